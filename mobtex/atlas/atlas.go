@@ -0,0 +1,346 @@
+/*
+Package atlas packs many images into a single texture so that sprites, icons,
+and glyphs can be drawn without binding a texture for each one.  The packed
+image is saved like any other texture asset and a sidecar file, in JSON or
+binary form, describes the named regions within it.
+
+	a, img, err := atlas.Pack(images, &atlas.Options{Padding: 1, Extrude: 1})
+	if err != nil {
+		return err
+	}
+	a.Image = "sprites"
+	err = png.Encode(imageFile, img)
+	...
+	err = a.WriteJSON(sidecarFile)
+
+Applications load the sidecar and its texture together and look regions up by
+name.
+
+	a, texture, err := atlas.Load(glctx, "sprites.atlas", nil)
+	if err != nil {
+		return err
+	}
+	u0, v0, u1, v1 := a.Regions["player"].UV(texture.Origin)
+*/
+package atlas
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sort"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/mobtex"
+)
+
+// Atlas describes the named regions of an atlas texture.
+type Atlas struct {
+	// Image is the path of the atlas texture asset relative to the
+	// directory of the sidecar.  It may omit the extension so that
+	// mobtex.LoadPath resolves the variant best supported by the device.
+	Image string
+
+	// Width and Height are the dimensions of the atlas texture in texels.
+	Width  int
+	Height int
+
+	// Regions maps region names to their locations.
+	Regions map[string]*Region
+}
+
+// Region is a named rectangle of an atlas.
+type Region struct {
+	Name string
+
+	// X, Y, Width, and Height locate the region in texels from the top-left
+	// corner of the atlas, excluding any padding or extruded texels.
+	X      int
+	Y      int
+	Width  int
+	Height int
+
+	// U0, V0 is the texture coordinate of the top-left corner of the region
+	// and U1, V1 the bottom-right corner, for a texture whose first row is
+	// the top of the atlas.  UV adjusts them for other textures.
+	U0, V0 float32
+	U1, V1 float32
+}
+
+// UV returns the texture coordinates of the top-left and bottom-right corners
+// of r in a texture with the given origin.
+func (r *Region) UV(origin mobtex.Origin) (u0, v0, u1, v1 float32) {
+	if origin != mobtex.OriginTopLeft {
+		return r.U0, 1 - r.V0, r.U1, 1 - r.V1
+	}
+	return r.U0, r.V0, r.U1, r.V1
+}
+
+// setUV computes the texture coordinates of r in an atlas of the given size.
+func (r *Region) setUV(width, height int) {
+	r.U0 = float32(r.X) / float32(width)
+	r.V0 = float32(r.Y) / float32(height)
+	r.U1 = float32(r.X+r.Width) / float32(width)
+	r.V1 = float32(r.Y+r.Height) / float32(height)
+}
+
+// names returns the names of the regions of a in sorted order.
+func (a *Atlas) names() []string {
+	names := make([]string, 0, len(a.Regions))
+	for name := range a.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Algorithm is a rectangle packing algorithm.
+type Algorithm int
+
+// Available packing algorithms.
+const (
+	// MaxRects packs most tightly, filling holes left between earlier
+	// rectangles.
+	MaxRects Algorithm = iota
+
+	// Skyline is faster than MaxRects and packs similar sized images, like
+	// glyphs, nearly as well.
+	Skyline
+)
+
+func (alg Algorithm) String() string {
+	switch alg {
+	case MaxRects:
+		return "maxrects"
+	case Skyline:
+		return "skyline"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(alg))
+	}
+}
+
+// Options control how images are packed.  A nil *Options, or any zero field,
+// selects the default behavior.
+type Options struct {
+	// Algorithm selects the packing algorithm.  The default is MaxRects.
+	Algorithm Algorithm
+
+	// MaxWidth and MaxHeight limit the dimensions of the atlas.  The
+	// default for each is 2048, the largest texture supported by most
+	// devices.
+	MaxWidth  int
+	MaxHeight int
+
+	// Padding is the number of transparent texels kept between regions and
+	// around the edges of the atlas.
+	Padding int
+
+	// Extrude is the number of times the edge texels of each image are
+	// repeated around it, so that filtering and mipmapping near the edges
+	// of a region do not sample its neighbors.  Extruded texels lie within
+	// the padding of the region.
+	Extrude int
+
+	// NPOT allows atlas dimensions which are not powers of two.  The atlas
+	// is then cropped to its regions, otherwise each dimension is the
+	// smallest power of two holding them.
+	NPOT bool
+}
+
+// DefaultMaxSize is the default MaxWidth and MaxHeight of an atlas.
+const DefaultMaxSize = 2048
+
+// Pack packs the named images into an atlas, returning the atlas description
+// and its image.
+func Pack(images map[string]image.Image, opts *Options) (*Atlas, *image.NRGBA, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.Algorithm != MaxRects && opts.Algorithm != Skyline {
+		return nil, nil, fmt.Errorf("atlas: unknown algorithm %v", opts.Algorithm)
+	}
+	if opts.Padding < 0 || opts.Extrude < 0 {
+		return nil, nil, fmt.Errorf("atlas: negative padding or extrusion")
+	}
+	maxWidth, maxHeight := opts.MaxWidth, opts.MaxHeight
+	if maxWidth <= 0 {
+		maxWidth = DefaultMaxSize
+	}
+	if maxHeight <= 0 {
+		maxHeight = DefaultMaxSize
+	}
+
+	// each image reserves its extruded border and the padding to its right
+	// and bottom.  Packing within a bin inset by the padding leaves the
+	// padding at the top and left edges of the atlas.
+	border := opts.Extrude
+	items := make([]*packItem, 0, len(images))
+	area, minWidth, minHeight := 0, 1, 1
+	for name, img := range images {
+		b := img.Bounds()
+		if b.Empty() {
+			return nil, nil, fmt.Errorf("atlas: %s is empty", name)
+		}
+		item := &packItem{
+			name: name,
+			img:  img,
+			w:    b.Dx() + 2*border + opts.Padding,
+			h:    b.Dy() + 2*border + opts.Padding,
+		}
+		if item.w > maxWidth-opts.Padding || item.h > maxHeight-opts.Padding {
+			return nil, nil, fmt.Errorf("atlas: %s (%dx%d) does not fit in %dx%d", name, b.Dx(), b.Dy(), maxWidth, maxHeight)
+		}
+		items = append(items, item)
+		area += item.w * item.h
+		if item.w+opts.Padding > minWidth {
+			minWidth = item.w + opts.Padding
+		}
+		if item.h+opts.Padding > minHeight {
+			minHeight = item.h + opts.Padding
+		}
+	}
+	// large images are placed first, ties are broken by name so that the
+	// result does not depend on map order.
+	sort.Sort(byArea(items))
+
+	// try successively larger bins, growing the shorter side, until the
+	// images fit.
+	width, height := nextPowerOfTwo(minWidth), nextPowerOfTwo(minHeight)
+	for width*height < area {
+		if width <= height {
+			width *= 2
+		} else {
+			height *= 2
+		}
+	}
+	if width > maxWidth {
+		width = maxWidth
+	}
+	if height > maxHeight {
+		height = maxHeight
+	}
+	for !packItems(items, opts.Algorithm, width-opts.Padding, height-opts.Padding) {
+		switch {
+		case width >= maxWidth && height >= maxHeight:
+			return nil, nil, fmt.Errorf("atlas: %d images do not fit in %dx%d", len(items), maxWidth, maxHeight)
+		case width <= height && width < maxWidth, height >= maxHeight:
+			width *= 2
+		default:
+			height *= 2
+		}
+		if width > maxWidth {
+			width = maxWidth
+		}
+		if height > maxHeight {
+			height = maxHeight
+		}
+	}
+
+	usedWidth, usedHeight := 1, 1
+	for _, item := range items {
+		item.x += opts.Padding
+		item.y += opts.Padding
+		if item.x+item.w > usedWidth {
+			usedWidth = item.x + item.w
+		}
+		if item.y+item.h > usedHeight {
+			usedHeight = item.y + item.h
+		}
+	}
+	if opts.NPOT {
+		width, height = usedWidth, usedHeight
+	} else {
+		width, height = nextPowerOfTwo(usedWidth), nextPowerOfTwo(usedHeight)
+		if width > maxWidth {
+			width = usedWidth
+		}
+		if height > maxHeight {
+			height = usedHeight
+		}
+	}
+
+	a := &Atlas{
+		Width:   width,
+		Height:  height,
+		Regions: make(map[string]*Region, len(items)),
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for _, item := range items {
+		b := item.img.Bounds()
+		r := &Region{
+			Name:   item.name,
+			X:      item.x + border,
+			Y:      item.y + border,
+			Width:  b.Dx(),
+			Height: b.Dy(),
+		}
+		r.setUV(width, height)
+		a.Regions[item.name] = r
+		draw.Draw(dst, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), item.img, b.Min, draw.Src)
+		extrude(dst, image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height), border)
+	}
+	return a, dst, nil
+}
+
+// packItem is an image being packed and the rectangle reserved for it.
+type packItem struct {
+	name string
+	img  image.Image
+	x, y int
+	w, h int
+}
+
+type byArea []*packItem
+
+func (s byArea) Len() int      { return len(s) }
+func (s byArea) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byArea) Less(i, j int) bool {
+	ai, aj := s[i].w*s[i].h, s[j].w*s[j].h
+	if ai != aj {
+		return ai > aj
+	}
+	return s[i].name < s[j].name
+}
+
+// packItems places items in a bin of the given size, returning false if they
+// do not all fit.
+func packItems(items []*packItem, alg Algorithm, width, height int) bool {
+	p := newPacker(alg, width, height)
+	for _, item := range items {
+		var ok bool
+		item.x, item.y, ok = p.insert(item.w, item.h)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// extrude repeats the edge texels of r in img n times outward.
+func extrude(img *image.NRGBA, r image.Rectangle, n int) {
+	for i := 1; i <= n; i++ {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			copyTexel(img, r.Min.X-i, y, r.Min.X, y)
+			copyTexel(img, r.Max.X-1+i, y, r.Max.X-1, y)
+		}
+	}
+	// rows are extruded after columns to fill the corners.
+	for i := 1; i <= n; i++ {
+		for x := r.Min.X - n; x < r.Max.X+n; x++ {
+			copyTexel(img, x, r.Min.Y-i, x, r.Min.Y)
+			copyTexel(img, x, r.Max.Y-1+i, x, r.Max.Y-1)
+		}
+	}
+}
+
+func copyTexel(img *image.NRGBA, dx, dy, sx, sy int) {
+	i, j := img.PixOffset(dx, dy), img.PixOffset(sx, sy)
+	copy(img.Pix[i:i+4], img.Pix[j:j+4])
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,178 @@
+package atlas
+
+// rect is a rectangle of texels with its origin at the top-left corner.
+type rect struct {
+	x, y, w, h int
+}
+
+func (r rect) intersects(s rect) bool {
+	return r.x < s.x+s.w && s.x < r.x+r.w && r.y < s.y+s.h && s.y < r.y+r.h
+}
+
+func (r rect) contains(s rect) bool {
+	return s.x >= r.x && s.y >= r.y && s.x+s.w <= r.x+r.w && s.y+s.h <= r.y+r.h
+}
+
+// packer places rectangles in a bin of fixed size.
+type packer interface {
+	// insert finds a position for a w by h rectangle and reserves it.  If
+	// the rectangle does not fit ok is false.
+	insert(w, h int) (x, y int, ok bool)
+}
+
+func newPacker(alg Algorithm, width, height int) packer {
+	if alg == Skyline {
+		return &skyline{width: width, height: height, nodes: []skylineNode{{w: width}}}
+	}
+	return &maxRects{free: []rect{{0, 0, width, height}}}
+}
+
+// maxRects is the MaxRects algorithm.  It tracks the maximal free rectangles
+// of the bin, which may overlap, and places each rectangle in the free
+// rectangle it fits most tightly along its shorter side.
+type maxRects struct {
+	free []rect
+}
+
+func (p *maxRects) insert(w, h int) (x, y int, ok bool) {
+	best := -1
+	var bestShort, bestLong int
+	for i, f := range p.free {
+		if f.w < w || f.h < h {
+			continue
+		}
+		short, long := f.w-w, f.h-h
+		if short > long {
+			short, long = long, short
+		}
+		if best < 0 || short < bestShort || short == bestShort && long < bestLong {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	r := rect{p.free[best].x, p.free[best].y, w, h}
+	p.place(r)
+	return r.x, r.y, true
+}
+
+// place splits the free rectangles overlapped by r into the maximal
+// rectangles around it.
+func (p *maxRects) place(r rect) {
+	var free []rect
+	for _, f := range p.free {
+		if !f.intersects(r) {
+			free = append(free, f)
+			continue
+		}
+		if r.x > f.x {
+			free = append(free, rect{f.x, f.y, r.x - f.x, f.h})
+		}
+		if r.x+r.w < f.x+f.w {
+			free = append(free, rect{r.x + r.w, f.y, f.x + f.w - r.x - r.w, f.h})
+		}
+		if r.y > f.y {
+			free = append(free, rect{f.x, f.y, f.w, r.y - f.y})
+		}
+		if r.y+r.h < f.y+f.h {
+			free = append(free, rect{f.x, r.y + r.h, f.w, f.y + f.h - r.y - r.h})
+		}
+	}
+
+	// drop rectangles contained by others, keeping the first of any
+	// duplicates.
+	p.free = p.free[:0]
+	for i, f := range free {
+		contained := false
+		for j, g := range free {
+			if i != j && g.contains(f) && (g != f || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			p.free = append(p.free, f)
+		}
+	}
+}
+
+// skylineNode is a horizontal segment of the skyline, the top edge of the
+// texels used in its columns.
+type skylineNode struct {
+	x, y, w int
+}
+
+// skyline is the skyline bottom-left algorithm.  It tracks only the height of
+// the used texels in each column, so it is faster than maxRects but cannot
+// fill holes beneath the skyline.
+type skyline struct {
+	width  int
+	height int
+	nodes  []skylineNode
+}
+
+func (p *skyline) insert(w, h int) (x, y int, ok bool) {
+	best := -1
+	var bestY, bestX int
+	for i := range p.nodes {
+		y, ok := p.fit(i, w, h)
+		if !ok {
+			continue
+		}
+		if best < 0 || y < bestY || y == bestY && p.nodes[i].x < bestX {
+			best, bestY, bestX = i, y, p.nodes[i].x
+		}
+	}
+	if best < 0 {
+		return 0, 0, false
+	}
+	p.place(best, skylineNode{bestX, bestY + h, w})
+	return bestX, bestY, true
+}
+
+// fit returns the lowest y at which a w by h rectangle can sit on the skyline
+// with its left edge at node i.
+func (p *skyline) fit(i, w, h int) (y int, ok bool) {
+	if p.nodes[i].x+w > p.width {
+		return 0, false
+	}
+	for remaining := w; remaining > 0; i++ {
+		if p.nodes[i].y > y {
+			y = p.nodes[i].y
+		}
+		remaining -= p.nodes[i].w
+	}
+	return y, y+h <= p.height
+}
+
+// place inserts node at index i and trims the nodes it covers.
+func (p *skyline) place(i int, node skylineNode) {
+	p.nodes = append(p.nodes, skylineNode{})
+	copy(p.nodes[i+1:], p.nodes[i:])
+	p.nodes[i] = node
+
+	for j := i + 1; j < len(p.nodes); {
+		prev := p.nodes[j-1]
+		shrink := prev.x + prev.w - p.nodes[j].x
+		if shrink <= 0 {
+			break
+		}
+		p.nodes[j].x += shrink
+		p.nodes[j].w -= shrink
+		if p.nodes[j].w > 0 {
+			break
+		}
+		p.nodes = append(p.nodes[:j], p.nodes[j+1:]...)
+	}
+
+	// merge neighbors at the same height.
+	for j := 1; j < len(p.nodes); {
+		if p.nodes[j-1].y == p.nodes[j].y {
+			p.nodes[j-1].w += p.nodes[j].w
+			p.nodes = append(p.nodes[:j], p.nodes[j+1:]...)
+			continue
+		}
+		j++
+	}
+}
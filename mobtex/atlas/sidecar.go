@@ -0,0 +1,298 @@
+package atlas
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/mobtex"
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/gl"
+)
+
+// Load loads the atlas sidecar asset at sidecarPath and its texture into
+// glctx.  The texture is loaded with mobtex.LoadPath according to opts, which
+// may be nil.  The texture must have the dimensions of the sidecar, or those
+// it is scaled to by opts.Mipmaps.Resize.  The texture coordinates of regions
+// are unaffected by scaling but their X, Y, Width, and Height remain in the
+// texels of the sidecar.
+func Load(glctx gl.Context, sidecarPath string, opts *mobtex.TextureOptions) (*Atlas, *mobtex.Texture, error) {
+	a, err := DecodePath(sidecarPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Image == "" {
+		return nil, nil, fmt.Errorf("atlas: %s does not name an image", sidecarPath)
+	}
+	texture, err := mobtex.LoadPath(glctx, path.Join(path.Dir(sidecarPath), a.Image), opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	width, height := a.Width, a.Height
+	if opts != nil && opts.Mipmaps != nil {
+		width, height = opts.Mipmaps.Resize.Dimensions(width, height)
+	}
+	sized := texture.Width == a.Width && texture.Height == a.Height
+	resized := texture.Width == width && texture.Height == height
+	if !sized && !resized {
+		texture.Release()
+		return nil, nil, fmt.Errorf("atlas: texture is %dx%d, expected %dx%d", texture.Width, texture.Height, a.Width, a.Height)
+	}
+	return a, texture, nil
+}
+
+// DecodePath decodes the atlas sidecar asset at path with Decode.
+func DecodePath(path string) (*Atlas, error) {
+	f, err := asset.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// Decode decodes an atlas sidecar written by WriteJSON or WriteBinary from r.
+func Decode(r io.Reader) (*Atlas, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(binaryMagic))
+	if err == nil && bytes.Equal(magic, []byte(binaryMagic)) {
+		return decodeBinary(br)
+	}
+	return decodeJSON(br)
+}
+
+// jsonAtlas is the JSON form of an Atlas.  Regions are stored in name order
+// so that sidecars are reproducible.
+type jsonAtlas struct {
+	Image   string       `json:"image"`
+	Width   int          `json:"width"`
+	Height  int          `json:"height"`
+	Regions []jsonRegion `json:"regions"`
+}
+
+type jsonRegion struct {
+	Name   string  `json:"name"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Width  int     `json:"width"`
+	Height int     `json:"height"`
+	U0     float32 `json:"u0"`
+	V0     float32 `json:"v0"`
+	U1     float32 `json:"u1"`
+	V1     float32 `json:"v1"`
+}
+
+// WriteJSON writes a to w as a JSON sidecar.
+func (a *Atlas) WriteJSON(w io.Writer) error {
+	ja := jsonAtlas{
+		Image:   a.Image,
+		Width:   a.Width,
+		Height:  a.Height,
+		Regions: make([]jsonRegion, 0, len(a.Regions)),
+	}
+	for _, name := range a.names() {
+		r := a.Regions[name]
+		ja.Regions = append(ja.Regions, jsonRegion{
+			Name:   name,
+			X:      r.X,
+			Y:      r.Y,
+			Width:  r.Width,
+			Height: r.Height,
+			U0:     r.U0,
+			V0:     r.V0,
+			U1:     r.U1,
+			V1:     r.V1,
+		})
+	}
+	b, err := json.MarshalIndent(&ja, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+func decodeJSON(r io.Reader) (*Atlas, error) {
+	var ja jsonAtlas
+	err := json.NewDecoder(r).Decode(&ja)
+	if err != nil {
+		return nil, fmt.Errorf("atlas: %v", err)
+	}
+	if ja.Width < 0 || ja.Height < 0 || int64(ja.Width) > math.MaxUint32 || int64(ja.Height) > math.MaxUint32 {
+		return nil, fmt.Errorf("atlas: invalid dimensions %dx%d", ja.Width, ja.Height)
+	}
+	a := &Atlas{
+		Image:   ja.Image,
+		Width:   ja.Width,
+		Height:  ja.Height,
+		Regions: make(map[string]*Region, len(ja.Regions)),
+	}
+	for _, jr := range ja.Regions {
+		err = a.addRegion(&Region{
+			Name:   jr.Name,
+			X:      jr.X,
+			Y:      jr.Y,
+			Width:  jr.Width,
+			Height: jr.Height,
+			U0:     jr.U0,
+			V0:     jr.V0,
+			U1:     jr.U1,
+			V1:     jr.V1,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// The binary sidecar is little-endian.  It begins with binaryMagic and a
+// uint32 version followed by the uint32 width and height of the atlas, the
+// image path, and a uint32 count of regions.  Each region is its name
+// followed by a binaryRegion.  Strings are stored as a uint32 length followed
+// by their bytes.
+const (
+	binaryMagic   = "\xabATLAS\r\n"
+	binaryVersion = 1
+
+	// maxBinaryString and maxBinaryRegions bound allocations made while
+	// decoding corrupt sidecars.
+	maxBinaryString  = 1 << 12
+	maxBinaryRegions = 1 << 20
+)
+
+type binaryRegion struct {
+	X, Y, Width, Height uint32
+	U0, V0, U1, V1      float32
+}
+
+// WriteBinary writes a to w as a binary sidecar.
+func (a *Atlas) WriteBinary(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(binaryMagic)
+	writeUint32 := func(v ...uint32) {
+		binary.Write(bw, binary.LittleEndian, v)
+	}
+	writeString := func(s string) {
+		writeUint32(uint32(len(s)))
+		bw.WriteString(s)
+	}
+
+	writeUint32(binaryVersion, uint32(a.Width), uint32(a.Height))
+	writeString(a.Image)
+	writeUint32(uint32(len(a.Regions)))
+	for _, name := range a.names() {
+		r := a.Regions[name]
+		writeString(name)
+		binary.Write(bw, binary.LittleEndian, &binaryRegion{
+			X:      uint32(r.X),
+			Y:      uint32(r.Y),
+			Width:  uint32(r.Width),
+			Height: uint32(r.Height),
+			U0:     r.U0,
+			V0:     r.V0,
+			U1:     r.U1,
+			V1:     r.V1,
+		})
+	}
+	// bufio.Writer retains the first write error.
+	return bw.Flush()
+}
+
+func decodeBinary(r io.Reader) (*Atlas, error) {
+	var err error
+	readUint32 := func() uint32 {
+		var v uint32
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, &v)
+		}
+		return v
+	}
+	readString := func() string {
+		n := readUint32()
+		if err != nil {
+			return ""
+		}
+		if n > maxBinaryString {
+			err = fmt.Errorf("string of %d bytes", n)
+			return ""
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b)
+	}
+
+	_, err = io.ReadFull(r, make([]byte, len(binaryMagic)))
+	version := readUint32()
+	if err == nil && version != binaryVersion {
+		return nil, fmt.Errorf("atlas: unsupported binary version %d", version)
+	}
+	a := &Atlas{
+		Width:  int(readUint32()),
+		Height: int(readUint32()),
+		Image:  readString(),
+	}
+	count := readUint32()
+	if err == nil && count > maxBinaryRegions {
+		return nil, fmt.Errorf("atlas: %d regions", count)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("atlas: %v", err)
+	}
+
+	// count is not trusted as a size hint since a corrupt sidecar may claim
+	// far more regions than it holds.
+	a.Regions = make(map[string]*Region)
+	for i := uint32(0); i < count; i++ {
+		name := readString()
+		var br binaryRegion
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, &br)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("atlas: region %d: %v", i, err)
+		}
+		err = a.addRegion(&Region{
+			Name:   name,
+			X:      int(br.X),
+			Y:      int(br.Y),
+			Width:  int(br.Width),
+			Height: int(br.Height),
+			U0:     br.U0,
+			V0:     br.V0,
+			U1:     br.U1,
+			V1:     br.V1,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// addRegion validates r and adds it to a.
+func (a *Atlas) addRegion(r *Region) error {
+	if r.Name == "" {
+		return fmt.Errorf("atlas: region without a name")
+	}
+	if _, ok := a.Regions[r.Name]; ok {
+		return fmt.Errorf("atlas: duplicate region %s", r.Name)
+	}
+	if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 ||
+		r.X > a.Width-r.Width || r.Y > a.Height-r.Height {
+		return fmt.Errorf("atlas: region %s (%d,%d %dx%d) is outside the %dx%d atlas",
+			r.Name, r.X, r.Y, r.Width, r.Height, a.Width, a.Height)
+	}
+	for _, uv := range []float32{r.U0, r.V0, r.U1, r.V1} {
+		if math.IsNaN(float64(uv)) || uv < 0 || uv > 1 {
+			return fmt.Errorf("atlas: region %s has texture coordinate %v", r.Name, uv)
+		}
+	}
+	a.Regions[r.Name] = r
+	return nil
+}
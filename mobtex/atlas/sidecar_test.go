@@ -0,0 +1,180 @@
+package atlas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testImages returns images of assorted sizes, each filled with its own
+// color.
+func testImages() map[string]image.Image {
+	sizes := map[string]image.Point{
+		"player": {32, 48},
+		"enemy":  {24, 24},
+		"tile":   {16, 16},
+		"wide":   {100, 8},
+		"tall":   {6, 70},
+		"dot":    {1, 1},
+	}
+	images := make(map[string]image.Image)
+	i := 0
+	for name, size := range sizes {
+		img := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+		c := color.NRGBA{uint8(40 * i), uint8(255 - 40*i), 0x80, 0xff}
+		for y := 0; y < size.Y; y++ {
+			for x := 0; x < size.X; x++ {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+		images[name] = img
+		i++
+	}
+	return images
+}
+
+func TestSidecarRoundTrip(t *testing.T) {
+	for _, opts := range []*Options{
+		nil,
+		{Algorithm: Skyline},
+		{Padding: 2, Extrude: 1},
+		{Padding: 1, NPOT: true},
+	} {
+		a, _, err := Pack(testImages(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Image = "sprites"
+		for _, format := range []struct {
+			name  string
+			write func(*Atlas, *bytes.Buffer) error
+		}{
+			{"json", func(a *Atlas, b *bytes.Buffer) error { return a.WriteJSON(b) }},
+			{"binary", func(a *Atlas, b *bytes.Buffer) error { return a.WriteBinary(b) }},
+		} {
+			var b bytes.Buffer
+			err := format.write(a, &b)
+			if err != nil {
+				t.Fatalf("%s: %v", format.name, err)
+			}
+			decoded, err := Decode(&b)
+			if err != nil {
+				t.Errorf("%+v %s: %v", opts, format.name, err)
+				continue
+			}
+			if !reflect.DeepEqual(decoded, a) {
+				t.Errorf("%+v %s: decoded %+v, expected %+v", opts, format.name, decoded, a)
+			}
+		}
+	}
+}
+
+// binarySidecar returns the binary sidecar of an atlas with one region,
+// with fn applied to it before it is encoded.
+func binarySidecar(fn func(a *Atlas)) []byte {
+	a := &Atlas{
+		Image:  "sprites",
+		Width:  16,
+		Height: 8,
+		Regions: map[string]*Region{
+			"dot": {Name: "dot", X: 4, Y: 2, Width: 1, Height: 1, U0: 0.25, V0: 0.25, U1: 0.3125, V1: 0.375},
+		},
+	}
+	if fn != nil {
+		fn(a)
+	}
+	var b bytes.Buffer
+	a.WriteBinary(&b)
+	return b.Bytes()
+}
+
+// malformedSidecars returns sidecars Decode rejects.
+func malformedSidecars() map[string][]byte {
+	m := map[string][]byte{
+		"empty":          nil,
+		"json syntax":    []byte(`{"image": "sprites",`),
+		"json type":      []byte(`{"image": 1}`),
+		"json unnamed":   []byte(`{"width": 4, "height": 4, "regions": [{"width": 1, "height": 1}]}`),
+		"json duplicate": []byte(`{"width": 4, "height": 4, "regions": [{"name": "a", "width": 1, "height": 1}, {"name": "a", "width": 1, "height": 1}]}`),
+		"json outside":   []byte(`{"width": 4, "height": 4, "regions": [{"name": "a", "x": 3, "width": 2, "height": 1}]}`),
+		"json overflow":  []byte(`{"width": 4, "height": 4, "regions": [{"name": "a", "x": 9223372036854775807, "width": 2, "height": 1}]}`),
+		"json uv":        []byte(`{"width": 4, "height": 4, "regions": [{"name": "a", "width": 1, "height": 1, "u1": 2}]}`),
+		"json negative":  []byte(`{"width": -5, "height": 4}`),
+		"json wide":      []byte(`{"width": 4294967296, "height": 4}`),
+		"binary magic":   []byte(binaryMagic),
+	}
+
+	b := binarySidecar(nil)
+	m["binary truncated"] = b[:len(b)-1]
+	b = binarySidecar(nil)
+	b[len(binaryMagic)] = 2
+	m["binary version"] = b
+	m["binary string"] = binarySidecar(func(a *Atlas) { a.Image = strings.Repeat("x", maxBinaryString+1) })
+	b = binarySidecar(func(a *Atlas) { a.Regions = nil })
+	binary.LittleEndian.PutUint32(b[len(b)-4:], maxBinaryRegions+1)
+	m["binary count"] = b
+	b = binarySidecar(func(a *Atlas) { a.Regions = nil })
+	binary.LittleEndian.PutUint32(b[len(b)-4:], maxBinaryRegions)
+	m["binary missing regions"] = b
+	m["binary outside"] = binarySidecar(func(a *Atlas) { a.Regions["dot"].X = 16 })
+	m["binary uv"] = binarySidecar(func(a *Atlas) { a.Regions["dot"].V1 = float32(math.NaN()) })
+	m["binary unnamed"] = binarySidecar(func(a *Atlas) { a.Regions = map[string]*Region{"": {Width: 1, Height: 1}} })
+	return m
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for name, b := range malformedSidecars() {
+		_, err := Decode(bytes.NewReader(b))
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	a, _, err := Pack(testImages(), nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	var b bytes.Buffer
+	a.WriteJSON(&b)
+	f.Add(b.Bytes())
+	b.Reset()
+	a.WriteBinary(&b)
+	f.Add(b.Bytes())
+	for _, b := range malformedSidecars() {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) > maxBinaryString {
+			// longer JSON strings cannot be written to a binary sidecar.
+			return
+		}
+		a, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		for name, r := range a.Regions {
+			if name != r.Name || r.Width <= 0 || r.Height <= 0 || r.X+r.Width > a.Width || r.Y+r.Height > a.Height {
+				t.Fatalf("invalid region %+v in a %dx%d atlas", r, a.Width, a.Height)
+			}
+		}
+		var buf bytes.Buffer
+		err = a.WriteBinary(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("failed to decode rewritten sidecar: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, a) {
+			t.Fatalf("decoded %+v, expected %+v", decoded, a)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package mobtex
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/ktx"
+	"golang.org/x/mobile/gl"
+)
+
+// Capabilities describes the texture features of a gl.Context.
+type Capabilities struct {
+	// Extensions lists the names in the EXTENSIONS string.
+	Extensions []string
+
+	// CompressedFormats lists COMPRESSED_TEXTURE_FORMATS, the compressed
+	// internal formats the device can sample.
+	CompressedFormats []gl.Enum
+
+	// Each compressed format family is supported if one of its formats is
+	// listed in CompressedFormats or its extension is present.
+	ETC1  bool
+	ETC2  bool
+	S3TC  bool
+	ASTC  bool
+	PVRTC bool
+	RGTC  bool
+	BPTC  bool
+
+	// SRGB is true if GL_EXT_sRGB is supported.
+	SRGB bool
+
+	// MaxAnisotropy is the largest anisotropy TextureOptions may request, or
+	// zero if GL_EXT_texture_filter_anisotropic is not supported.
+	MaxAnisotropy float32
+
+	// MaxTextureSize is the largest texture width or height.
+	MaxTextureSize int
+}
+
+var capabilities = struct {
+	sync.Mutex
+	m map[gl.Context]*Capabilities
+}{m: make(map[gl.Context]*Capabilities)}
+
+// QueryCapabilities returns the capabilities of glctx.  The context is probed
+// the first time it is queried and the result is reused until Forget is
+// called.
+func QueryCapabilities(glctx gl.Context) *Capabilities {
+	capabilities.Lock()
+	defer capabilities.Unlock()
+	if caps, ok := capabilities.m[glctx]; ok {
+		return caps
+	}
+	caps := probeCapabilities(glctx)
+	capabilities.m[glctx] = caps
+	return caps
+}
+
+// Forget discards the capabilities of glctx cached by QueryCapabilities.  It
+// should be called when glctx is lost, as on lifecycle.CrossOff, so that the
+// context is not kept alive and a later context is probed again.
+func Forget(glctx gl.Context) {
+	capabilities.Lock()
+	defer capabilities.Unlock()
+	delete(capabilities.m, glctx)
+}
+
+// probeCapabilities queries glctx for its capabilities.
+func probeCapabilities(glctx gl.Context) *Capabilities {
+	caps := &Capabilities{
+		Extensions:     strings.Fields(glctx.GetString(gl.EXTENSIONS)),
+		MaxTextureSize: glctx.GetInteger(gl.MAX_TEXTURE_SIZE),
+	}
+	if n := glctx.GetInteger(gl.NUM_COMPRESSED_TEXTURE_FORMATS); n > 0 {
+		formats := make([]int32, n)
+		glctx.GetIntegerv(formats, gl.COMPRESSED_TEXTURE_FORMATS)
+		for _, f := range formats {
+			caps.CompressedFormats = append(caps.CompressedFormats, gl.Enum(f))
+		}
+	}
+
+	family := func(format gl.Enum, extensions ...string) bool {
+		if caps.HasCompressedFormat(format) {
+			return true
+		}
+		for _, ext := range extensions {
+			if caps.HasExtension(ext) {
+				return true
+			}
+		}
+		return false
+	}
+	caps.ETC1 = family(0x8D64, "GL_OES_compressed_ETC1_RGB8_texture")
+	caps.ETC2 = family(0x9274, "GL_OES_compressed_ETC2_RGB8_texture")
+	caps.S3TC = family(0x83F1, "GL_EXT_texture_compression_s3tc", "GL_EXT_texture_compression_dxt1")
+	caps.ASTC = family(0x93B0, "GL_KHR_texture_compression_astc_ldr")
+	caps.PVRTC = family(0x8C00, "GL_IMG_texture_compression_pvrtc")
+	caps.RGTC = family(0x8DBB, "GL_EXT_texture_compression_rgtc")
+	caps.BPTC = family(0x8E8C, "GL_EXT_texture_compression_bptc")
+	caps.SRGB = caps.HasExtension("GL_EXT_sRGB")
+
+	if caps.HasExtension("GL_EXT_texture_filter_anisotropic") {
+		var max [1]float32
+		glctx.GetFloatv(max[:], glMaxTextureMaxAnisotropy)
+		caps.MaxAnisotropy = max[0]
+	}
+	return caps
+}
+
+// HasExtension returns true if the named extension is supported.
+func (caps *Capabilities) HasExtension(name string) bool {
+	for _, ext := range caps.Extensions {
+		if ext == name {
+			return true
+		}
+	}
+	return false
+}
+
+// HasCompressedFormat returns true if format is listed in
+// COMPRESSED_TEXTURE_FORMATS.
+func (caps *Capabilities) HasCompressedFormat(format gl.Enum) bool {
+	for _, f := range caps.CompressedFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// hasExtension returns true if glctx supports the named extension.
+func hasExtension(glctx gl.Context, name string) bool {
+	return QueryCapabilities(glctx).HasExtension(name)
+}
+
+// compressedTextureFormats returns the compressed formats supported by
+// glctx.
+func compressedTextureFormats(glctx gl.Context) []gl.Enum {
+	return QueryCapabilities(glctx).CompressedFormats
+}
+
+// hasCompressedFormat returns true if format is listed in
+// COMPRESSED_TEXTURE_FORMATS.
+func hasCompressedFormat(glctx gl.Context, format gl.Enum) bool {
+	return QueryCapabilities(glctx).HasCompressedFormat(format)
+}
+
+// compressedFormatNames returns the names of the compressed formats supported
+// by glctx.
+func compressedFormatNames(glctx gl.Context) string {
+	var names []string
+	for _, f := range compressedTextureFormats(glctx) {
+		names = append(names, ktx.FormatName(uint32(f)))
+	}
+	return strings.Join(names, ", ")
+}
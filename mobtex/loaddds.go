@@ -2,123 +2,200 @@ package mobtex
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
 	"fmt"
+	"image"
 	"io"
 	"log"
 
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/dds"
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/ktx"
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/s3tc"
 	"golang.org/x/mobile/asset"
 	"golang.org/x/mobile/gl"
 )
 
-var zt gl.Texture
-
 // LoadDDSPath loads a DDS asset at path into the given gl.Context and returns
-// the gl.Texture identifier for the resulting texture.
-func LoadDDSPath(glctx gl.Context, path string) (gl.Texture, error) {
+// the resulting texture.
+func LoadDDSPath(glctx gl.Context, path string, opts *TextureOptions) (*Texture, error) {
 	f, err := asset.Open(path)
 	if err != nil {
-		return zt, err
+		return nil, err
 	}
 	defer f.Close()
-	return LoadDDS(glctx, f)
+	return LoadDDS(glctx, f, opts)
 }
 
-var ddsFileCode = []byte("DDS ")
-
-// LoadDDS loads a DDS formatted byte stream from r into the given gl.Context
-// and returns the gl.Texture identifier for the resulting texture.
-func LoadDDS(glctx gl.Context, r io.Reader) (gl.Texture, error) {
-	r = bufio.NewReader(r)
+// maxDDSSize is the largest amount of pixel data LoadDDS will allocate when
+// decompressing a level.
+const maxDDSSize = 1 << 28
 
-	var (
-		header      [124]byte
-		fileCode    [4]byte
-		height      uint32
-		width       uint32
-		linearSize  uint32
-		mipMapCount uint32
-	)
+// ddsS3TCFormats are the dds formats which can be decompressed in software.
+var ddsS3TCFormats = map[dds.Format]s3tc.Format{
+	dds.BC1: s3tc.DXT1,
+	dds.BC2: s3tc.DXT3,
+	dds.BC3: s3tc.DXT5,
+}
 
-	_, err := io.ReadFull(r, fileCode[:])
+// LoadDDS loads a DDS formatted byte stream from r into the given gl.Context
+// and returns the resulting texture.  Block compressed data is uploaded as is,
+// uncompressed data is converted to RGB, RGBA, LUMINANCE, or ALPHA pixels.
+// Cubemap files are loaded as TEXTURE_CUBE_MAP textures, array files are not
+// supported.
+//
+// DDS images are stored top-down so the texture has the top-left Origin.
+// Devices which do not list the S3TC format of the stream in
+// COMPRESSED_TEXTURE_FORMATS receive the data decompressed to RGBA, other
+// compressed formats fail to load on such devices.  opts.FlipY is honored only
+// for uncompressed 2D textures.
+func LoadDDS(glctx gl.Context, r io.Reader, opts *TextureOptions) (*Texture, error) {
+	opts = opts.orDefault()
+	header, data, err := dds.Read(bufio.NewReader(r))
 	if err != nil {
-		return zt, err
+		return nil, err
 	}
-	if !bytes.Equal(fileCode[:], ddsFileCode) {
-		return zt, fmt.Errorf("not a dds format stream")
+	if header.NumArrayElements() > 1 {
+		return nil, fmt.Errorf("array textures are not supported")
 	}
-	_, err = io.ReadFull(r, header[:])
+	f, err := header.Format()
 	if err != nil {
-		return zt, fmt.Errorf("failed to read header: %v", err)
+		return nil, err
 	}
-
-	height = binary.LittleEndian.Uint32(header[8:12])
-	width = binary.LittleEndian.Uint32(header[12:16])
-	linearSize = binary.LittleEndian.Uint32(header[16:20])
-
-	mipMapCount = binary.LittleEndian.Uint32(header[24:28])
-	fourCC := string(header[80:84])
-
-	bufSize := linearSize
-	if mipMapCount > 1 {
-		bufSize = linearSize * 2
+	log.Printf("DDS %v %dx%d LEVELS=%d FACES=%d SRGB=%v",
+		f, header.Width, header.Height, header.NumLevels(), header.NumFaces(), header.SRGB())
+
+	target := gl.Enum(gl.TEXTURE_2D)
+	faceTarget := gl.Enum(gl.TEXTURE_2D)
+	if header.IsCubemap() {
+		target = gl.TEXTURE_CUBE_MAP
+		faceTarget = gl.TEXTURE_CUBE_MAP_POSITIVE_X
 	}
-	buf := make([]byte, bufSize)
-	n, err := io.ReadFull(r, buf)
-	if err != nil && err != io.ErrUnexpectedEOF {
-		return zt, fmt.Errorf("failed to read data (%d of %d bytes): %v", n, bufSize, err)
+
+	// devices without support for a compressed format receive the data
+	// decompressed to RGBA, if possible.
+	var format gl.Enum
+	var dxt s3tc.Format
+	compressed := f.Compressed()
+	if compressed {
+		format = gl.Enum(f.GLInternalFormat(header.SRGB()))
+		if hasCompressedFormat(glctx, format) {
+			format = opts.srgbCompressedFormat(glctx, format)
+		} else if dxt = ddsS3TCFormats[f]; dxt != 0 {
+			log.Printf("%v is not supported, decompressing in software", f)
+			compressed = false
+		} else {
+			log.Printf("AVAILABLE COMPRESSED TEXTURE FORMATS: %s", compressedFormatNames(glctx))
+			return nil, fmt.Errorf("%v compressed textures are not supported by the device: %s", f, ktx.FormatName(uint32(format)))
+		}
 	}
-	buf = buf[:n]
-	var dummy [1]byte
-	n, err = io.ReadFull(r, dummy[:])
-	if err != io.EOF {
-		if err == nil {
-			return zt, fmt.Errorf("bytes remaining in stream")
+	origin := OriginTopLeft
+	flipped := false
+	if !compressed {
+		if header.SRGB() {
+			srgbOpts := *opts
+			srgbOpts.SRGB = true
+			opts = &srgbOpts
+		}
+		format = opts.srgbFormat(glctx, ddsPixelFormat(f))
+		flipped = opts.FlipY && !header.IsCubemap()
+		if flipped {
+			origin = origin.flip()
 		}
-		return zt, err
 	}
+	premultiplied := !compressed && opts.PremultiplyAlpha && f.HasAlpha() && f != dds.Alpha
 
-	var format gl.Enum
-	//numComponent := 4
-	blockSize := uint32(16)
-	switch fourCC {
-	case "DXT1":
-		format = 0x83F1
-		//numComponent = 3
-		blockSize = 8
-	case "DXT3":
-		format = 0x83F2
-	case "DXT5":
-		format = 0x83F3
-	default:
-		return zt, fmt.Errorf("invalid dxt identifier")
+	texture := glctx.CreateTexture()
+	glctx.BindTexture(target, texture)
+	defer setUnpackAlignment(glctx, 1)()
+
+	levels := header.NumLevels()
+	for level := 0; level < levels; level++ {
+		w, h := header.LevelDimensions(level)
+		for face, images := range data {
+			pix := images[level]
+			log.Printf("LEVEL=%d FACE=%d WIDTH=%d HEIGHT=%d SIZE=%d", level, face, w, h, len(pix))
+			if compressed {
+				glctx.CompressedTexImage2D(faceTarget+gl.Enum(face), level, format, w, h, 0, pix)
+			} else {
+				pix, err = ddsPixels(header, f, dxt, pix, w, h)
+				if err != nil {
+					return nil, err
+				}
+				if premultiplied {
+					premultiply(pix, 4)
+				}
+				if flipped {
+					flipRows(pix, len(pix)/h)
+				}
+				glctx.TexImage2D(faceTarget+gl.Enum(face), level, w, h, format, gl.UNSIGNED_BYTE, pix)
+			}
+			glerr := glctx.GetError()
+			if glerr == gl.INVALID_ENUM {
+				return nil, fmt.Errorf("invalid internal format: %v (%x)", f, format)
+			} else if glerr != 0 {
+				return nil, fmt.Errorf("internal gl error: %v", glerr)
+			}
+		}
 	}
 
-	texture := glctx.CreateTexture()
-	glctx.BindTexture(gl.TEXTURE_2D, texture)
-	glctx.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	levels = opts.finish(glctx, target, int(header.Width), int(header.Height), levels, compressed)
+
+	return &Texture{
+		Texture:    texture,
+		Target:     target,
+		Width:      int(header.Width),
+		Height:     int(header.Height),
+		Levels:     levels,
+		Format:     format,
+		Compressed: compressed,
+		Alpha:      formatHasAlpha(format),
+		Origin:     origin,
+		glctx:      glctx,
+	}, nil
+}
 
-	log.Printf(fourCC)
-	for level := 0; level < int(mipMapCount) && (width > 0 || height > 0); level++ {
-		size := ((width + 3) / 4) * ((height + 3) / 4) * blockSize
-		data := buf[:size]
-		log.Printf("LEVEL=%d WIDTH=%d HEIGHT=%d SIZE=%d", level, width, height, len(data))
-		glctx.CompressedTexImage2D(gl.TEXTURE_2D, level, format, int(width), int(height), 0, data)
-		glerr := glctx.GetError()
-		if glerr == gl.INVALID_ENUM {
-			return zt, fmt.Errorf("invalid internal format: %s (%x)", fourCC, format)
-		} else if glerr != 0 {
-			return zt, fmt.Errorf("internal gl error: %v", glerr)
-		}
-		buf = buf[size:]
-		width /= 2
-		height /= 2
+// ddsPixelFormat returns the format of pixels converted from f data.  S3TC
+// data is decompressed to RGBA.
+func ddsPixelFormat(f dds.Format) gl.Enum {
+	switch f {
+	case dds.RGB:
+		return gl.RGB
+	case dds.Luminance:
+		return gl.LUMINANCE
+	case dds.Alpha:
+		return gl.ALPHA
 	}
+	return gl.RGBA
+}
 
-	glctx.Enable(gl.TEXTURE_2D)
-	glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+// ddsPixels converts one image of dds data into pixels with the format
+// returned by ddsPixelFormat.  If dxt is non-zero the data is decompressed.
+func ddsPixels(header *dds.Header, f dds.Format, dxt s3tc.Format, b []byte, width, height int) ([]byte, error) {
+	if width*height*4 > maxDDSSize {
+		return nil, fmt.Errorf("image is too large to convert: %dx%d", width, height)
+	}
+	if dxt != 0 {
+		img, err := s3tc.Decode(dxt, b, width, height)
+		if err != nil {
+			return nil, err
+		}
+		return img.Pix, nil
+	}
 
-	return texture, nil
+	img, err := header.DecodeImage(b, width, height)
+	if err != nil {
+		return nil, err
+	}
+	switch img := img.(type) {
+	case *image.Gray:
+		return img.Pix, nil
+	case *image.Alpha:
+		return img.Pix, nil
+	case *image.NRGBA:
+		if f == dds.RGB {
+			pix, _, _ := imagePixels(img)
+			return pix, nil
+		}
+		return img.Pix, nil
+	}
+	return nil, fmt.Errorf("unexpected %v image: %T", f, img)
 }
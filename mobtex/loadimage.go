@@ -0,0 +1,255 @@
+package mobtex
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	// register the standard image formats for LoadPath
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/gl"
+)
+
+// LoadImagePath decodes an image asset at path in any format registered with
+// the image package and loads it into the given gl.Context.
+func LoadImagePath(glctx gl.Context, path string, opts *TextureOptions) (*Texture, error) {
+	f, err := asset.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return LoadImage(glctx, img, opts)
+}
+
+// LoadImage loads img into the given gl.Context and returns the resulting
+// texture.  Grayscale images are uploaded as LUMINANCE, opaque images as RGB,
+// and all other images as RGBA with non-premultiplied alpha unless
+// opts.PremultiplyAlpha is set.  Images are stored top-down so the texture has
+// the top-left Origin unless opts.FlipY is set.
+func LoadImage(glctx gl.Context, img image.Image, opts *TextureOptions) (*Texture, error) {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("empty image")
+	}
+	pix, format, _ := imagePixels(img)
+	return uploadPixels(glctx, b.Dx(), b.Dy(), format, pix, OriginTopLeft, opts)
+}
+
+// imagePixels converts img to tightly packed rows of unsigned bytes, top row
+// first, in a format accepted by TexImage2D.  Common concrete image types are
+// converted directly from their backing arrays.
+func imagePixels(img image.Image) (pix []byte, format gl.Enum, channels int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	switch img := img.(type) {
+	case *image.Gray:
+		pix = make([]byte, w*h)
+		for y := 0; y < h; y++ {
+			i := img.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(pix[y*w:], img.Pix[i:i+w])
+		}
+		return pix, gl.LUMINANCE, 1
+	case *image.Gray16:
+		pix = make([]byte, w*h)
+		for y := 0; y < h; y++ {
+			i := img.PixOffset(b.Min.X, b.Min.Y+y)
+			for x := 0; x < w; x++ {
+				pix[y*w+x] = img.Pix[i+2*x]
+			}
+		}
+		return pix, gl.LUMINANCE, 1
+	case *image.YCbCr:
+		pix = make([]byte, w*h*3)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				yi := img.YOffset(b.Min.X+x, b.Min.Y+y)
+				ci := img.COffset(b.Min.X+x, b.Min.Y+y)
+				j := (y*w + x) * 3
+				pix[j], pix[j+1], pix[j+2] = color.YCbCrToRGB(img.Y[yi], img.Cb[ci], img.Cr[ci])
+			}
+		}
+		return pix, gl.RGB, 3
+	case *image.Paletted:
+		return palettedPixels(img)
+	case *image.NRGBA:
+		if img.Opaque() {
+			return rgbPixels(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), w, h, 1)
+		}
+		pix = make([]byte, w*h*4)
+		for y := 0; y < h; y++ {
+			i := img.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(pix[y*w*4:], img.Pix[i:i+w*4])
+		}
+		return pix, gl.RGBA, 4
+	case *image.NRGBA64:
+		if img.Opaque() {
+			return rgbPixels(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), w, h, 2)
+		}
+		pix = make([]byte, w*h*4)
+		for y := 0; y < h; y++ {
+			i := img.PixOffset(b.Min.X, b.Min.Y+y)
+			for x := 0; x < w*4; x++ {
+				pix[y*w*4+x] = img.Pix[i+2*x]
+			}
+		}
+		return pix, gl.RGBA, 4
+	case *image.RGBA:
+		if img.Opaque() {
+			return rgbPixels(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), w, h, 1)
+		}
+		pix = make([]byte, w*h*4)
+		for y := 0; y < h; y++ {
+			i := img.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(pix[y*w*4:], img.Pix[i:i+w*4])
+		}
+		unpremultiply(pix)
+		return pix, gl.RGBA, 4
+	case *image.RGBA64:
+		if img.Opaque() {
+			return rgbPixels(img.Pix, img.Stride, img.PixOffset(b.Min.X, b.Min.Y), w, h, 2)
+		}
+		// unpremultiply at full precision before discarding the low bytes.
+		pix = make([]byte, w*h*4)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := img.RGBA64At(b.Min.X+x, b.Min.Y+y)
+				j := (y*w + x) * 4
+				pix[j+3] = uint8(c.A >> 8)
+				if c.A == 0 {
+					continue
+				}
+				pix[j] = unpremultiply16(c.R, c.A)
+				pix[j+1] = unpremultiply16(c.G, c.A)
+				pix[j+2] = unpremultiply16(c.B, c.A)
+			}
+		}
+		return pix, gl.RGBA, 4
+	}
+
+	// other image types are converted through the generic draw path.
+	nrgba := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	return imagePixels(nrgba)
+}
+
+// pixelsImage returns tightly packed, unsigned byte pixel data in the given
+// format as an image.  It is the inverse of imagePixels.
+func pixelsImage(width, height int, format gl.Enum, pix []byte) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	channels := len(pix) / (width * height)
+	for i := 0; i < width*height; i++ {
+		src := pix[i*channels : i*channels+channels]
+		dst := img.Pix[i*4 : i*4+4]
+		switch format {
+		case gl.ALPHA:
+			dst[3] = src[0]
+		case gl.LUMINANCE, gl.LUMINANCE_ALPHA:
+			dst[0], dst[1], dst[2], dst[3] = src[0], src[0], src[0], 0xff
+			if channels == 2 {
+				dst[3] = src[1]
+			}
+		default:
+			dst[3] = 0xff
+			copy(dst, src)
+		}
+	}
+	return img
+}
+
+// rgbPixels copies the color channels from 4-channel pixel data with the
+// given component size in bytes, dropping alpha.  Only the most significant
+// byte of each component is kept.
+func rgbPixels(src []byte, stride, offset, w, h, size int) ([]byte, gl.Enum, int) {
+	pix := make([]byte, w*h*3)
+	for y := 0; y < h; y++ {
+		row := src[offset+y*stride:]
+		for x := 0; x < w; x++ {
+			j := (y*w + x) * 3
+			i := x * 4 * size
+			pix[j], pix[j+1], pix[j+2] = row[i], row[i+size], row[i+2*size]
+		}
+	}
+	return pix, gl.RGB, 3
+}
+
+// palettedPixels expands the palette indices of img.  The palette is converted
+// once, up front, rather than for each pixel.
+func palettedPixels(img *image.Paletted) ([]byte, gl.Enum, int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	var lut [256][4]byte
+	channels := 3
+	for i, c := range img.Palette {
+		if i >= len(lut) {
+			break
+		}
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		lut[i] = [4]byte{nc.R, nc.G, nc.B, nc.A}
+		if nc.A != 0xff {
+			channels = 4
+		}
+	}
+	format := gl.Enum(gl.RGB)
+	if channels == 4 {
+		format = gl.RGBA
+	}
+
+	pix := make([]byte, w*h*channels)
+	for y := 0; y < h; y++ {
+		i := img.PixOffset(b.Min.X, b.Min.Y+y)
+		for x, index := range img.Pix[i : i+w] {
+			copy(pix[(y*w+x)*channels:], lut[index][:channels])
+		}
+	}
+	return pix, format, channels
+}
+
+// unpremultiply converts RGBA pixel data from premultiplied to straight alpha
+// in place.
+func unpremultiply(pix []byte) {
+	for i := 0; i+3 < len(pix); i += 4 {
+		a := uint32(pix[i+3])
+		if a == 0 || a == 0xff {
+			continue
+		}
+		for j := i; j < i+3; j++ {
+			c := uint32(pix[j]) * 0xff / a
+			if c > 0xff {
+				c = 0xff
+			}
+			pix[j] = uint8(c)
+		}
+	}
+}
+
+// unpremultiply16 returns the most significant byte of the straight alpha
+// value of the premultiplied component c.
+func unpremultiply16(c, a uint16) uint8 {
+	v := uint32(c) * 0xffff / uint32(a)
+	if v > 0xffff {
+		v = 0xffff
+	}
+	return uint8(v >> 8)
+}
+
+// flipRows reverses the order of the rows of length stride in pix.
+func flipRows(pix []byte, stride int) {
+	tmp := make([]byte, stride)
+	for i, j := 0, len(pix)-stride; i < j; i, j = i+stride, j-stride {
+		copy(tmp, pix[i:i+stride])
+		copy(pix[i:i+stride], pix[j:j+stride])
+		copy(pix[j:j+stride], tmp)
+	}
+}
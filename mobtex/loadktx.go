@@ -1,81 +1,292 @@
 package mobtex
 
 import (
+	"bufio"
 	"fmt"
+	"image"
+	"io"
 	"log"
 
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/etc"
 	"github.com/bmatsuo/mobile-gl-tutorial/texture/ktx"
 	"golang.org/x/mobile/asset"
 	"golang.org/x/mobile/gl"
 )
 
 // LoadKTX loads a KTX asset at path into the given gl.Context and returns the
-// gl.Texture identifier for the resulting texture.
-func LoadKTX(glctx gl.Context, path string) (gl.Texture, error) {
+// resulting texture.  Both KTX 1.1 and KTX 2.0 files are supported, the
+// version is determined from the file identifier.  Six-face KTX files are
+// loaded as cubemaps and must be bound to the TEXTURE_CUBE_MAP target rather
+// than TEXTURE_2D.  Files with a non-zero GLType contain uncompressed pixels
+// which are uploaded using GLFormat and GLType, other files are uploaded as
+// compressed data with GLInternalFormat.  ETC1, ETC2, and unsigned EAC data
+// is decompressed in software and uploaded as uncompressed pixels when the
+// device does not support its format.
+//
+// The Origin of the texture is taken from the KTXorientation metadata.  Files
+// without orientation metadata are assumed to have the top-left origin
+// recommended by the specification.  The texture is prepared and configured
+// according to opts, which may be nil.
+func LoadKTX(glctx gl.Context, path string, opts *TextureOptions) (*Texture, error) {
 	f, err := asset.Open(path)
 	if err != nil {
-		return gl.Texture{}, err
+		return nil, err
 	}
 	defer f.Close()
 
-	prevUnpackAlignment := glctx.GetInteger(gl.UNPACK_ALIGNMENT)
-	if prevUnpackAlignment != 4 {
-		glctx.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
-		defer glctx.PixelStorei(gl.UNPACK_ALIGNMENT, int32(prevUnpackAlignment))
+	r := bufio.NewReader(f)
+	id, err := r.Peek(12)
+	if err != nil {
+		return nil, err
+	}
+	if ktx.IsKTX2(id) {
+		return loadKTX2(glctx, r, opts)
 	}
 
-	header, metadata, data, err := ktx.Read(f)
+	header, metadata, data, err := ktx.Read(r)
 	if err != nil {
-		return gl.Texture{}, err
+		return nil, err
 	}
 	log.Printf("%#v", header)
 
-	metamap, err := ktx.DecodeMetadata(header, metadata)
+	meta, err := ktx.ParseMetadata(header, metadata)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range meta {
+		log.Printf("%s=%q", kv.Key, kv.Value)
+	}
+	origin, err := ktxOrigin(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadKTX(glctx, header, data, origin, opts)
+}
+
+// loadKTX2 loads a KTX 2.0 byte stream from r.  The KTX 2.0 header is
+// translated into an equivalent KTX 1.1 header so that the same upload code
+// can be used for either version.
+func loadKTX2(glctx gl.Context, r io.Reader, opts *TextureOptions) (*Texture, error) {
+	k, data, err := ktx.ReadKTX2(r)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%#v", k.KTX2Header)
+
+	header, err := k.GLHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := ktx.ParseMetadata(header, k.Meta)
 	if err != nil {
-		return gl.Texture{}, err
+		return nil, err
+	}
+	for _, kv := range meta {
+		log.Printf("%s=%q", kv.Key, kv.Value)
+	}
+	origin, err := ktxOrigin(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	for level := range data {
+		data[level], err = k.Decompress(level, data[level])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return uploadKTX(glctx, header, data, origin, opts)
+}
+
+// ktxOrigin determines the Origin of a texture from its KTXorientation
+// metadata.
+func ktxOrigin(meta ktx.Metadata) (Origin, error) {
+	o, ok, err := meta.Orientation()
+	if err != nil {
+		return 0, err
+	}
+	if ok && o.T == ktx.Up {
+		return OriginBottomLeft, nil
+	}
+	return OriginTopLeft, nil
+}
+
+// uploadKTX creates a texture in glctx from decoded KTX mipmap data whose first
+// row lies at origin.  Cubemap data is uploaded to a TEXTURE_CUBE_MAP texture,
+// which the caller must bind to the TEXTURE_CUBE_MAP target when rendering.
+func uploadKTX(glctx gl.Context, header *ktx.Header, data [][]byte, origin Origin, opts *TextureOptions) (*Texture, error) {
+	opts = opts.orDefault()
+	if header.NumberOfArrayElements > 0 {
+		return nil, fmt.Errorf("array textures are not supported")
 	}
-	for k, vs := range metamap {
-		for _, v := range vs {
-			log.Printf("%s=%q", k, v)
+	if header.PixelDepth > 0 {
+		return nil, fmt.Errorf("3D textures are not supported")
+	}
+	target := gl.Enum(gl.TEXTURE_2D)
+	faceTarget := gl.Enum(gl.TEXTURE_2D)
+	if header.IsCubemap() {
+		target = gl.TEXTURE_CUBE_MAP
+		faceTarget = gl.TEXTURE_CUBE_MAP_POSITIVE_X
+	}
+
+	// a zero GLType indicates compressed data
+	compressed := header.GLType == 0
+
+	internalFormat := opts.srgbCompressedFormat(glctx, gl.Enum(header.GLInternalFormat))
+	format := gl.Enum(header.GLFormat)
+	bytePixels := header.GLType == gl.UNSIGNED_BYTE
+
+	// etc data is decompressed into bytes when the device cannot sample it
+	var etcFormat etc.Format
+	if f, srgb, ok := etc.FormatFromGL(header.GLInternalFormat); ok && compressed && !hasCompressedFormat(glctx, internalFormat) {
+		if f.Signed() {
+			return nil, fmt.Errorf("%v is not supported and cannot be decompressed", f)
+		}
+		log.Printf("%v is not supported, decompressing in software", f)
+		etcFormat = f
+		compressed = false
+		bytePixels = true
+		format = etcPixelFormat(f)
+		if srgb {
+			srgbOpts := *opts
+			srgbOpts.SRGB = true
+			opts = &srgbOpts
 		}
 	}
+	if bytePixels {
+		format = opts.srgbFormat(glctx, format)
+	}
+	premultiplied := opts.PremultiplyAlpha && bytePixels &&
+		(header.GLFormat == gl.RGBA || header.GLFormat == gl.LUMINANCE_ALPHA || etcFormat.HasAlpha())
+
+	// rows of uncompressed ktx data are padded to 4-byte boundaries but rows
+	// of decompressed etc data are not padded
+	align := 4
+	if etcFormat != 0 {
+		align = 1
+	}
+	defer setUnpackAlignment(glctx, align)()
 
 	log.Printf("%d levels of texture", len(data))
 
 	texture := glctx.CreateTexture()
-	glctx.BindTexture(gl.TEXTURE_2D, texture)
+	glctx.BindTexture(target, texture)
+
+	flipped := opts.FlipY && !compressed && !header.IsCubemap()
+	if flipped {
+		origin = origin.flip()
+	}
 
-	width := int(header.PixelWidth)
-	height := int(header.PixelHeight)
+	// one-dimensional textures are uploaded as a single row
+	baseWidth, baseHeight := int(header.PixelWidth), int(header.PixelHeight)
+	if baseHeight == 0 {
+		baseHeight = 1
+	}
+	pixelType := gl.Enum(header.GLType)
+	if etcFormat != 0 {
+		pixelType = gl.UNSIGNED_BYTE
+	}
+	width, height := baseWidth, baseHeight
 	for level, mipdata := range data {
-		log.Printf("LEVEL=%d WIDTH=%d HEIGHT=%d LEN=%d SIZE=%d",
-			level, width, height, len(mipdata), ((width+3)/4)*((height+3)/4)*8)
-		glctx.CompressedTexImage2D(gl.TEXTURE_2D, level, gl.Enum(header.GLInternalFormat), width, height, 0, mipdata)
-		glerr := glctx.GetError()
-		if glerr == gl.INVALID_ENUM {
-			formats := make([]int32, 32)
-			glctx.GetIntegerv(formats, gl.COMPRESSED_TEXTURE_FORMATS)
-			for i := range formats {
-				if formats[i] == 0 {
-					formats = formats[:i]
-					break
+		if header.GLType != 0 {
+			err := ktx.ConvertEndianness(header, mipdata, ktx.NativeEndianness)
+			if err != nil {
+				return nil, err
+			}
+		}
+		images, err := header.Images(mipdata)
+		if err != nil {
+			return nil, err
+		}
+		for face, img := range images {
+			log.Printf("LEVEL=%d FACE=%d WIDTH=%d HEIGHT=%d LEN=%d",
+				level, face, width, height, len(img))
+			if etcFormat != 0 {
+				img, err = decodeETC(etcFormat, img, width, height)
+				if err != nil {
+					return nil, err
 				}
 			}
-			log.Printf("AVAILABLE COMPRESSED TEXTURE FORMATS: %x", formats)
-			return gl.Texture{}, fmt.Errorf("invalid compressed texture format: %x", header.GLInternalFormat)
-		} else if glerr != 0 {
-			return gl.Texture{}, fmt.Errorf("GL ERROR: %x", glerr)
+			if premultiplied {
+				channels := 4
+				if header.GLFormat == gl.LUMINANCE_ALPHA {
+					channels = 2
+				}
+				premultiply(img, channels)
+			}
+			if flipped {
+				flipRows(img, len(img)/height)
+			}
+			if compressed {
+				glctx.CompressedTexImage2D(faceTarget+gl.Enum(face), level, internalFormat, width, height, 0, img)
+			} else {
+				glctx.TexImage2D(faceTarget+gl.Enum(face), level, width, height, format, pixelType, img)
+			}
+			glerr := glctx.GetError()
+			if glerr == gl.INVALID_ENUM && compressed {
+				log.Printf("AVAILABLE COMPRESSED TEXTURE FORMATS: %s", compressedFormatNames(glctx))
+				return nil, fmt.Errorf("compressed texture format is not supported by the device: %s", ktx.FormatName(uint32(internalFormat)))
+			} else if glerr == gl.INVALID_ENUM {
+				return nil, fmt.Errorf("invalid texture format: format=%x type=%x", format, pixelType)
+			} else if glerr != 0 {
+				return nil, fmt.Errorf("GL ERROR: %x", glerr)
+			}
+		}
+		if width > 1 {
+			width /= 2
+		}
+		if height > 1 {
+			height /= 2
 		}
-		width /= 2
-		height /= 2
 	}
 
-	//glctx.Enable(gl.TEXTURE_2D)
+	// textures without a complete mipmap chain, such as those with a zero
+	// NumberOfMipmapLevels, have mipmaps generated if they are uncompressed.
+	levels := opts.finish(glctx, target, baseWidth, baseHeight, len(data), compressed)
 
-	//glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.REPEAT)
-	//glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.REPEAT)
-	glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	glctx.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR_MIPMAP_LINEAR)
+	if !compressed {
+		internalFormat = format
+	}
+	return &Texture{
+		Texture:    texture,
+		Target:     target,
+		Width:      baseWidth,
+		Height:     baseHeight,
+		Levels:     levels,
+		Format:     internalFormat,
+		Compressed: compressed,
+		Alpha:      formatHasAlpha(internalFormat),
+		Origin:     origin,
+		glctx:      glctx,
+	}, nil
+}
+
+// etcPixelFormat returns the format of pixels decompressed from f data.  GL ES
+// 2 has no single channel format so R11 data is uploaded as LUMINANCE.
+func etcPixelFormat(f etc.Format) gl.Enum {
+	switch {
+	case f.HasAlpha():
+		return gl.RGBA
+	case f == etc.R11:
+		return gl.LUMINANCE
+	}
+	return gl.RGB
+}
 
-	return texture, nil
+// decodeETC decompresses an image of f data into pixels with the format
+// returned by etcPixelFormat.
+func decodeETC(f etc.Format, b []byte, width, height int) ([]byte, error) {
+	img, err := etc.Decode(f, b, width, height)
+	if err != nil {
+		return nil, err
+	}
+	if f.HasAlpha() {
+		// imagePixels would drop the alpha channel of opaque levels
+		return img.(*image.NRGBA).Pix, nil
+	}
+	pix, _, _ := imagePixels(img)
+	return pix, nil
 }
@@ -0,0 +1,359 @@
+package mobtex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/exp/f32"
+	"golang.org/x/mobile/gl"
+)
+
+// Material is a material defined in an MTL material library.
+type Material struct {
+	Name string
+
+	// Ambient, Diffuse, and Specular are the Ka, Kd, and Ks reflectivity
+	// colors.
+	Ambient  f32.Vec3
+	Diffuse  f32.Vec3
+	Specular f32.Vec3
+
+	// Shininess is the Ns specular exponent.
+	Shininess float32
+
+	// OpticalDensity is the Ni index of refraction.
+	OpticalDensity float32
+
+	// Dissolve is the d opacity of the material, 1 unless the library sets
+	// d or its complement Tr.
+	Dissolve float32
+
+	// Illum is the illumination model.
+	Illum int
+
+	// DiffuseMap, SpecularMap, BumpMap, NormalMap, and AlphaMap are the
+	// map_Kd, map_Ks, map_Bump (or bump), norm, and map_d texture maps.
+	// Maps not given by the library are nil.
+	DiffuseMap  *TextureMap
+	SpecularMap *TextureMap
+	BumpMap     *TextureMap
+	NormalMap   *TextureMap
+	AlphaMap    *TextureMap
+}
+
+// TextureMap is a texture map statement of a Material and its options.
+type TextureMap struct {
+	// Path is the path of the texture asset.  Libraries decoded with
+	// DecodeMtlPath have paths relative to the asset directory, otherwise
+	// the path is as written.
+	Path string
+
+	// BlendU and BlendV are the -blendu and -blendv options, true unless
+	// turned off.
+	BlendU bool
+	BlendV bool
+
+	// ColorCorrection is the -cc option.
+	ColorCorrection bool
+
+	// Clamp is the -clamp option.  Clamped maps are loaded with
+	// CLAMP_TO_EDGE wrapping.
+	Clamp bool
+
+	// Base and Gain are the -mm options, 0 and 1 unless given.
+	Base float32
+	Gain float32
+
+	// Offset, Scale, and Turbulence are the -o, -s, and -t options.  Scale
+	// is 1, 1, 1 unless given.
+	Offset     f32.Vec3
+	Scale      f32.Vec3
+	Turbulence f32.Vec3
+
+	// Resolution is the -texres option, zero unless given.
+	Resolution int
+
+	// BumpMultiplier is the -bm option, 1 unless given.
+	BumpMultiplier float32
+
+	// Boost is the -boost option.
+	Boost float32
+
+	// Channel is the -imfchan option, one of "r", "g", "b", "m", "l", or
+	// "z", empty unless given.
+	Channel string
+}
+
+// Load loads the texture map into glctx with LoadPath, according to opts,
+// which may be nil.  MTL files often name images which are not shipped with
+// an application, so if the asset at m.Path does not exist the texture is
+// loaded from m.Path without its extension, the best variant available on
+// the device.
+func (m *TextureMap) Load(glctx gl.Context, opts *TextureOptions) (*Texture, error) {
+	if m.Clamp {
+		clampOpts := *opts.orDefault()
+		if clampOpts.WrapS == 0 {
+			clampOpts.WrapS = gl.CLAMP_TO_EDGE
+		}
+		if clampOpts.WrapT == 0 {
+			clampOpts.WrapT = gl.CLAMP_TO_EDGE
+		}
+		opts = &clampOpts
+	}
+	p := m.Path
+	if f, err := asset.Open(p); err == nil {
+		f.Close()
+	} else if ext := path.Ext(p); ext != "" {
+		p = strings.TrimSuffix(p, ext)
+	}
+	return LoadPath(glctx, p, opts)
+}
+
+// DecodeMtlPath loads a material library asset at path using DecodeMtl.  The
+// paths of texture maps are resolved relative to the directory of the
+// library.
+func DecodeMtlPath(p string) (map[string]*Material, error) {
+	f, err := asset.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeMtl(f, path.Dir(p))
+}
+
+// DecodeMtl loads a material library (MTL) byte stream from r and returns its
+// materials by name.  Errors report the line of the stream they occur on.
+func DecodeMtl(r io.Reader) (map[string]*Material, error) {
+	return decodeMtl(r, "")
+}
+
+func decodeMtl(r io.Reader, dir string) (map[string]*Material, error) {
+	materials := make(map[string]*Material)
+	var m *Material
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		b := s.Bytes()
+		if i := bytes.IndexByte(b, '#'); i >= 0 {
+			b = b[:i]
+		}
+		fields := bytes.Fields(b)
+		if len(fields) == 0 {
+			continue
+		}
+		head, args := string(fields[0]), fields[1:]
+		if head == "newmtl" {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("line %d: material without a name", line)
+			}
+			m = &Material{Name: joinFields(args), Dissolve: 1}
+			materials[m.Name] = m
+			continue
+		}
+		if m == nil {
+			return nil, fmt.Errorf("line %d: %s before newmtl", line, head)
+		}
+		err := m.decodeStatement(head, args, dir)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+	return materials, nil
+}
+
+// decodeStatement decodes a statement of the material.  Statements which are
+// not supported are ignored.
+func (m *Material) decodeStatement(head string, args [][]byte, dir string) error {
+	var err error
+	switch head {
+	case "Ka":
+		m.Ambient, err = parseColor(args)
+	case "Kd":
+		m.Diffuse, err = parseColor(args)
+	case "Ks":
+		m.Specular, err = parseColor(args)
+	case "Ns":
+		m.Shininess, err = parseScalar(args)
+	case "Ni":
+		m.OpticalDensity, err = parseScalar(args)
+	case "d":
+		if len(args) > 0 && string(args[0]) == "-halo" {
+			args = args[1:]
+		}
+		m.Dissolve, err = parseScalar(args)
+	case "Tr":
+		var tr float32
+		tr, err = parseScalar(args)
+		m.Dissolve = 1 - tr
+	case "illum":
+		if len(args) != 1 {
+			return fmt.Errorf("invalid illum")
+		}
+		m.Illum, err = strconv.Atoi(string(args[0]))
+	case "map_Kd":
+		m.DiffuseMap, err = parseTextureMap(args, dir)
+	case "map_Ks":
+		m.SpecularMap, err = parseTextureMap(args, dir)
+	case "map_Bump", "map_bump", "bump":
+		m.BumpMap, err = parseTextureMap(args, dir)
+	case "norm":
+		m.NormalMap, err = parseTextureMap(args, dir)
+	case "map_d":
+		m.AlphaMap, err = parseTextureMap(args, dir)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", head, err)
+	}
+	return nil
+}
+
+// parseColor parses an RGB color.  A single value is used for all three
+// components and spectral and CIEXYZ colors are not supported.
+func parseColor(args [][]byte) (f32.Vec3, error) {
+	var c f32.Vec3
+	if len(args) == 1 {
+		x, err := parseFloat32(args[0])
+		return f32.Vec3{x, x, x}, err
+	}
+	if len(args) != 3 {
+		return c, fmt.Errorf("expected r g b components: %q", args)
+	}
+	v, err := parseVec(args)
+	if err != nil {
+		return c, err
+	}
+	return f32.Vec3{v[0], v[1], v[2]}, nil
+}
+
+func parseScalar(args [][]byte) (float32, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected one value: %q", args)
+	}
+	return parseFloat32(args[0])
+}
+
+// parseTextureMap parses the options and filename of a texture map
+// statement.  The filename is joined to dir.
+func parseTextureMap(args [][]byte, dir string) (*TextureMap, error) {
+	m := &TextureMap{
+		BlendU:         true,
+		BlendV:         true,
+		Gain:           1,
+		Scale:          f32.Vec3{1, 1, 1},
+		BumpMultiplier: 1,
+	}
+	var err error
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		opt := string(args[0])
+		args = args[1:]
+		switch opt {
+		case "-blendu":
+			m.BlendU, args, err = parseOnOff(opt, args)
+		case "-blendv":
+			m.BlendV, args, err = parseOnOff(opt, args)
+		case "-cc":
+			m.ColorCorrection, args, err = parseOnOff(opt, args)
+		case "-clamp":
+			m.Clamp, args, err = parseOnOff(opt, args)
+		case "-mm":
+			if len(args) < 2 {
+				return nil, fmt.Errorf("%s expects base and gain", opt)
+			}
+			var v [3]float32
+			v, err = parseVec(args[:2])
+			m.Base, m.Gain, args = v[0], v[1], args[2:]
+		case "-o":
+			m.Offset, args, err = parseOptionVec(opt, args, m.Offset)
+		case "-s":
+			m.Scale, args, err = parseOptionVec(opt, args, m.Scale)
+		case "-t":
+			m.Turbulence, args, err = parseOptionVec(opt, args, m.Turbulence)
+		case "-texres":
+			if len(args) < 1 {
+				return nil, fmt.Errorf("%s expects a value", opt)
+			}
+			m.Resolution, err = strconv.Atoi(string(args[0]))
+			args = args[1:]
+		case "-bm", "-boost":
+			if len(args) < 1 {
+				return nil, fmt.Errorf("%s expects a value", opt)
+			}
+			var x float32
+			x, err = parseFloat32(args[0])
+			if opt == "-bm" {
+				m.BumpMultiplier = x
+			} else {
+				m.Boost = x
+			}
+			args = args[1:]
+		case "-imfchan":
+			if len(args) < 1 || len(args[0]) != 1 || !strings.Contains("rgbmlz", string(args[0])) {
+				return nil, fmt.Errorf("%s expects one of r, g, b, m, l, or z", opt)
+			}
+			m.Channel, args = string(args[0]), args[1:]
+		case "-type":
+			// reflection map types do not apply to the supported maps.
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		default:
+			return nil, fmt.Errorf("unknown option %s", opt)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing filename")
+	}
+	// files written on windows may separate directories with backslashes.
+	m.Path = path.Join(dir, strings.Replace(joinFields(args), `\`, "/", -1))
+	return m, nil
+}
+
+// parseOnOff parses the on or off argument of option opt.
+func parseOnOff(opt string, args [][]byte) (bool, [][]byte, error) {
+	if len(args) > 0 {
+		switch string(args[0]) {
+		case "on":
+			return true, args[1:], nil
+		case "off":
+			return false, args[1:], nil
+		}
+	}
+	return false, args, fmt.Errorf("%s expects on or off", opt)
+}
+
+// parseOptionVec parses the one to three numeric arguments of option opt.
+// Components which are not given keep their value in v.
+func parseOptionVec(opt string, args [][]byte, v f32.Vec3) (f32.Vec3, [][]byte, error) {
+	n := 0
+	for ; n < 3 && n < len(args); n++ {
+		x, err := strconv.ParseFloat(*(*string)(unsafe.Pointer(&args[n])), 32)
+		if err != nil {
+			break
+		}
+		v[n] = float32(x)
+	}
+	if n == 0 {
+		return v, args, fmt.Errorf("%s expects a value", opt)
+	}
+	return v, args[n:], nil
+}
+
+// joinFields joins fields separated by single spaces, recovering names which
+// contain spaces.
+func joinFields(fields [][]byte) string {
+	return string(bytes.Join(fields, []byte(" ")))
+}
@@ -0,0 +1,274 @@
+package mobtex
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mobile/exp/f32"
+)
+
+func TestDecodeMtl(t *testing.T) {
+	materials, err := DecodeMtl(strings.NewReader(`# two materials
+newmtl shiny red
+	Ka 0.1 0.2 0.3
+Kd 1 0 0 # a comment
+Ks 0.5
+Ns 96.078431
+Ni 1.45
+d 0.25
+illum 2
+unknown statements are ignored
+
+newmtl glass
+Tr 0.75
+d -halo 0.5
+Tr 0.125
+illum 4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*Material{
+		"shiny red": {
+			Name:           "shiny red",
+			Ambient:        f32.Vec3{0.1, 0.2, 0.3},
+			Diffuse:        f32.Vec3{1, 0, 0},
+			Specular:       f32.Vec3{0.5, 0.5, 0.5},
+			Shininess:      96.078431,
+			OpticalDensity: 1.45,
+			Dissolve:       0.25,
+			Illum:          2,
+		},
+		"glass": {
+			Name:     "glass",
+			Dissolve: 0.875,
+			Illum:    4,
+		},
+	}
+	if !reflect.DeepEqual(materials, want) {
+		for name, m := range materials {
+			t.Errorf("%s: %+v", name, m)
+		}
+	}
+}
+
+// defaultTextureMap returns a TextureMap for path with no options given.
+func defaultTextureMap(path string) *TextureMap {
+	return &TextureMap{
+		Path:           path,
+		BlendU:         true,
+		BlendV:         true,
+		Gain:           1,
+		Scale:          f32.Vec3{1, 1, 1},
+		BumpMultiplier: 1,
+	}
+}
+
+func TestParseTextureMap(t *testing.T) {
+	for _, test := range []struct {
+		args string
+		dir  string
+		want func(m *TextureMap)
+	}{
+		{"diffuse.tga", "", func(m *TextureMap) {}},
+		{"diffuse.tga", "models", func(m *TextureMap) { m.Path = "models/diffuse.tga" }},
+		{`textures\my diffuse.tga`, "models", func(m *TextureMap) { m.Path = "models/textures/my diffuse.tga" }},
+		{"-o 0.5 diffuse.tga", "", func(m *TextureMap) { m.Offset = f32.Vec3{0.5, 0, 0} }},
+		{"-o 0.5 0.25 0.125 diffuse.tga", "", func(m *TextureMap) { m.Offset = f32.Vec3{0.5, 0.25, 0.125} }},
+		{"-s 2 4 diffuse.tga", "", func(m *TextureMap) { m.Scale = f32.Vec3{2, 4, 1} }},
+		{"-t 1 1 1 diffuse.tga", "", func(m *TextureMap) { m.Turbulence = f32.Vec3{1, 1, 1} }},
+		{"-bm 0.5 diffuse.tga", "", func(m *TextureMap) { m.BumpMultiplier = 0.5 }},
+		{"-boost 2 diffuse.tga", "", func(m *TextureMap) { m.Boost = 2 }},
+		{"-clamp on diffuse.tga", "", func(m *TextureMap) { m.Clamp = true }},
+		{"-clamp off diffuse.tga", "", func(m *TextureMap) {}},
+		{"-blendu off -blendv off -cc on diffuse.tga", "", func(m *TextureMap) {
+			m.BlendU, m.BlendV, m.ColorCorrection = false, false, true
+		}},
+		{"-mm 0.25 2 diffuse.tga", "", func(m *TextureMap) { m.Base, m.Gain = 0.25, 2 }},
+		{"-texres 256 diffuse.tga", "", func(m *TextureMap) { m.Resolution = 256 }},
+		{"-imfchan l diffuse.tga", "", func(m *TextureMap) { m.Channel = "l" }},
+		{"-type sphere diffuse.tga", "", func(m *TextureMap) {}},
+		{"-clamp on -s 2 2 1 -o 0.5 0.5 -bm 3 diffuse.tga", "", func(m *TextureMap) {
+			m.Clamp = true
+			m.Scale = f32.Vec3{2, 2, 1}
+			m.Offset = f32.Vec3{0.5, 0.5, 0}
+			m.BumpMultiplier = 3
+		}},
+		// a lone dash is a filename rather than an option.
+		{"-", "", func(m *TextureMap) { m.Path = "-" }},
+	} {
+		m, err := parseTextureMap(bytes.Fields([]byte(test.args)), test.dir)
+		if err != nil {
+			t.Errorf("%q: %v", test.args, err)
+			continue
+		}
+		want := defaultTextureMap("diffuse.tga")
+		test.want(want)
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("%q: parsed %+v, expected %+v", test.args, m, want)
+		}
+	}
+}
+
+// malformedMtl maps the names of malformed material libraries to the library
+// and the line its error is expected on.
+var malformedMtl = map[string]struct {
+	s, line string
+}{
+	"unnamed material":  {"newmtl\n", "line 1"},
+	"before newmtl":     {"Kd 1 1 1\n", "line 1"},
+	"color components":  {"newmtl m\nKd 1 1\n", "line 2"},
+	"color value":       {"newmtl m\nKa 1 x 1\n", "line 2"},
+	"scalar values":     {"newmtl m\nNs 1 2\n", "line 2"},
+	"missing scalar":    {"newmtl m\nNi\n", "line 2"},
+	"dissolve":          {"newmtl m\nd -halo\n", "line 2"},
+	"transparency":      {"newmtl m\nTr opaque\n", "line 2"},
+	"illum":             {"newmtl m\nillum two\n", "line 2"},
+	"illum values":      {"newmtl m\nillum\n", "line 2"},
+	"missing filename":  {"newmtl m\nmap_Kd\n", "line 2"},
+	"options only":      {"newmtl m\nmap_Kd -clamp on\n", "line 2"},
+	"unknown option":    {"newmtl m\nmap_Ks -wrap on a.png\n", "line 2"},
+	"on or off":         {"newmtl m\nmap_d -clamp yes a.png\n", "line 2"},
+	"missing offset":    {"newmtl m\nnorm -o a.png\n", "line 2"},
+	"missing scale":     {"newmtl m\nmap_Bump -s\n", "line 2"},
+	"missing bm":        {"newmtl m\nbump -bm\n", "line 2"},
+	"bm value":          {"newmtl m\nbump -bm x a.png\n", "line 2"},
+	"mm values":         {"newmtl m\nmap_Kd -mm 1\n", "line 2"},
+	"texres value":      {"newmtl m\nmap_Kd -texres big a.png\n", "line 2"},
+	"imfchan channel":   {"newmtl m\nmap_Kd -imfchan q a.png\n", "line 2"},
+	"second material":   {"newmtl a\nKd 1 1 1\nnewmtl b\nKd 1\nKs\n", "line 5"},
+	"line after blanks": {"newmtl a\n\n# comment\n\nKd 1 1\n", "line 5"},
+}
+
+func TestDecodeMtlMalformed(t *testing.T) {
+	for name, test := range malformedMtl {
+		_, err := DecodeMtl(strings.NewReader(test.s))
+		if err == nil || !strings.Contains(err.Error(), test.line+":") {
+			t.Errorf("%s: error %v, expected one on %s", name, err, test.line)
+		}
+	}
+}
+
+func TestDecodeObjPathCylinder(t *testing.T) {
+	quietLog(t)
+	// assets with absolute paths are opened directly on desktop systems.
+	p, err := filepath.Abs(filepath.Join("..", "tutorial13", "assets", "cylinder.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := DecodeObjPath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*Material{
+		"NormalMapping_diffuse.tga": {
+			Name:           "NormalMapping_diffuse.tga",
+			Diffuse:        f32.Vec3{0.64, 0.64, 0.64},
+			Specular:       f32.Vec3{0.5, 0.5, 0.5},
+			Shininess:      96.078431,
+			OpticalDensity: 1,
+			Dissolve:       1,
+			Illum:          2,
+			DiffuseMap:     defaultTextureMap(filepath.Join(filepath.Dir(p), "diffuse.tga")),
+		},
+	}
+	if !reflect.DeepEqual(obj.Materials, want) {
+		for name, m := range obj.Materials {
+			t.Errorf("%s: %+v", name, m)
+		}
+	}
+	wantSubmeshes := []Submesh{{Material: "NormalMapping_diffuse.tga", First: 0, Count: 192}}
+	if !reflect.DeepEqual(obj.Submeshes, wantSubmeshes) {
+		t.Errorf("submeshes %+v, expected %+v", obj.Submeshes, wantSubmeshes)
+	}
+}
+
+// triangleObj is a triangle drawn once with each of the given materials.
+func triangleObj(mtllib string, materials ...string) string {
+	s := "v 0 0 0\nv 1 0 0\nv 0 1 0\nvn 0 0 1\n"
+	if mtllib != "" {
+		s = "mtllib " + mtllib + "\n" + s
+	}
+	for _, m := range materials {
+		s += "usemtl " + m + "\nf 1//1 2//1 3//1\n"
+	}
+	return s
+}
+
+func TestDecodeObjPathMaterials(t *testing.T) {
+	quietLog(t)
+	dir := t.TempDir()
+	write := func(name, s string) string {
+		p := filepath.Join(dir, name)
+		err := ioutil.WriteFile(p, []byte(s), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write("colors.mtl", "newmtl red\nKd 1 0 0\nnewmtl blue\nKd 0 0 1\nmap_Kd -clamp on blue.png\n")
+
+	obj, err := DecodeObjPath(write("split.obj", triangleObj("colors.mtl", "red", "red", "blue", "red")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantSubmeshes := []Submesh{
+		{Material: "red", First: 0, Count: 6},
+		{Material: "blue", First: 6, Count: 3},
+		{Material: "red", First: 9, Count: 3},
+	}
+	if !reflect.DeepEqual(obj.Submeshes, wantSubmeshes) {
+		t.Errorf("submeshes %+v, expected %+v", obj.Submeshes, wantSubmeshes)
+	}
+	if len(obj.Materials) != 2 || obj.Materials["red"].Diffuse != (f32.Vec3{1, 0, 0}) {
+		t.Errorf("materials %+v", obj.Materials)
+	} else if m := obj.Materials["blue"].DiffuseMap; m == nil || !m.Clamp || m.Path != filepath.Join(dir, "blue.png") {
+		t.Errorf("blue diffuse map %+v", m)
+	}
+
+	for _, test := range []struct {
+		name    string
+		obj     string
+		invalid bool
+	}{
+		{"unknown material", triangleObj("colors.mtl", "red", "green"), true},
+		{"no library", triangleObj("", "green"), false},
+		{"missing library", triangleObj("colors.mtl missing.mtl", "green"), false},
+		{"no usemtl", triangleObj("colors.mtl", ""), false},
+	} {
+		_, err := DecodeObjPath(write("test.obj", test.obj))
+		if test.invalid && (err == nil || !strings.Contains(err.Error(), "green")) {
+			t.Errorf("%s: error %v, expected one naming the material", test.name, err)
+		} else if !test.invalid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func FuzzDecodeMtl(f *testing.F) {
+	f.Add([]byte("newmtl a b\nKa 0.1 0.2 0.3\nKd 1\nKs 0.5 0.5 0.5\nNs 96\nNi 1.5\nd -halo 0.5\nTr 0.25\nillum 2\n"))
+	f.Add([]byte("newmtl m\nmap_Kd -clamp on -o 0.5 0.5 -s 2 2 1 -mm 0 1 diffuse.tga\nmap_Bump -bm 0.5 -imfchan l bump.png\nnorm -texres 256 n.png\nmap_d -blendu off a.png\n"))
+	for _, test := range malformedMtl {
+		f.Add([]byte(test.s))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		materials, err := DecodeMtl(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		for name, m := range materials {
+			if m.Name != name {
+				t.Fatalf("material %q is named %q", name, m.Name)
+			}
+			for _, tm := range []*TextureMap{m.DiffuseMap, m.SpecularMap, m.BumpMap, m.NormalMap, m.AlphaMap} {
+				if tm != nil && tm.Path == "" {
+					t.Fatalf("material %q has a texture map without a path", name)
+				}
+			}
+		}
+	})
+}
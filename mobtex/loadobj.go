@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"path"
 	"strconv"
 	"unsafe"
 
@@ -30,16 +31,25 @@ func IndexVBO(in *Obj) *VBO {
 			VT: in.VT[i],
 			VN: in.VN[i],
 		}
+		if in.T != nil {
+			packed.T = in.T[i]
+		}
 		index, ok := indexMap[packed]
 		if !ok {
 			index = len(vbo.V)
 			vbo.V = append(vbo.V, in.V[i])
 			vbo.VT = append(vbo.VT, in.VT[i])
 			vbo.VN = append(vbo.VN, in.VN[i])
+			if in.T != nil {
+				vbo.T = append(vbo.T, in.T[i])
+			}
 			indexMap[packed] = index
 		}
 		vbo.Index = append(vbo.Index, uint16(index))
 	}
+	// each vertex of in has one entry in the index, so the ranges of the
+	// model are unchanged.
+	vbo.Model = in.Model
 	log.Printf("VBO V=%d VT=%d VN=%d INDEX=%d", len(vbo.V), len(vbo.VT), len(vbo.VN), len(vbo.Index))
 	return vbo
 }
@@ -48,6 +58,7 @@ type packedVertex struct {
 	V  f32.Vec3
 	VT Vec2
 	VN f32.Vec3
+	T  f32.Vec4
 }
 
 // Obj contains the contents of an OBJ file.
@@ -55,148 +66,501 @@ type Obj struct {
 	V  []f32.Vec3
 	VT []Vec2
 	VN []f32.Vec3
+
+	// T holds the tangent of each vertex, with its handedness in the w
+	// component, so that the bitangent is w * cross(VN, T).  T is nil
+	// unless tangents are generated with GenerateTangents.
+	T []f32.Vec4
+
+	Model
+}
+
+// Model describes the parts of an Obj, the objects and groups its triangles
+// belong to and the materials they are drawn with.  Each part is a range of
+// vertices in the vertex arrays of the Obj, which is also its range in the
+// Index of a VBO built from the Obj.
+type Model struct {
+	// Objects lists the objects begun by o statements in the order they
+	// occur.  Faces preceding any o statement belong to an unnamed object.
+	Objects []*Object
+
+	// Submeshes divides the triangles of the Obj into runs of consecutive
+	// faces which use the same material.  A material may be used by more
+	// than one submesh.
+	Submeshes []Submesh
+
+	// MaterialLibs lists the files named by mtllib statements.
+	MaterialLibs []string
+
+	// Materials holds the materials of MaterialLibs by name.  It is only
+	// populated by DecodeObjPath.
+	Materials map[string]*Material
+}
+
+// Object is a named object of a Model.
+type Object struct {
+	Name string
+
+	// First is the index of the first vertex of the object and Count the
+	// number of vertices in it.
+	First int
+	Count int
+
+	// Groups divides the object into runs of consecutive faces with the
+	// same group names and smoothing group.
+	Groups []*Group
+}
+
+// Group is a run of faces of an Object following the same g and s
+// statements.
+type Group struct {
+	// Names are the names given by the g statement preceding the faces, or
+	// "default" if there is none.  A face may belong to several groups.
+	Names []string
+
+	// Smoothing is the smoothing group given by the s statement preceding
+	// the faces, or zero if smoothing is off.
+	Smoothing int
+
+	// First is the index of the first vertex of the group and Count the
+	// number of vertices in it.
+	First int
+	Count int
+}
+
+// HasName returns true if name is one of the names of g.
+func (g *Group) HasName(name string) bool {
+	for _, n := range g.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Object returns the first object of m with the given name, or nil if there
+// is none.
+func (m *Model) Object(name string) *Object {
+	for _, o := range m.Objects {
+		if o.Name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// Groups returns the groups of every object of m which have the given name.
+func (m *Model) Groups(name string) []*Group {
+	var groups []*Group
+	for _, o := range m.Objects {
+		for _, g := range o.Groups {
+			if g.HasName(name) {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+// ObjectAt returns the object containing the given vertex, or nil if there
+// is none.  Dividing the index of a picked triangle by three gives its first
+// vertex.
+func (m *Model) ObjectAt(vertex int) *Object {
+	for _, o := range m.Objects {
+		if vertex >= o.First && vertex < o.First+o.Count {
+			return o
+		}
+	}
+	return nil
+}
+
+// SubmeshesIn returns the parts of the submeshes of m within the range of
+// count vertices beginning at first, such as the range of an Object or Group,
+// so that the range can be drawn material by material.
+func (m *Model) SubmeshesIn(first, count int) []Submesh {
+	var submeshes []Submesh
+	for _, sm := range m.Submeshes {
+		lo, hi := sm.First, sm.First+sm.Count
+		if lo < first {
+			lo = first
+		}
+		if hi > first+count {
+			hi = first + count
+		}
+		if lo < hi {
+			submeshes = append(submeshes, Submesh{Material: sm.Material, First: lo, Count: hi - lo})
+		}
+	}
+	return submeshes
+}
+
+// Submesh is a range of the triangles of an Obj drawn with one material.
+type Submesh struct {
+	// Material names the material given by the usemtl statement preceding
+	// the faces of the submesh, or is empty if there is none.
+	Material string
+
+	// First is the index of the first vertex of the submesh and Count the
+	// number of vertices in it.
+	First int
+	Count int
+}
+
+// OrientUV adjusts the texture coordinates of obj, which follow the OBJ
+// convention of a bottom-left origin, for use with a texture having the given
+// origin.  OrientUV must only be called once for a given Obj.  Flipping the
+// texture coordinates reverses the handedness of any tangents.
+func (obj *Obj) OrientUV(origin Origin) {
+	if origin != OriginTopLeft {
+		return
+	}
+	for i := range obj.VT {
+		obj.VT[i][1] = 1 - obj.VT[i][1]
+	}
+	for i := range obj.T {
+		obj.T[i][3] = -obj.T[i][3]
+	}
 }
 
 // DecodeObjPath loads an object asset at path using the DecodeObj function as
-// a helper.
-func DecodeObjPath(path string) (*Obj, error) {
-	f, err := asset.Open(path)
+// a helper.  Material libraries named by the object are loaded from the
+// directory of path like DecodeMtlPath.  Libraries which do not exist are
+// skipped, as exporters commonly name libraries they did not write.  When
+// the object names libraries and all of them are loaded, a usemtl statement
+// naming a material none of them define is an error.
+func DecodeObjPath(p string) (*Obj, error) {
+	f, err := asset.Open(p)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	return DecodeObj(f)
+	obj, err := DecodeObj(f)
+	if err != nil {
+		return nil, err
+	}
+
+	obj.Materials = make(map[string]*Material)
+	skipped := false
+	for _, lib := range obj.MaterialLibs {
+		libPath := path.Join(path.Dir(p), lib)
+		lf, err := asset.Open(libPath)
+		if err != nil {
+			log.Printf("skipping material library: %v", err)
+			skipped = true
+			continue
+		}
+		materials, err := decodeMtl(lf, path.Dir(libPath))
+		lf.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", libPath, err)
+		}
+		for name, m := range materials {
+			obj.Materials[name] = m
+		}
+	}
+	if skipped || len(obj.MaterialLibs) == 0 {
+		return obj, nil
+	}
+	for _, sm := range obj.Submeshes {
+		if sm.Material != "" && obj.Materials[sm.Material] == nil {
+			return nil, fmt.Errorf("%s: usemtl %s: unknown material", p, sm.Material)
+		}
+	}
+	return obj, nil
 }
 
-// DecodeObj loads an object (OBJ) byte stream from r.
+// DecodeObj loads an object (OBJ) byte stream from r.  Polygonal faces are
+// triangulated, and face vertices may omit their texture coordinate or normal
+// index, as in "f 1 2 3" or "f 1//1 2//2 3//3".  Such vertices have a zero VT
+// and a normal generated like Obj.SmoothNormals with DefaultCreaseAngle, so
+// that models without normals can be lit.  Streams without s statements have
+// their missing normals smoothed as a single group rather than left flat.
+// Negative indices refer to elements relative to the end of those read so far.
+// The o, g, and s statements of the stream divide the triangles of the Obj
+// into Objects and their Groups.  Errors report the line of the stream they
+// occur on.
 func DecodeObj(r io.Reader) (*Obj, error) {
-	var err error
-	var vxIndices, uvIndices, normIndices []int
-	var vxTemp []f32.Vec3
-	var uvTemp []Vec2
-	var normTemp []f32.Vec3
-
+	d := &objDecoder{obj: &Obj{}, groups: defaultGroups, newObject: true}
 	s := bufio.NewScanner(r)
+	s.Buffer(nil, maxObjLine)
 	for s.Scan() {
-		line := s.Bytes()
-		if len(line) == 0 {
-			return nil, fmt.Errorf("blank line")
+		d.line++
+		err := d.decodeLine(s.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", d.line, err)
 		}
-		if line[0] == '#' {
-			continue
+	}
+	if s.Err() != nil {
+		return nil, s.Err()
+	}
+
+	obj := d.obj
+	if d.missingVN != nil {
+		generateNormals(obj, d.missingVN, d.smoothingGroups, DefaultCreaseAngle)
+		log.Printf("OBJ generated missing normals")
+	}
+	log.Printf("OBJ V=%d VT=%d VN=%d", len(obj.V), len(obj.VT), len(obj.VN))
+	return obj, nil
+}
+
+// maxObjLine is the longest line DecodeObj accepts.
+const maxObjLine = 1 << 20
+
+// objDecoder holds the state of DecodeObj.  Elements are indexed by faces as
+// they are read and faces are expanded into obj as triangles.
+type objDecoder struct {
+	line int
+	v    []f32.Vec3
+	vt   []Vec2
+	vn   []f32.Vec3
+	face []faceVertex
+	obj  *Obj
+
+	// material is the name given by the last usemtl statement.
+	material string
+
+	// objectName, groups, and smoothing are given by the last o, g, and s
+	// statements.  Objects are added when their first face is read, which
+	// is pending if newObject is true.
+	objectName string
+	newObject  bool
+	groups     []string
+	smoothing  int
+
+	// smoothingGroups is true once an s statement is read.
+	smoothingGroups bool
+
+	// missingVN marks the vertices of obj whose face vertex has no normal
+	// index.  It is nil until one is read.
+	missingVN []bool
+}
+
+// defaultGroups are the group names of faces which follow no g statement.
+var defaultGroups = []string{"default"}
+
+// faceVertex holds the zero-based indices of the elements of a face vertex,
+// with -1 for those that are omitted.
+type faceVertex struct {
+	v, vt, vn int
+}
+
+func (d *objDecoder) decodeLine(line []byte) error {
+	if i := bytes.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	head, vector := string(fields[0]), fields[1:]
+	switch head {
+	case "v":
+		// a fourth weight component, or vertex colors, are ignored.
+		if len(vector) < 3 {
+			return fmt.Errorf("invalid vertex")
 		}
-		fields := bytes.Fields(line)
-		head, vector := fields[0], fields[1:]
-		switch {
-		case bytes.Equal(head, []byte("v")):
-			if len(vector) != 3 {
-				return nil, fmt.Errorf("invalid vertex")
-			}
-			var v f32.Vec3
-			v[0], err = parseFloat32(vector[0])
-			if err != nil {
-				return nil, fmt.Errorf("invalid vertex: %v", err)
-			}
-			v[1], err = parseFloat32(vector[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid vertex: %v", err)
-			}
-			v[2], err = parseFloat32(vector[2])
-			if err != nil {
-				return nil, fmt.Errorf("invalid vertex: %v", err)
-			}
-			vxTemp = append(vxTemp, v)
-		case bytes.Equal(head, []byte("vt")):
-			if len(vector) != 2 {
-				return nil, fmt.Errorf("invalid texture coords")
-			}
-			var vt Vec2
-			vt[0], err = parseFloat32(vector[0])
-			if err != nil {
-				return nil, fmt.Errorf("invalid texture coords: %v", err)
-			}
-			vt[1], err = parseFloat32(vector[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid texture coords: %v", err)
-			}
-			uvTemp = append(uvTemp, vt)
-		case bytes.Equal(head, []byte("vn")):
-			if len(vector) != 3 {
-				return nil, fmt.Errorf("invalid normal")
-			}
-			var vn f32.Vec3
-			vn[0], err = parseFloat32(vector[0])
-			if err != nil {
-				return nil, fmt.Errorf("invalid normal: %v", err)
-			}
-			vn[1], err = parseFloat32(vector[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid normal: %v", err)
-			}
-			vn[2], err = parseFloat32(vector[2])
-			if err != nil {
-				return nil, fmt.Errorf("invalid normal: %v", err)
-			}
-			normTemp = append(normTemp, vn)
-		case bytes.Equal(head, []byte("f")):
-			if len(vector) != 3 {
-				return nil, fmt.Errorf("invalid face %q", vector)
-			}
-			vx1, uv1, norm1, err := parseIndices(vector[0])
+		v, err := parseVec(vector[:3])
+		if err != nil {
+			return fmt.Errorf("invalid vertex: %v", err)
+		}
+		d.v = append(d.v, f32.Vec3{v[0], v[1], v[2]})
+	case "vt":
+		// a third w component is ignored and v defaults to zero.
+		if len(vector) < 1 || len(vector) > 3 {
+			return fmt.Errorf("invalid texture coords")
+		}
+		if len(vector) > 2 {
+			vector = vector[:2]
+		}
+		vt, err := parseVec(vector)
+		if err != nil {
+			return fmt.Errorf("invalid texture coords: %v", err)
+		}
+		d.vt = append(d.vt, Vec2{vt[0], vt[1]})
+	case "vn":
+		if len(vector) != 3 {
+			return fmt.Errorf("invalid normal")
+		}
+		vn, err := parseVec(vector)
+		if err != nil {
+			return fmt.Errorf("invalid normal: %v", err)
+		}
+		d.vn = append(d.vn, f32.Vec3{vn[0], vn[1], vn[2]})
+	case "f":
+		if len(vector) < 3 {
+			return fmt.Errorf("invalid face %q", vector)
+		}
+		d.face = d.face[:0]
+		for _, b := range vector {
+			fv, err := d.parseFaceVertex(b)
 			if err != nil {
-				return nil, fmt.Errorf("invalid face: %v", err)
+				return fmt.Errorf("invalid face: %v", err)
 			}
-			vx2, uv2, norm2, err := parseIndices(vector[1])
-			if err != nil {
-				return nil, fmt.Errorf("invalid face: %v", err)
+			d.face = append(d.face, fv)
+		}
+		d.addFace(d.face)
+	case "usemtl":
+		d.material = joinFields(vector)
+	case "o":
+		d.objectName = joinFields(vector)
+		d.newObject = true
+	case "g":
+		d.groups = defaultGroups
+		if len(vector) > 0 {
+			d.groups = make([]string, len(vector))
+			for i, name := range vector {
+				d.groups[i] = string(name)
 			}
-			vx3, uv3, norm3, err := parseIndices(vector[2])
-			if err != nil {
-				return nil, fmt.Errorf("invalid face: %v", err)
+		}
+	case "s":
+		if len(vector) != 1 {
+			return fmt.Errorf("invalid smoothing group")
+		}
+		d.smoothing = 0
+		d.smoothingGroups = true
+		if string(vector[0]) != "off" {
+			s, err := strconv.Atoi(string(vector[0]))
+			if err != nil || s < 0 {
+				return fmt.Errorf("invalid smoothing group %q", vector[0])
 			}
+			d.smoothing = s
+		}
+	case "mtllib":
+		for _, lib := range vector {
+			d.obj.MaterialLibs = append(d.obj.MaterialLibs, string(lib))
+		}
+	}
+	return nil
+}
 
-			vxIndices = append(vxIndices, vx1, vx2, vx3)
-			uvIndices = append(uvIndices, uv1, uv2, uv3)
-			normIndices = append(normIndices, norm1, norm2, norm3)
-		case bytes.Equal(head, []byte("s")):
-			// ?
-			continue
+// parseFaceVertex parses a face vertex of the form v, v/vt, v//vn, or
+// v/vt/vn.
+func (d *objDecoder) parseFaceVertex(b []byte) (faceVertex, error) {
+	fv := faceVertex{-1, -1, -1}
+	fields := bytes.Split(b, []byte("/"))
+	if len(fields) > 3 || len(fields[0]) == 0 {
+		return fv, fmt.Errorf("expected v, v/vt, v//vn, or v/vt/vn indices: %q", b)
+	}
+	var err error
+	fv.v, err = parseIndex(fields[0], len(d.v), "vertex")
+	if err != nil {
+		return fv, err
+	}
+	if len(fields) > 1 && len(fields[1]) > 0 {
+		fv.vt, err = parseIndex(fields[1], len(d.vt), "texture coordinate")
+		if err != nil {
+			return fv, err
 		}
 	}
-	if s.Err() != nil {
-		return nil, err
+	if len(fields) > 2 && len(fields[2]) > 0 {
+		fv.vn, err = parseIndex(fields[2], len(d.vn), "normal")
+		if err != nil {
+			return fv, err
+		}
 	}
+	return fv, nil
+}
 
-	obj := &Obj{}
-	for i := range vxIndices {
-		obj.V = append(obj.V, vxTemp[vxIndices[i]-1])
+// parseIndex parses a one-based or negative, relative, index to one of n
+// elements and returns the zero-based index.
+func parseIndex(b []byte, n int, element string) (int, error) {
+	i, err := strconv.Atoi(*(*string)(unsafe.Pointer(&b)))
+	if err != nil {
+		return 0, err
 	}
-	for i := range uvIndices {
-		obj.VT = append(obj.VT, uvTemp[uvIndices[i]-1])
+	index := i - 1
+	if i < 0 {
+		index = n + i
 	}
-	for i := range normIndices {
-		obj.VN = append(obj.VN, normTemp[normIndices[i]-1])
+	if i == 0 || index < 0 || index >= n {
+		return 0, fmt.Errorf("%s index out of range: %d", element, i)
 	}
+	return index, nil
+}
 
-	log.Printf("OBJ V=%d VT=%d VN=%d", len(obj.V), len(obj.VT), len(obj.VN))
-	return obj, nil
+// addFace triangulates the polygon face and appends its triangles to obj.
+func (d *objDecoder) addFace(face []faceVertex) {
+	positions := make([]f32.Vec3, len(face))
+	for i, fv := range face {
+		positions[i] = d.v[fv.v]
+	}
+	tris := triangulate(positions)
+	n := len(d.obj.Submeshes)
+	if n == 0 || d.obj.Submeshes[n-1].Material != d.material {
+		d.obj.Submeshes = append(d.obj.Submeshes, Submesh{
+			Material: d.material,
+			First:    len(d.obj.V),
+		})
+		n++
+	}
+	d.obj.Submeshes[n-1].Count += len(tris)
+	group := d.group()
+	group.Count += len(tris)
+	d.obj.Objects[len(d.obj.Objects)-1].Count += len(tris)
+	for _, i := range tris {
+		fv := face[i]
+		var vt Vec2
+		var vn f32.Vec3
+		if fv.vt >= 0 {
+			vt = d.vt[fv.vt]
+		}
+		if fv.vn >= 0 {
+			vn = d.vn[fv.vn]
+		} else if d.missingVN == nil {
+			d.missingVN = make([]bool, len(d.obj.VN))
+		}
+		if d.missingVN != nil {
+			d.missingVN = append(d.missingVN, fv.vn < 0)
+		}
+		d.obj.V = append(d.obj.V, d.v[fv.v])
+		d.obj.VT = append(d.obj.VT, vt)
+		d.obj.VN = append(d.obj.VN, vn)
+	}
 }
 
-func parseIndices(b []byte) (int, int, int, error) {
-	fields := bytes.SplitN(b, []byte("/"), 3)
-	x1, err := strconv.Atoi(*(*string)(unsafe.Pointer(&fields[0])))
-	if err != nil {
-		return 0, 0, 0, err
+// group returns the group of the next face, adding an object or group to obj
+// if the face begins a new one.
+func (d *objDecoder) group() *Group {
+	first := len(d.obj.V)
+	if d.newObject {
+		d.obj.Objects = append(d.obj.Objects, &Object{Name: d.objectName, First: first})
+		d.newObject = false
 	}
-	x2, err := strconv.Atoi(*(*string)(unsafe.Pointer(&fields[1])))
-	if err != nil {
-		return 0, 0, 0, err
+	o := d.obj.Objects[len(d.obj.Objects)-1]
+	if n := len(o.Groups); n > 0 {
+		g := o.Groups[n-1]
+		if g.Smoothing == d.smoothing && sameNames(g.Names, d.groups) {
+			return g
+		}
 	}
-	x3, err := strconv.Atoi(*(*string)(unsafe.Pointer(&fields[2])))
-	if err != nil {
-		return 0, 0, 0, err
+	g := &Group{Names: d.groups, Smoothing: d.smoothing, First: first}
+	o.Groups = append(o.Groups, g)
+	return g
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseVec parses each field as a float32.
+func parseVec(fields [][]byte) ([3]float32, error) {
+	var v [3]float32
+	for i, b := range fields {
+		x, err := parseFloat32(b)
+		if err != nil {
+			return v, err
+		}
+		v[i] = x
 	}
-	return x1, x2, x3, nil
+	return v, nil
 }
 
 func parseFloat32(b []byte) (float32, error) {
@@ -0,0 +1,322 @@
+package mobtex
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mobile/exp/f32"
+)
+
+// goldenObj describes the obj files shipped as tutorial assets.  Files with
+// the same name in different tutorials are identical.
+var goldenObj = map[string]struct {
+	vertices    int // triangle vertices
+	vboVertices int // unique vertices after IndexVBO
+	libs        []string
+	materials   []string // material of each submesh
+	smoothing   int
+}{
+	"cube.obj":     {36, 28, []string{"cube.mtl"}, []string{"Material_ray.png"}, 0},
+	"cube2.obj":    {36, 24, nil, []string{""}, 0},
+	"suzanne.obj":  {2904, 590, nil, []string{"Material_ray.png"}, 1},
+	"cylinder.obj": {192, 66, []string{"cylinder.mtl"}, []string{"NormalMapping_diffuse.tga"}, 1},
+}
+
+// quietLog discards the debug output of the decoders for the rest of the
+// test.
+func quietLog(t testing.TB) {
+	log.SetOutput(ioutil.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func TestDecodeObjGolden(t *testing.T) {
+	quietLog(t)
+	files, err := filepath.Glob(filepath.Join("..", "tutorial*", "assets", "*.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no obj assets")
+	}
+	for _, p := range files {
+		golden, ok := goldenObj[filepath.Base(p)]
+		if !ok {
+			t.Errorf("%s: no golden description", p)
+			continue
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj, err := DecodeObj(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		if len(obj.V) != golden.vertices || len(obj.VT) != golden.vertices || len(obj.VN) != golden.vertices {
+			t.Errorf("%s: V=%d VT=%d VN=%d, expected %d", p, len(obj.V), len(obj.VT), len(obj.VN), golden.vertices)
+		}
+		for i, n := range obj.VN {
+			if l := n.Dot(&n); math.Abs(float64(l)-1) > 1e-3 {
+				t.Errorf("%s: normal %d has length %v", p, i, math.Sqrt(float64(l)))
+				break
+			}
+		}
+		if !reflect.DeepEqual(obj.MaterialLibs, golden.libs) {
+			t.Errorf("%s: material libraries %q, expected %q", p, obj.MaterialLibs, golden.libs)
+		}
+		var materials []string
+		for _, s := range obj.Submeshes {
+			materials = append(materials, s.Material)
+		}
+		if !reflect.DeepEqual(materials, golden.materials) {
+			t.Errorf("%s: submesh materials %q, expected %q", p, materials, golden.materials)
+		}
+		if len(obj.Objects) != 1 || len(obj.Objects[0].Groups) != 1 {
+			t.Errorf("%s: expected a single object and group", p)
+		} else if g := obj.Objects[0].Groups[0]; g.Smoothing != golden.smoothing || g.Count != golden.vertices {
+			t.Errorf("%s: group %+v, expected smoothing group %d", p, g, golden.smoothing)
+		}
+		vbo := IndexVBO(obj)
+		if len(vbo.V) != golden.vboVertices || len(vbo.Index) != golden.vertices {
+			t.Errorf("%s: VBO V=%d INDEX=%d, expected %d and %d", p, len(vbo.V), len(vbo.Index), golden.vboVertices, golden.vertices)
+		}
+		if unindexed := vbo.Unindex(); !reflect.DeepEqual(unindexed.V, obj.V) || !reflect.DeepEqual(unindexed.VN, obj.VN) {
+			t.Errorf("%s: Unindex does not restore the vertices of the VBO", p)
+		}
+	}
+}
+
+func decodeObjString(t *testing.T, s string) *Obj {
+	obj, err := DecodeObj(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestDecodeObjFaces(t *testing.T) {
+	quietLog(t)
+	obj := decodeObjString(t, `# a unit square
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+vn 0 0 1
+
+f 1/1/1 2/2/1 3/3/1 4/4/1
+   `+"\t"+`
+f -4/-4/-1 -2/-2/-1 -1/-1/-1 # negative indices
+`)
+	if len(obj.V) != 9 {
+		t.Fatalf("%d vertices, expected 9", len(obj.V))
+	}
+	for i, vn := range obj.VN {
+		if vn != (f32.Vec3{0, 0, 1}) {
+			t.Errorf("normal %d is %v", i, vn)
+		}
+	}
+	last := []f32.Vec3{{0, 0, 0}, {1, 1, 0}, {0, 1, 0}}
+	if !reflect.DeepEqual(obj.V[6:], last) {
+		t.Errorf("negative indices gave %v, expected %v", obj.V[6:], last)
+	}
+	if obj.VT[7] != (Vec2{1, 1}) {
+		t.Errorf("texture coordinate %v, expected {1, 1}", obj.VT[7])
+	}
+}
+
+func TestDecodeObjModel(t *testing.T) {
+	quietLog(t)
+	obj := decodeObjString(t, `mtllib a.mtl b.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+vn 0 0 1
+f 1//1 2//1 3//1
+o first
+usemtl red
+f 1//1 2//1 3//1
+g wheels body
+s 2
+f 1//1 2//1 3//1
+f 1//1 2//1 3//1
+o second
+usemtl blue
+s off
+f 1//1 2//1 3//1
+`)
+	if !reflect.DeepEqual(obj.MaterialLibs, []string{"a.mtl", "b.mtl"}) {
+		t.Errorf("material libraries %q", obj.MaterialLibs)
+	}
+	wantSubmeshes := []Submesh{{Material: "", First: 0, Count: 3}, {Material: "red", First: 3, Count: 9}, {Material: "blue", First: 12, Count: 3}}
+	if !reflect.DeepEqual(obj.Submeshes, wantSubmeshes) {
+		t.Errorf("submeshes %+v, expected %+v", obj.Submeshes, wantSubmeshes)
+	}
+	wantObjects := []*Object{
+		{Name: "", First: 0, Count: 3, Groups: []*Group{{Names: []string{"default"}, First: 0, Count: 3}}},
+		{Name: "first", First: 3, Count: 9, Groups: []*Group{
+			{Names: []string{"default"}, First: 3, Count: 3},
+			{Names: []string{"wheels", "body"}, Smoothing: 2, First: 6, Count: 6},
+		}},
+		{Name: "second", First: 12, Count: 3, Groups: []*Group{{Names: []string{"wheels", "body"}, First: 12, Count: 3}}},
+	}
+	if !reflect.DeepEqual(obj.Objects, wantObjects) {
+		t.Errorf("objects do not match")
+		for _, o := range obj.Objects {
+			t.Logf("%+v", o)
+			for _, g := range o.Groups {
+				t.Logf("\t%+v", g)
+			}
+		}
+	}
+	if o := obj.ObjectAt(7); o == nil || o.Name != "first" {
+		t.Errorf("ObjectAt(7) is %+v", o)
+	}
+	if groups := obj.Groups("body"); len(groups) != 2 {
+		t.Errorf("%d groups named body, expected 2", len(groups))
+	}
+}
+
+// foldedObj is two triangles folded 30 degrees along their shared edge,
+// without normals, and preceded by the given s statement.
+func foldedObj(s string) string {
+	return s + `
+v 0 0 0
+v 1 0 0
+v 0 1 0
+v 1 1 0.57735
+f 1 2 3
+f 2 4 3
+`
+}
+
+func TestDecodeObjMissingNormals(t *testing.T) {
+	quietLog(t)
+	for _, test := range []struct {
+		s      string
+		smooth bool
+	}{
+		{"", true}, // no s statement smooths as a single group
+		{"s 1", true},
+		{"s off", false},
+	} {
+		obj := decodeObjString(t, foldedObj(test.s))
+		// vertex 1 of the first triangle and vertex 0 of the second share
+		// a position.
+		shared := obj.VN[1] == obj.VN[3]
+		if shared != test.smooth {
+			t.Errorf("%q: shared edge normals %v and %v, expected smoothing %v", test.s, obj.VN[1], obj.VN[3], test.smooth)
+		}
+		if obj.VN[0] != (f32.Vec3{0, 0, 1}) {
+			t.Errorf("%q: unshared normal %v", test.s, obj.VN[0])
+		}
+		for i, vt := range obj.VT {
+			if vt != (Vec2{}) {
+				t.Errorf("%q: texture coordinate %d is %v", test.s, i, vt)
+			}
+		}
+	}
+
+	// given normals are kept while missing ones are generated.
+	obj := decodeObjString(t, `v 0 0 0
+v 1 0 0
+v 0 1 0
+vn 0 1 0
+f 1//1 2 3
+`)
+	if obj.VN[0] != (f32.Vec3{0, 1, 0}) || obj.VN[1] != (f32.Vec3{0, 0, 1}) {
+		t.Errorf("normals %v", obj.VN)
+	}
+}
+
+// malformedObj lists streams DecodeObj rejects with the line of each error,
+// including the inputs which once made it panic.
+var malformedObj = map[string]struct {
+	s    string
+	line string
+}{
+	"zero index":         {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n", "line 4"},
+	"index past end":     {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 4\n", "line 4"},
+	"negative past end":  {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 -4\n", "line 4"},
+	"forward reference":  {"f 1 2 3\nv 0 0 0\nv 1 0 0\nv 0 1 0\n", "line 1"},
+	"texture index":      {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1/1 2/1 3/1\n", "line 4"},
+	"normal index":       {"v 0 0 0\nv 1 0 0\nv 0 1 0\nvn 0 0 1\nf 1//1 2//2 3//1\n", "line 5"},
+	"four indices":       {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1/1/1/1 2 3\n", "line 4"},
+	"missing vertex":     {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf /1 2 3\n", "line 4"},
+	"bad index":          {"v 0 0 0\nv 1 0 0\nv 0 1 0\nf 1 2 x\n", "line 4"},
+	"two vertices":       {"v 0 0 0\nv 1 0 0\nf 1 2\n", "line 3"},
+	"short vertex":       {"v 0 0\n", "line 1"},
+	"bad vertex":         {"\n\nv 0 0 zero\n", "line 3"},
+	"bad texture coords": {"vt\n", "line 1"},
+	"bad normal":         {"vn 0 1\n", "line 1"},
+	"smoothing group":    {"s -1\n", "line 1"},
+	"smoothing groups":   {"s 1 2\n", "line 1"},
+	"long line":          {"# " + strings.Repeat("x", maxObjLine), "token too long"},
+}
+
+func TestDecodeObjMalformed(t *testing.T) {
+	quietLog(t)
+	for name, test := range malformedObj {
+		_, err := DecodeObj(strings.NewReader(test.s))
+		if err == nil || !strings.Contains(err.Error(), test.line) {
+			t.Errorf("%s: error %v, expected one on %s", name, err, test.line)
+		}
+	}
+}
+
+func FuzzDecodeObj(f *testing.F) {
+	quietLog(f)
+	f.Add([]byte(foldedObj("")))
+	f.Add([]byte(foldedObj("s off")))
+	f.Add([]byte("o a\ng b\ns 1\nusemtl m\nv 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nvt 0 0\nvn 0 0 1\nf 1/1/1 2/1/1 3/1/1 4/1/1\n"))
+	for _, test := range malformedObj {
+		if len(test.s) < 1024 {
+			f.Add([]byte(test.s))
+		}
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) > 1<<12 {
+			// ear clipping is quadratic in the vertices of a face.
+			return
+		}
+		obj, err := DecodeObj(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		n := len(obj.V)
+		if n%3 != 0 || len(obj.VT) != n || len(obj.VN) != n {
+			t.Fatalf("V=%d VT=%d VN=%d", n, len(obj.VT), len(obj.VN))
+		}
+		count := 0
+		for _, o := range obj.Objects {
+			if o.First != count {
+				t.Fatalf("object %q begins at %d, expected %d", o.Name, o.First, count)
+			}
+			count += o.Count
+		}
+		if count != n {
+			t.Fatalf("objects hold %d of %d vertices", count, n)
+		}
+		if n > 3*1024 {
+			return
+		}
+		vbo := IndexVBO(obj)
+		for _, i := range vbo.Index {
+			if int(i) >= len(vbo.V) {
+				t.Fatalf("index %d of %d vertices", i, len(vbo.V))
+			}
+		}
+	})
+}
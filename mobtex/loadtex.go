@@ -2,23 +2,116 @@ package mobtex
 
 import (
 	"fmt"
+	"image"
+	"log"
 	"path/filepath"
 	"strings"
 
+	"github.com/bmatsuo/mobile-gl-tutorial/mobtex/mipmap"
 	"golang.org/x/mobile/gl"
 )
 
 // LoadPath loads a texture asset at the given path into glctx and
-// returns a gl.Texture identifier for the resulting texture.
-func LoadPath(glctx gl.Context, path string) (gl.Texture, error) {
-	switch strings.ToLower(filepath.Ext(path)) {
+// returns the resulting texture.  Assets without a recognized extension are
+// decoded with the image package, so png, jpeg, gif, and any other format
+// registered by the application are supported.  A path without an extension
+// names a texture available in several variants and is resolved to an asset
+// with ResolvePath.  The texture is prepared and configured according to
+// opts, which may be nil.
+func LoadPath(glctx gl.Context, path string, opts *TextureOptions) (*Texture, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		resolved, err := ResolvePath(glctx, path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("texture %s resolved to %s", path, resolved)
+		return LoadPath(glctx, resolved, opts)
+	}
+	switch ext {
 	case ".bmp":
-		return LoadBMP(glctx, path)
-	case ".ktx":
-		return LoadKTX(glctx, path)
+		return LoadBMP(glctx, path, opts)
+	case ".ktx", ".ktx2":
+		return LoadKTX(glctx, path, opts)
+	case ".tga":
+		return LoadTGA(glctx, path, opts)
 	case ".dds":
-		return LoadDDSPath(glctx, path)
+		return LoadDDSPath(glctx, path, opts)
 	default:
-		return gl.Texture{}, fmt.Errorf("unable to open texture asset: %s", path)
+		// fall back to any format registered with the image package
+		texture, err := LoadImagePath(glctx, path, opts)
+		if err == image.ErrFormat {
+			return nil, fmt.Errorf("unable to open texture asset: %s", path)
+		}
+		return texture, err
+	}
+}
+
+// uploadPixels creates a texture from tightly packed, unsigned byte pixel data
+// in the given format, whose first row lies at origin.
+func uploadPixels(glctx gl.Context, width, height int, format gl.Enum, pix []byte, origin Origin, opts *TextureOptions) (*Texture, error) {
+	opts = opts.orDefault()
+	channels := len(pix) / (width * height)
+	if opts.FlipY {
+		flipRows(pix, width*channels)
+		origin = origin.flip()
 	}
+	premultiplied := opts.PremultiplyAlpha && (format == gl.RGBA || format == gl.LUMINANCE_ALPHA)
+
+	texture := glctx.CreateTexture()
+	glctx.BindTexture(gl.TEXTURE_2D, texture)
+
+	// rows of RGB and luminance data are not generally 4-byte aligned.
+	internalFormat := opts.srgbFormat(glctx, format)
+	restore := setUnpackAlignment(glctx, 1)
+	defer restore()
+
+	levels := [][]byte{pix}
+	if mopts := opts.cpuMipmaps(glctx, width, height, internalFormat); mopts != nil {
+		// the chain is filtered with straight alpha, each level is
+		// premultiplied below.
+		chain, err := mipmap.Generate(pixelsImage(width, height, format, pix), mopts)
+		if err != nil {
+			return nil, err
+		}
+		levels = levels[:0]
+		for _, img := range chain {
+			pix, err := mipmap.Pixels(img, format)
+			if err != nil {
+				return nil, err
+			}
+			levels = append(levels, pix)
+		}
+		if b := chain[0].Bounds(); b.Dx() != width || b.Dy() != height {
+			log.Printf("resized %dx%d texture to %dx%d", width, height, b.Dx(), b.Dy())
+			width, height = b.Dx(), b.Dy()
+		}
+	}
+
+	for level, pix := range levels {
+		if premultiplied {
+			premultiply(pix, channels)
+		}
+		w, h := width>>uint(level), height>>uint(level)
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+		glctx.TexImage2D(gl.TEXTURE_2D, level, w, h, internalFormat, gl.UNSIGNED_BYTE, pix)
+	}
+
+	numLevels := opts.finish(glctx, gl.TEXTURE_2D, width, height, len(levels), false)
+	return &Texture{
+		Texture: texture,
+		Target:  gl.TEXTURE_2D,
+		Width:   width,
+		Height:  height,
+		Levels:  numLevels,
+		Format:  internalFormat,
+		Alpha:   formatHasAlpha(internalFormat),
+		Origin:  origin,
+		glctx:   glctx,
+	}, nil
 }
@@ -0,0 +1,285 @@
+package mobtex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/gl"
+)
+
+// maxTGASize is the largest amount of pixel data LoadTGA will allocate.
+const maxTGASize = 1 << 28
+
+// TGA image types.
+const (
+	tgaColorMapped    = 1
+	tgaTrueColor      = 2
+	tgaGrayscale      = 3
+	tgaRLEColorMapped = 9
+	tgaRLETrueColor   = 10
+	tgaRLEGrayscale   = 11
+)
+
+// LoadTGA loads a TGA asset at path into the given gl.Context and returns the
+// resulting texture.  Uncompressed and RLE compressed true-color, grayscale,
+// and color-mapped images are supported.  The Origin of the texture reflects
+// the vertical origin given in the image descriptor, images stored
+// right-to-left are mirrored during decoding.  The texture is prepared and
+// configured according to opts, which may be nil.
+func LoadTGA(glctx gl.Context, path string, opts *TextureOptions) (*Texture, error) {
+	f, err := asset.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := decodeTGA(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return uploadPixels(glctx, img.width, img.height, img.format, img.pix, img.origin, opts)
+}
+
+// tgaImage is a decoded TGA image with pixels in a layout accepted by
+// TexImage2D.
+type tgaImage struct {
+	width  int
+	height int
+	format gl.Enum // gl.LUMINANCE, gl.LUMINANCE_ALPHA, gl.RGB, or gl.RGBA
+	origin Origin
+	pix    []byte
+}
+
+type tgaHeader struct {
+	idLength     uint8
+	colorMapType uint8
+	imageType    uint8
+	cmFirst      uint16
+	cmLength     uint16
+	cmEntrySize  uint8
+	width        uint16
+	height       uint16
+	pixelDepth   uint8
+	descriptor   uint8
+}
+
+func decodeTGA(r io.Reader) (*tgaImage, error) {
+	var b [18]byte
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %v", err)
+	}
+	h := tgaHeader{
+		idLength:     b[0],
+		colorMapType: b[1],
+		imageType:    b[2],
+		cmFirst:      binary.LittleEndian.Uint16(b[3:5]),
+		cmLength:     binary.LittleEndian.Uint16(b[5:7]),
+		cmEntrySize:  b[7],
+		width:        binary.LittleEndian.Uint16(b[12:14]),
+		height:       binary.LittleEndian.Uint16(b[14:16]),
+		pixelDepth:   b[16],
+		descriptor:   b[17],
+	}
+	if h.width == 0 || h.height == 0 {
+		return nil, fmt.Errorf("invalid image dimensions: %dx%d", h.width, h.height)
+	}
+	if h.colorMapType > 1 {
+		return nil, fmt.Errorf("invalid color map type: %d", h.colorMapType)
+	}
+
+	_, err = io.CopyN(ioutil.Discard, r, int64(h.idLength))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image id: %v", err)
+	}
+
+	// the color map is present whenever colorMapType is set, even for images
+	// which do not use it.
+	var cmap []byte
+	if h.colorMapType == 1 {
+		cmap = make([]byte, int(h.cmLength)*tgaBytes(h.cmEntrySize))
+		_, err = io.ReadFull(r, cmap)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read color map: %v", err)
+		}
+	}
+
+	// determine the size of encoded pixels and the format of the output.
+	var srcDepth uint8 // depth of the colors being converted
+	alphaBits := h.descriptor & 0x0f
+	switch h.imageType {
+	case tgaColorMapped, tgaRLEColorMapped:
+		if cmap == nil {
+			return nil, fmt.Errorf("color-mapped image without a color map")
+		}
+		if h.pixelDepth != 8 && h.pixelDepth != 16 {
+			return nil, fmt.Errorf("invalid color map index depth: %d", h.pixelDepth)
+		}
+		srcDepth = h.cmEntrySize
+	case tgaTrueColor, tgaRLETrueColor:
+		srcDepth = h.pixelDepth
+	case tgaGrayscale, tgaRLEGrayscale:
+		if h.pixelDepth != 8 && h.pixelDepth != 16 {
+			return nil, fmt.Errorf("invalid grayscale depth: %d", h.pixelDepth)
+		}
+		srcDepth = h.pixelDepth
+	default:
+		return nil, fmt.Errorf("unsupported image type: %d", h.imageType)
+	}
+
+	img := &tgaImage{
+		width:  int(h.width),
+		height: int(h.height),
+		origin: OriginBottomLeft,
+	}
+	if h.descriptor&0x20 != 0 {
+		img.origin = OriginTopLeft
+	}
+	gray := h.imageType == tgaGrayscale || h.imageType == tgaRLEGrayscale
+	var channels int
+	switch {
+	case gray && srcDepth == 8:
+		img.format, channels = gl.LUMINANCE, 1
+	case gray:
+		img.format, channels = gl.LUMINANCE_ALPHA, 2
+	case srcDepth == 15, srcDepth == 16 && alphaBits == 0, srcDepth == 24:
+		img.format, channels = gl.RGB, 3
+	case srcDepth == 16, srcDepth == 32:
+		img.format, channels = gl.RGBA, 4
+	default:
+		return nil, fmt.Errorf("unsupported color depth: %d", srcDepth)
+	}
+
+	pixBytes := tgaBytes(h.pixelDepth)
+	numPixels := img.width * img.height
+	if numPixels*pixBytes > maxTGASize || numPixels*channels > maxTGASize {
+		return nil, fmt.Errorf("image too large: %dx%d", img.width, img.height)
+	}
+	raw := make([]byte, numPixels*pixBytes)
+	rle := h.imageType >= tgaRLEColorMapped
+	if rle {
+		err = readTGARLE(r, raw, pixBytes)
+	} else {
+		_, err = io.ReadFull(r, raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pixel data: %v", err)
+	}
+
+	img.pix = make([]byte, numPixels*channels)
+	cmEntryBytes := tgaBytes(h.cmEntrySize)
+	for i := 0; i < numPixels; i++ {
+		src := raw[i*pixBytes : (i+1)*pixBytes]
+		if cmap != nil && !gray && h.imageType != tgaTrueColor && h.imageType != tgaRLETrueColor {
+			index := int(src[0])
+			if pixBytes == 2 {
+				index = int(binary.LittleEndian.Uint16(src))
+			}
+			index -= int(h.cmFirst)
+			if index < 0 || index >= int(h.cmLength) {
+				return nil, fmt.Errorf("color map index out of range: %d", index+int(h.cmFirst))
+			}
+			src = cmap[index*cmEntryBytes : (index+1)*cmEntryBytes]
+		}
+		dst := img.pix[i*channels : (i+1)*channels]
+		if gray {
+			copy(dst, src)
+			continue
+		}
+		tgaColor(dst, src, srcDepth, alphaBits)
+	}
+
+	if h.descriptor&0x10 != 0 {
+		mirrorRows(img.pix, img.width, channels)
+	}
+	return img, nil
+}
+
+// readTGARLE decodes run-length encoded pixels from r until raw is full.
+func readTGARLE(r io.Reader, raw []byte, pixBytes int) error {
+	var packet [1]byte
+	pixel := make([]byte, pixBytes)
+	for len(raw) > 0 {
+		_, err := io.ReadFull(r, packet[:])
+		if err != nil {
+			return err
+		}
+		count := int(packet[0]&0x7f) + 1
+		n := count * pixBytes
+		if n > len(raw) {
+			return fmt.Errorf("run-length packet overflows image")
+		}
+		if packet[0]&0x80 == 0 {
+			_, err = io.ReadFull(r, raw[:n])
+			if err != nil {
+				return err
+			}
+		} else {
+			_, err = io.ReadFull(r, pixel)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < count; i++ {
+				copy(raw[i*pixBytes:], pixel)
+			}
+		}
+		raw = raw[n:]
+	}
+	return nil
+}
+
+// tgaColor converts a little endian BGR(A) color of the given depth in src to
+// RGB or RGBA in dst, depending on len(dst).
+func tgaColor(dst, src []byte, depth uint8, alphaBits uint8) {
+	switch depth {
+	case 15, 16:
+		v := binary.LittleEndian.Uint16(src)
+		dst[0] = expand5(uint8(v >> 10 & 0x1f))
+		dst[1] = expand5(uint8(v >> 5 & 0x1f))
+		dst[2] = expand5(uint8(v & 0x1f))
+		if len(dst) == 4 {
+			dst[3] = 0
+			if v&0x8000 != 0 {
+				dst[3] = 0xff
+			}
+		}
+	default:
+		dst[0], dst[1], dst[2] = src[2], src[1], src[0]
+		if len(dst) == 4 {
+			dst[3] = 0xff
+			if len(src) == 4 && alphaBits != 0 {
+				dst[3] = src[3]
+			}
+		}
+	}
+}
+
+// expand5 scales a 5-bit color component to 8 bits.
+func expand5(c uint8) uint8 {
+	return c<<3 | c>>2
+}
+
+// tgaBytes returns the number of bytes used to store a value with the given
+// number of bits.
+func tgaBytes(bits uint8) int {
+	return (int(bits) + 7) / 8
+}
+
+// mirrorRows reverses the order of the pixels in each row of pix.
+func mirrorRows(pix []byte, width, channels int) {
+	stride := width * channels
+	for row := 0; row+stride <= len(pix); row += stride {
+		for i, j := 0, width-1; i < j; i, j = i+1, j-1 {
+			a := pix[row+i*channels : row+(i+1)*channels]
+			b := pix[row+j*channels : row+(j+1)*channels]
+			for k := range a {
+				a[k], b[k] = b[k], a[k]
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package mobtex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mobile/gl"
+)
+
+func TestDecodeTGAGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "tutorial*", "assets", "*.tga"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no tga assets")
+	}
+	for _, p := range files {
+		if filepath.Base(p) != "uvtemplate.tga" {
+			t.Errorf("%s: no golden description", p)
+			continue
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := decodeTGA(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		if img.width != 512 || img.height != 512 || img.format != gl.RGB || img.origin != OriginBottomLeft {
+			t.Errorf("%s: %dx%d format %#x origin %v", p, img.width, img.height, img.format, img.origin)
+		}
+		const golden = "983d9ad7bde81302090b5c1eb8b9f4e10e2426a1165d9ce0f6ccb16c35ea2c98"
+		if sum := fmt.Sprintf("%x", sha256.Sum256(img.pix)); sum != golden {
+			t.Errorf("%s: pixel sha256 %s, expected %s", p, sum, golden)
+		}
+	}
+}
+
+// testTGA describes a tga file to be encoded by hand.
+type testTGA struct {
+	imageType     uint8
+	width, height uint16
+	pixelDepth    uint8
+	descriptor    uint8
+	id            []byte
+
+	// a color map is written if cmEntrySize is not zero.
+	cmFirst     uint16
+	cmEntrySize uint8
+	cmap        []byte
+
+	data []byte
+}
+
+func (g *testTGA) encode() []byte {
+	var b bytes.Buffer
+	colorMapType := uint8(0)
+	cmLength := uint16(0)
+	if g.cmEntrySize != 0 {
+		colorMapType = 1
+		cmLength = uint16(len(g.cmap) / tgaBytes(g.cmEntrySize))
+	}
+	b.Write([]byte{uint8(len(g.id)), colorMapType, g.imageType})
+	binary.Write(&b, binary.LittleEndian, []uint16{g.cmFirst, cmLength})
+	b.WriteByte(g.cmEntrySize)
+	binary.Write(&b, binary.LittleEndian, []uint16{0, 0, g.width, g.height})
+	b.Write([]byte{g.pixelDepth, g.descriptor})
+	b.Write(g.id)
+	b.Write(g.cmap)
+	b.Write(g.data)
+	return b.Bytes()
+}
+
+func TestDecodeTGA(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		tga    testTGA
+		format gl.Enum
+		origin Origin
+		pix    []byte
+	}{
+		{
+			name:   "24-bit",
+			tga:    testTGA{imageType: tgaTrueColor, width: 2, height: 1, pixelDepth: 24, id: []byte("id"), data: []byte{1, 2, 3, 4, 5, 6}},
+			format: gl.RGB,
+			origin: OriginBottomLeft,
+			pix:    []byte{3, 2, 1, 6, 5, 4},
+		},
+		{
+			name:   "32-bit top-left",
+			tga:    testTGA{imageType: tgaTrueColor, width: 2, height: 1, pixelDepth: 32, descriptor: 0x28, data: []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+			format: gl.RGBA,
+			origin: OriginTopLeft,
+			pix:    []byte{3, 2, 1, 4, 7, 6, 5, 8},
+		},
+		{
+			name:   "32-bit without alpha bits",
+			tga:    testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 32, data: []byte{1, 2, 3, 4}},
+			format: gl.RGBA,
+			pix:    []byte{3, 2, 1, 0xff},
+		},
+		{
+			name:   "16-bit with alpha",
+			tga:    testTGA{imageType: tgaTrueColor, width: 2, height: 1, pixelDepth: 16, descriptor: 1, data: []byte{0x1f, 0x80, 0xe0, 0x03}},
+			format: gl.RGBA,
+			pix:    []byte{0, 0, 0xff, 0xff, 0, 0xff, 0, 0},
+		},
+		{
+			name:   "15-bit",
+			tga:    testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 15, data: []byte{0x00, 0x7c}},
+			format: gl.RGB,
+			pix:    []byte{0xff, 0, 0},
+		},
+		{
+			name:   "right-to-left",
+			tga:    testTGA{imageType: tgaTrueColor, width: 3, height: 1, pixelDepth: 24, descriptor: 0x10, data: []byte{1, 1, 1, 2, 2, 2, 3, 3, 3}},
+			format: gl.RGB,
+			pix:    []byte{3, 3, 3, 2, 2, 2, 1, 1, 1},
+		},
+		{
+			name:   "grayscale",
+			tga:    testTGA{imageType: tgaGrayscale, width: 2, height: 1, pixelDepth: 8, data: []byte{0x40, 0x80}},
+			format: gl.LUMINANCE,
+			pix:    []byte{0x40, 0x80},
+		},
+		{
+			name:   "grayscale with alpha",
+			tga:    testTGA{imageType: tgaGrayscale, width: 1, height: 1, pixelDepth: 16, descriptor: 8, data: []byte{0x40, 0x80}},
+			format: gl.LUMINANCE_ALPHA,
+			pix:    []byte{0x40, 0x80},
+		},
+		{
+			name: "color-mapped",
+			tga: testTGA{imageType: tgaColorMapped, width: 3, height: 1, pixelDepth: 8, cmFirst: 2, cmEntrySize: 24,
+				cmap: []byte{1, 2, 3, 4, 5, 6}, data: []byte{3, 2, 3}},
+			format: gl.RGB,
+			pix:    []byte{6, 5, 4, 3, 2, 1, 6, 5, 4},
+		},
+		{
+			name: "unused color map",
+			tga: testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 24, cmEntrySize: 24,
+				cmap: []byte{9, 9, 9}, data: []byte{1, 2, 3}},
+			format: gl.RGB,
+			pix:    []byte{3, 2, 1},
+		},
+		{
+			name: "run-length",
+			tga: testTGA{imageType: tgaRLETrueColor, width: 4, height: 1, pixelDepth: 24, data: []byte{
+				0x82, 1, 2, 3, // repeat 3 times
+				0x00, 4, 5, 6, // 1 raw pixel
+			}},
+			format: gl.RGB,
+			pix:    []byte{3, 2, 1, 3, 2, 1, 3, 2, 1, 6, 5, 4},
+		},
+		{
+			name: "run-length color-mapped",
+			tga: testTGA{imageType: tgaRLEColorMapped, width: 3, height: 1, pixelDepth: 8, cmEntrySize: 16,
+				cmap: []byte{0x1f, 0x00, 0x00, 0x7c}, data: []byte{0x01, 0, 1, 0x80, 0}},
+			format: gl.RGB,
+			pix:    []byte{0, 0, 0xff, 0xff, 0, 0, 0, 0, 0xff},
+		},
+	} {
+		img, err := decodeTGA(bytes.NewReader(test.tga.encode()))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if img.width != int(test.tga.width) || img.height != int(test.tga.height) {
+			t.Errorf("%s: dimensions %dx%d", test.name, img.width, img.height)
+		}
+		if img.format != test.format || img.origin != test.origin {
+			t.Errorf("%s: format %#x origin %v, expected %#x and %v", test.name, img.format, img.origin, test.format, test.origin)
+		}
+		if !bytes.Equal(img.pix, test.pix) {
+			t.Errorf("%s: pixels %v, expected %v", test.name, img.pix, test.pix)
+		}
+	}
+}
+
+// malformedTGA returns images decodeTGA rejects, including the dimensions
+// which would once have been allocated without bound.
+func malformedTGA() map[string][]byte {
+	tga := map[string]testTGA{
+		"zero width":      {imageType: tgaTrueColor, height: 1, pixelDepth: 24},
+		"missing map":     {imageType: tgaColorMapped, width: 1, height: 1, pixelDepth: 8, data: []byte{0}},
+		"index depth":     {imageType: tgaColorMapped, width: 1, height: 1, pixelDepth: 24, cmEntrySize: 24, cmap: make([]byte, 3)},
+		"index range":     {imageType: tgaColorMapped, width: 1, height: 1, pixelDepth: 8, cmFirst: 1, cmEntrySize: 24, cmap: make([]byte, 3), data: []byte{0}},
+		"gray depth":      {imageType: tgaGrayscale, width: 1, height: 1, pixelDepth: 24, data: make([]byte, 3)},
+		"color depth":     {imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 12, data: make([]byte, 2)},
+		"image type":      {imageType: 32, width: 1, height: 1, pixelDepth: 24, data: make([]byte, 3)},
+		"truncated":       {imageType: tgaTrueColor, width: 2, height: 2, pixelDepth: 24, data: make([]byte, 11)},
+		"run overflow":    {imageType: tgaRLETrueColor, width: 2, height: 1, pixelDepth: 24, data: []byte{0x82, 1, 2, 3}},
+		"run truncated":   {imageType: tgaRLETrueColor, width: 2, height: 1, pixelDepth: 24, data: []byte{0x01, 1, 2, 3}},
+		"huge dimensions": {imageType: tgaTrueColor, width: 0xffff, height: 0xffff, pixelDepth: 32},
+	}
+	m := make(map[string][]byte)
+	for name, g := range tga {
+		m[name] = g.encode()
+	}
+
+	b := (&testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 24, data: []byte{1, 2, 3}}).encode()
+	b[1] = 2
+	m["color map type"] = b
+
+	b = (&testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 24, id: []byte("id")}).encode()
+	m["truncated id"] = b[:len(b)-1]
+
+	b = (&testTGA{imageType: tgaTrueColor, width: 1, height: 1, pixelDepth: 24, cmEntrySize: 24, cmap: make([]byte, 6)}).encode()
+	m["truncated map"] = b[:len(b)-1]
+
+	m["truncated header"] = make([]byte, 10)
+	return m
+}
+
+func TestDecodeTGAMalformed(t *testing.T) {
+	for name, b := range malformedTGA() {
+		_, err := decodeTGA(bytes.NewReader(b))
+		if err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func FuzzDecodeTGA(f *testing.F) {
+	f.Add((&testTGA{imageType: tgaRLETrueColor, width: 4, height: 1, pixelDepth: 24, data: []byte{0x82, 1, 2, 3, 0x00, 4, 5, 6}}).encode())
+	f.Add((&testTGA{imageType: tgaColorMapped, width: 3, height: 1, pixelDepth: 8, cmFirst: 2, cmEntrySize: 24, cmap: []byte{1, 2, 3, 4, 5, 6}, data: []byte{3, 2, 3}}).encode())
+	f.Add((&testTGA{imageType: tgaGrayscale, width: 1, height: 1, pixelDepth: 16, descriptor: 0x38, data: []byte{0x40, 0x80}}).encode())
+	for _, b := range malformedTGA() {
+		f.Add(b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) >= 16 && int(binary.LittleEndian.Uint16(b[12:]))*int(binary.LittleEndian.Uint16(b[14:])) > 1<<16 {
+			// large images are slow to allocate.
+			return
+		}
+		img, err := decodeTGA(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		channels := map[gl.Enum]int{gl.LUMINANCE: 1, gl.LUMINANCE_ALPHA: 2, gl.RGB: 3, gl.RGBA: 4}[img.format]
+		if channels == 0 || len(img.pix) != img.width*img.height*channels {
+			t.Fatalf("%d bytes of %#x pixels for a %dx%d image", len(img.pix), img.format, img.width, img.height)
+		}
+	})
+}
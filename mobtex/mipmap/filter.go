@@ -0,0 +1,272 @@
+package mipmap
+
+import (
+	"fmt"
+	"math"
+)
+
+// Filter is a resampling filter used to reduce an image to the next mipmap
+// level.
+type Filter int
+
+// Available filters.
+const (
+	// Box averages the texels covered by each output texel.  It is the
+	// fastest filter and matches most GenerateMipmap implementations.
+	Box Filter = iota
+
+	// Kaiser is a sinc filter with a Kaiser window.  Distant levels stay
+	// sharper than with Box and ringing is slight.
+	Kaiser
+
+	// Lanczos is a three lobed Lanczos filter.  It is the sharpest filter and
+	// may ring near hard edges.
+	Lanczos
+)
+
+var filterNames = map[Filter]string{
+	Box:     "box",
+	Kaiser:  "kaiser",
+	Lanczos: "lanczos",
+}
+
+func (f Filter) String() string {
+	if name, ok := filterNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Filter(%d)", int(f))
+}
+
+func (f Filter) valid() bool {
+	_, ok := filterNames[f]
+	return ok
+}
+
+// kaiserAlpha controls the shape of the Kaiser window.  Larger values trade
+// sharpness for less ringing.
+const kaiserAlpha = 4
+
+// radius returns the distance from its center, in output texels, beyond which
+// the filter kernel is zero.
+func (f Filter) radius() float64 {
+	if f == Box {
+		return 0.5
+	}
+	return 3
+}
+
+// weight returns the value of the windowed sinc kernel of f at x.
+func (f Filter) weight(x float64) float64 {
+	if x <= -3 || x >= 3 {
+		return 0
+	}
+	if f == Lanczos {
+		return sinc(x) * sinc(x/3)
+	}
+	t := x / 3
+	return sinc(x) * besselI0(kaiserAlpha*math.Sqrt(1-t*t)) / besselI0(kaiserAlpha)
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// besselI0 returns the zeroth order modified Bessel function of the first
+// kind, evaluated by its power series.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1.0; term > sum*1e-12; k++ {
+		term *= (x / (2 * k)) * (x / (2 * k))
+		sum += term
+	}
+	return sum
+}
+
+// contrib lists the input texels contributing to one output texel along an
+// axis and their normalized weights.
+type contrib struct {
+	index  []int
+	weight []float32
+}
+
+// contributions computes the contributions for resampling an axis of in texels
+// to out texels with f.  Texels beyond the edges of the input repeat the edge
+// texels.
+func (f Filter) contributions(in, out int) []contrib {
+	scale := float64(in) / float64(out)
+	// kernels are stretched over the input when reducing so that they
+	// filter out frequencies the output cannot represent.
+	stretch := scale
+	if stretch < 1 {
+		stretch = 1
+	}
+	radius := f.radius() * stretch
+
+	cs := make([]contrib, out)
+	for i := range cs {
+		center := (float64(i) + 0.5) * scale
+		lo := int(math.Floor(center - radius))
+		hi := int(math.Ceil(center + radius))
+		var sum float64
+		for j := lo; j < hi; j++ {
+			var w float64
+			if f == Box {
+				// the fraction of input texel j covered by the output texel
+				w = overlap(float64(j), float64(j+1), center-radius, center+radius)
+			} else {
+				w = f.weight((float64(j) + 0.5 - center) / stretch)
+			}
+			if w == 0 {
+				continue
+			}
+			k := j
+			if k < 0 {
+				k = 0
+			} else if k >= in {
+				k = in - 1
+			}
+			cs[i].index = append(cs[i].index, k)
+			cs[i].weight = append(cs[i].weight, float32(w))
+			sum += w
+		}
+		for j := range cs[i].weight {
+			cs[i].weight[j] /= float32(sum)
+		}
+	}
+	return cs
+}
+
+// overlap returns the length of the intersection of [a0, a1) and [b0, b1).
+func overlap(a0, a1, b0, b1 float64) float64 {
+	lo, hi := a0, a1
+	if b0 > lo {
+		lo = b0
+	}
+	if b1 < hi {
+		hi = b1
+	}
+	if hi < lo {
+		return 0
+	}
+	return hi - lo
+}
+
+// buffer holds an image as linear, premultiplied RGBA components so that
+// filtering neither darkens sRGB data nor bleeds the color of transparent
+// texels.
+type buffer struct {
+	width  int
+	height int
+	pix    []float32
+}
+
+func newBuffer(width, height int) *buffer {
+	return &buffer{
+		width:  width,
+		height: height,
+		pix:    make([]float32, width*height*4),
+	}
+}
+
+// resample returns b scaled to width by height texels with f.
+func (b *buffer) resample(f Filter, width, height int) *buffer {
+	src := b
+	if width != src.width {
+		dst := newBuffer(width, src.height)
+		cs := f.contributions(src.width, width)
+		for y := 0; y < src.height; y++ {
+			row := src.pix[y*src.width*4:]
+			for x, c := range cs {
+				p := dst.pix[(y*width+x)*4:]
+				for i, k := range c.index {
+					w := c.weight[i]
+					p[0] += w * row[k*4]
+					p[1] += w * row[k*4+1]
+					p[2] += w * row[k*4+2]
+					p[3] += w * row[k*4+3]
+				}
+			}
+		}
+		src = dst
+	}
+	if height != src.height {
+		dst := newBuffer(src.width, height)
+		cs := f.contributions(src.height, height)
+		stride := src.width * 4
+		for y, c := range cs {
+			row := dst.pix[y*stride : (y+1)*stride]
+			for i, k := range c.index {
+				w := c.weight[i]
+				for x, v := range src.pix[k*stride : (k+1)*stride] {
+					row[x] += w * v
+				}
+			}
+		}
+		src = dst
+	}
+	if src != b {
+		src.clamp()
+	}
+	return src
+}
+
+// clamp removes the overshoot of negative filter lobes, keeping alpha within
+// [0, 1] and premultiplied color within [0, alpha].
+func (b *buffer) clamp() {
+	for i := 0; i < len(b.pix); i += 4 {
+		p := b.pix[i : i+4]
+		if p[3] < 0 {
+			p[3] = 0
+		} else if p[3] > 1 {
+			p[3] = 1
+		}
+		for j := 0; j < 3; j++ {
+			if p[j] < 0 {
+				p[j] = 0
+			} else if p[j] > p[3] {
+				p[j] = p[3]
+			}
+		}
+	}
+}
+
+// srgbToLinear decodes 8-bit sRGB components.
+var srgbToLinear [256]float32
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			c /= 12.92
+		} else {
+			c = math.Pow((c+0.055)/1.055, 2.4)
+		}
+		srgbToLinear[i] = float32(c)
+	}
+}
+
+// linearToSRGB encodes a linear component in [0, 1] as 8-bit sRGB.
+func linearToSRGB(c float32) uint8 {
+	v := float64(c)
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return unorm8(float32(v))
+}
+
+// unorm8 converts a component in [0, 1] to 8 bits.
+func unorm8(c float32) uint8 {
+	if c <= 0 {
+		return 0
+	}
+	if c >= 1 {
+		return 0xff
+	}
+	return uint8(c*255 + 0.5)
+}
@@ -0,0 +1,317 @@
+/*
+Package mipmap builds mipmap chains on the CPU.  GLES2 cannot generate mipmaps
+for non-power-of-two or sRGB textures with GenerateMipmap, nor for compressed
+data, so such textures must be given their levels explicitly.
+
+A chain is built from the base image with a choice of filter.  Color is
+treated as sRGB encoded and filtered in linear space, and alpha weights color
+so that transparent texels do not bleed into their neighbors.
+
+	chain, err := mipmap.Generate(img, &mipmap.Options{
+		Filter: mipmap.Kaiser,
+		Resize: mipmap.ResizeNearest,
+	})
+	if err != nil {
+		return err
+	}
+	err = chain.Upload(glctx, gl.TEXTURE_2D, gl.RGBA)
+
+The levels of a chain can also be compressed and stored in a ktx file.
+
+	err = etc.WriteKTX(w, etc.RGB8, chain.Images(), nil)
+*/
+package mipmap
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+
+	"golang.org/x/mobile/gl"
+)
+
+// Resize selects the power-of-two dimensions a non-power-of-two image is
+// scaled to before its mipmaps are built.
+type Resize int
+
+// Available Resize modes.
+const (
+	// NoResize keeps the dimensions of the image.  GLES2 devices without
+	// GL_OES_texture_npot cannot sample mipmapped textures with
+	// non-power-of-two dimensions.
+	NoResize Resize = iota
+
+	// ResizeUp scales each dimension up to the next power of two.
+	ResizeUp
+
+	// ResizeDown scales each dimension down to the previous power of two.
+	ResizeDown
+
+	// ResizeNearest scales each dimension to the closest power of two.
+	ResizeNearest
+)
+
+var resizeNames = map[Resize]string{
+	NoResize:      "none",
+	ResizeUp:      "up",
+	ResizeDown:    "down",
+	ResizeNearest: "nearest",
+}
+
+func (r Resize) String() string {
+	if name, ok := resizeNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Resize(%d)", int(r))
+}
+
+// Dimensions returns the dimensions of a width by height image after it is
+// resized by r.
+func (r Resize) Dimensions(width, height int) (int, int) {
+	return r.size(width), r.size(height)
+}
+
+func (r Resize) size(n int) int {
+	if r == NoResize || n < 1 || n&(n-1) == 0 {
+		return n
+	}
+	lo := 1
+	for lo*2 < n {
+		lo *= 2
+	}
+	hi := lo * 2
+	switch r {
+	case ResizeDown:
+		return lo
+	case ResizeNearest:
+		// compare ratios, so 3 becomes 4 rather than 2.
+		if n*n < lo*hi {
+			return lo
+		}
+	}
+	return hi
+}
+
+// Options control how a mipmap chain is built.  A nil *Options, or any zero
+// field, selects the default behavior.
+type Options struct {
+	// Filter reduces each level to the next.  The default is Box.
+	Filter Filter
+
+	// Linear disables the conversion of color between sRGB and linear space
+	// around filtering.  It should be set for data which is not color, like
+	// normal maps, and for color which is already linear.
+	Linear bool
+
+	// Resize scales the base image before the chain is built.  The default
+	// is NoResize.
+	Resize Resize
+}
+
+var defaultOptions Options
+
+// Chain is a mipmap chain beginning with the base level.  Each level is half
+// the size of the previous one, rounded down but at least 1, and the last
+// level is 1x1.
+type Chain []*image.NRGBA
+
+// Levels returns the number of levels in a complete mipmap chain for an
+// image of the given size.
+func Levels(width, height int) int {
+	n := 1
+	for width > 1 || height > 1 {
+		width /= 2
+		height /= 2
+		n++
+	}
+	return n
+}
+
+// Generate builds the complete mipmap chain of img according to opts, which
+// may be nil.
+func Generate(img image.Image, opts *Options) (Chain, error) {
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	if !opts.Filter.valid() {
+		return nil, fmt.Errorf("mipmap: unknown filter %v", opts.Filter)
+	}
+	b := img.Bounds()
+	if b.Empty() {
+		return nil, fmt.Errorf("mipmap: empty image")
+	}
+
+	buf := decode(img, opts.Linear)
+	width, height := opts.Resize.Dimensions(b.Dx(), b.Dy())
+	buf = buf.resample(opts.Filter, width, height)
+
+	n := Levels(width, height)
+	chain := make(Chain, 0, n)
+	for {
+		chain = append(chain, buf.encode(opts.Linear))
+		if len(chain) == n {
+			return chain, nil
+		}
+		// each level is reduced from the unquantized previous level.
+		buf = buf.resample(opts.Filter, halve(buf.width), halve(buf.height))
+	}
+}
+
+// Scale returns img resampled to width by height texels with the Filter and
+// color space given by opts, which may be nil.
+func Scale(img image.Image, width, height int, opts *Options) (*image.NRGBA, error) {
+	if opts == nil {
+		opts = &defaultOptions
+	}
+	if !opts.Filter.valid() {
+		return nil, fmt.Errorf("mipmap: unknown filter %v", opts.Filter)
+	}
+	if img.Bounds().Empty() || width < 1 || height < 1 {
+		return nil, fmt.Errorf("mipmap: cannot scale %v image to %dx%d", img.Bounds().Size(), width, height)
+	}
+	buf := decode(img, opts.Linear)
+	return buf.resample(opts.Filter, width, height).encode(opts.Linear), nil
+}
+
+func halve(n int) int {
+	if n > 1 {
+		return n / 2
+	}
+	return 1
+}
+
+// Images returns the levels of c as a slice of image.Image, as accepted by
+// ktx writers.
+func (c Chain) Images() []image.Image {
+	images := make([]image.Image, len(c))
+	for i, img := range c {
+		images[i] = img
+	}
+	return images
+}
+
+// Upload specifies the levels of c as the mipmap levels of the texture bound
+// to target, TEXTURE_2D or a cubemap face, with TexImage2D.  Each level is
+// converted to format, which may be any format accepted by Pixels.
+func (c Chain) Upload(glctx gl.Context, target, format gl.Enum) error {
+	// rows of RGB and luminance data are not generally 4-byte aligned.
+	prev := glctx.GetInteger(gl.UNPACK_ALIGNMENT)
+	glctx.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	defer glctx.PixelStorei(gl.UNPACK_ALIGNMENT, int32(prev))
+
+	for level, img := range c {
+		pix, err := Pixels(img, format)
+		if err != nil {
+			return err
+		}
+		b := img.Bounds()
+		glctx.TexImage2D(target, level, b.Dx(), b.Dy(), format, gl.UNSIGNED_BYTE, pix)
+		if glerr := glctx.GetError(); glerr != 0 {
+			return fmt.Errorf("mipmap: level %d: gl error %#x", level, glerr)
+		}
+	}
+	return nil
+}
+
+// GL_EXT_sRGB formats.
+const (
+	glSRGB      = 0x8C40
+	glSRGBAlpha = 0x8C42
+)
+
+// Pixels converts img to tightly packed rows of unsigned bytes in format,
+// which is RGBA, RGB, LUMINANCE, LUMINANCE_ALPHA, ALPHA, or one of the
+// GL_EXT_sRGB formats SRGB_EXT and SRGB_ALPHA_EXT.
+func Pixels(img *image.NRGBA, format gl.Enum) ([]byte, error) {
+	var channels int
+	switch format {
+	case gl.RGBA, glSRGBAlpha:
+		channels = 4
+	case gl.RGB, glSRGB:
+		channels = 3
+	case gl.LUMINANCE_ALPHA:
+		channels = 2
+	case gl.LUMINANCE, gl.ALPHA:
+		channels = 1
+	default:
+		return nil, fmt.Errorf("mipmap: unsupported format %#x", format)
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	pix := make([]byte, w*h*channels)
+	for y := 0; y < h; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < w; x++ {
+			src := row[x*4 : x*4+4]
+			dst := pix[(y*w+x)*channels:]
+			switch format {
+			case gl.ALPHA:
+				dst[0] = src[3]
+			case gl.LUMINANCE, gl.LUMINANCE_ALPHA:
+				// the luma weights of color.GrayModel
+				dst[0] = uint8((19595*uint32(src[0]) + 38470*uint32(src[1]) + 7471*uint32(src[2]) + 1<<15) >> 16)
+				if channels == 2 {
+					dst[1] = src[3]
+				}
+			default:
+				copy(dst[:channels], src)
+			}
+		}
+	}
+	return pix, nil
+}
+
+// decode converts img into a buffer, decoding sRGB color unless linear is
+// true.
+func decode(img image.Image, linear bool) *buffer {
+	b := img.Bounds()
+	src, ok := img.(*image.NRGBA)
+	if !ok {
+		src = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+		b = src.Bounds()
+	}
+
+	buf := newBuffer(b.Dx(), b.Dy())
+	for y := 0; y < buf.height; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x := 0; x < buf.width; x++ {
+			s := row[x*4 : x*4+4]
+			p := buf.pix[(y*buf.width+x)*4:]
+			a := float32(s[3]) / 255
+			for j := 0; j < 3; j++ {
+				c := float32(s[j]) / 255
+				if !linear {
+					c = srgbToLinear[s[j]]
+				}
+				p[j] = c * a
+			}
+			p[3] = a
+		}
+	}
+	return buf
+}
+
+// encode converts b into an image, encoding color as sRGB unless linear is
+// true.
+func (b *buffer) encode(linear bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, b.width, b.height))
+	for i := 0; i < len(b.pix); i += 4 {
+		p := b.pix[i : i+4]
+		a := p[3]
+		img.Pix[i+3] = unorm8(a)
+		if a <= 0 {
+			continue
+		}
+		for j := 0; j < 3; j++ {
+			c := p[j] / a
+			if linear {
+				img.Pix[i+j] = unorm8(c)
+			} else {
+				img.Pix[i+j] = linearToSRGB(c)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,224 @@
+package mipmap
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeDimensions(t *testing.T) {
+	for _, test := range []struct {
+		r    Resize
+		n    int
+		want int
+	}{
+		{NoResize, 3, 3},
+		{NoResize, 6, 6},
+		{ResizeUp, 1, 1},
+		{ResizeUp, 3, 4},
+		{ResizeUp, 5, 8},
+		{ResizeUp, 8, 8},
+		{ResizeDown, 3, 2},
+		{ResizeDown, 6, 4},
+		{ResizeDown, 8, 8},
+		{ResizeNearest, 1, 1},
+		{ResizeNearest, 2, 2},
+		{ResizeNearest, 3, 4},
+		{ResizeNearest, 5, 4},
+		{ResizeNearest, 6, 8},
+		{ResizeNearest, 11, 8},
+		{ResizeNearest, 12, 16},
+	} {
+		w, h := test.r.Dimensions(test.n, 1)
+		if w != test.want || h != 1 {
+			t.Errorf("%v: %dx1 resized to %dx%d, expected %dx1", test.r, test.n, w, h, test.want)
+		}
+		w, h = test.r.Dimensions(1, test.n)
+		if w != 1 || h != test.want {
+			t.Errorf("%v: 1x%d resized to %dx%d, expected 1x%d", test.r, test.n, w, h, test.want)
+		}
+	}
+}
+
+// uniform returns a width by height image filled with c.
+func uniform(width, height int, c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestGenerateLevels(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		width, height int
+		resize        Resize
+		want          []image.Point
+	}{
+		{"1x1", 1, 1, NoResize, []image.Point{{1, 1}}},
+		{"square", 4, 4, NoResize, []image.Point{{4, 4}, {2, 2}, {1, 1}}},
+		{"wide", 8, 2, NoResize, []image.Point{{8, 2}, {4, 1}, {2, 1}, {1, 1}}},
+		{"tall", 1, 4, NoResize, []image.Point{{1, 4}, {1, 2}, {1, 1}}},
+		{"npot", 5, 3, NoResize, []image.Point{{5, 3}, {2, 1}, {1, 1}}},
+		{"npot resized", 6, 3, ResizeNearest, []image.Point{{8, 4}, {4, 2}, {2, 1}, {1, 1}}},
+		{"npot resized down", 7, 5, ResizeDown, []image.Point{{4, 4}, {2, 2}, {1, 1}}},
+	} {
+		chain, err := Generate(uniform(test.width, test.height, color.NRGBA{0x80, 0x40, 0x20, 0xff}), &Options{Resize: test.resize})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(chain) != len(test.want) {
+			t.Errorf("%s: %d levels, expected %d", test.name, len(chain), len(test.want))
+			continue
+		}
+		w, h := test.resize.Dimensions(test.width, test.height)
+		if n := Levels(w, h); n != len(chain) {
+			t.Errorf("%s: Levels returned %d, the chain has %d", test.name, n, len(chain))
+		}
+		for i, img := range chain {
+			if size := img.Bounds().Size(); size != test.want[i] {
+				t.Errorf("%s: level %d is %v, expected %v", test.name, i, size, test.want[i])
+			}
+		}
+	}
+}
+
+func TestGenerateUniform(t *testing.T) {
+	c := color.NRGBA{0x80, 0x40, 0x20, 0xc0}
+	for _, f := range []Filter{Box, Kaiser, Lanczos} {
+		chain, err := Generate(uniform(7, 5, c), &Options{Filter: f, Resize: ResizeUp})
+		if err != nil {
+			t.Errorf("%v: %v", f, err)
+			continue
+		}
+		for i, img := range chain {
+			b := img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if got := img.NRGBAAt(x, y); !near(got, c, 1) {
+						t.Errorf("%v: level %d texel %d,%d is %v, expected %v", f, i, x, y, got, c)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestBoxAverage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	img.SetNRGBA(0, 0, color.NRGBA{0, 40, 10, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{100, 80, 20, 0xff})
+	img.SetNRGBA(0, 1, color.NRGBA{200, 120, 30, 0xff})
+	img.SetNRGBA(1, 1, color.NRGBA{100, 160, 40, 0xff})
+	want := color.NRGBA{100, 100, 25, 0xff}
+
+	chain, err := Generate(img, &Options{Linear: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 {
+		t.Fatalf("%d levels", len(chain))
+	}
+	if got := chain[0].NRGBAAt(1, 1); got != img.NRGBAAt(1, 1) {
+		t.Errorf("base level changed to %v", got)
+	}
+	if got := chain[1].NRGBAAt(0, 0); !near(got, want, 1) {
+		t.Errorf("averaged to %v, expected %v", got, want)
+	}
+
+	scaled, err := Scale(img, 1, 1, &Options{Linear: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scaled.NRGBAAt(0, 0); !near(got, want, 1) {
+		t.Errorf("scaled to %v, expected %v", got, want)
+	}
+}
+
+func TestAverageSRGB(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+
+	for _, test := range []struct {
+		name   string
+		linear bool
+		want   uint8
+	}{
+		// half the light of white is encoded as 188 in sRGB.
+		{"srgb", false, 188},
+		{"linear", true, 128},
+	} {
+		scaled, err := Scale(img, 1, 1, &Options{Linear: test.linear})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := color.NRGBA{test.want, test.want, test.want, 0xff}
+		if got := scaled.NRGBAAt(0, 0); !near(got, want, 1) {
+			t.Errorf("%s: averaged black and white to %v, expected %v", test.name, got, want)
+		}
+	}
+}
+
+func TestTransparentBleed(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.NRGBA{0xff, 0, 0, 0xff}
+			if (x+y)%2 == 1 {
+				// fully transparent, but with a color of its own
+				c = color.NRGBA{0, 0xff, 0xff, 0}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	for _, f := range []Filter{Box, Kaiser, Lanczos} {
+		chain, err := Generate(img, &Options{Filter: f})
+		if err != nil {
+			t.Errorf("%v: %v", f, err)
+			continue
+		}
+		for i, level := range chain[1:] {
+			for j := 0; j < len(level.Pix); j += 4 {
+				p := level.Pix[j : j+4]
+				if p[3] != 0 && (p[0] != 0xff || p[1] != 0 || p[2] != 0) {
+					t.Errorf("%v: level %d has color %v", f, i+1, p)
+					break
+				}
+			}
+		}
+		if a := chain[len(chain)-1].Pix[3]; a < 127 || a > 128 {
+			t.Errorf("%v: alpha averaged to %d, expected 128", f, a)
+		}
+	}
+}
+
+func TestOptionsInvalid(t *testing.T) {
+	img := uniform(2, 2, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	if _, err := Generate(img, &Options{Filter: Lanczos + 1}); err == nil {
+		t.Errorf("generated a chain with an unknown filter")
+	}
+	if _, err := Generate(image.NewNRGBA(image.Rect(0, 0, 0, 4)), nil); err == nil {
+		t.Errorf("generated a chain from an empty image")
+	}
+	if _, err := Scale(img, 0, 1, nil); err == nil {
+		t.Errorf("scaled an image to 0x1")
+	}
+	if _, err := Scale(img, 1, 1, &Options{Filter: -1}); err == nil {
+		t.Errorf("scaled an image with an unknown filter")
+	}
+}
+
+// near returns true if each component of a and b differ by at most d.
+func near(a, b color.NRGBA, d int) bool {
+	diff := func(x, y uint8) bool {
+		n := int(x) - int(y)
+		return n >= -d && n <= d
+	}
+	return diff(a.R, b.R) && diff(a.G, b.G) && diff(a.B, b.B) && diff(a.A, b.A)
+}
@@ -1,14 +1,35 @@
 /*
 Package mobtex wraps generic texture asset decoders so they can be loaded into
 a gl.Context from golang.org/x/mobile/gl.  If the texture does not supply
-mipmaps, as in the BMP format, then mipmaps will be generated automatically.
+mipmaps, as in the BMP and TGA formats, then mipmaps will be generated
+automatically, on the CPU with package mipmap where GenerateMipmap cannot be
+used.  Any image format registered with the standard image package,
+such as png and jpeg, may also be loaded.  S3TC data in DDS files and ETC data
+in KTX files is decompressed in software when the device cannot sample it.
 
 Typically an application will just make use of the generic function LoadPath.
 
 	texturePath := "myasset.ktx"
-	texture, err := mobtex.LoadPath(glctx, texturePath)
+	texture, err := mobtex.LoadPath(glctx, texturePath, nil)
 	if err != nil {
 		log.Printf("texture asset %s failed to load : %v", texturePath, err)
 	}
+
+Filtering, wrapping, mipmap generation, and the preparation of pixel data are
+controlled by TextureOptions.  A nil *TextureOptions selects the defaults.
+
+	texture, err := mobtex.LoadPath(glctx, "sprites.png", &mobtex.TextureOptions{
+		MinFilter:        gl.NEAREST,
+		MagFilter:        gl.NEAREST,
+		NoMipmaps:        true,
+		PremultiplyAlpha: true,
+	})
+
+Image formats disagree about whether the first row of pixel data is the top
+or bottom of the image.  The Origin of each loaded Texture records which is
+the case so that mesh texture coordinates can be adjusted to match, regardless
+of the asset format.
+
+	obj.OrientUV(texture.Origin)
 */
 package mobtex
@@ -0,0 +1,179 @@
+package mobtex
+
+import (
+	"math"
+
+	"golang.org/x/mobile/exp/f32"
+)
+
+// DefaultCreaseAngle is the crease angle, in radians, used for the normals
+// DecodeObj generates.
+const DefaultCreaseAngle = math.Pi / 3
+
+// FlatNormals sets the normal of each vertex of obj to the normal of its
+// triangle, so that every face is lit evenly.
+func (obj *Obj) FlatNormals() {
+	for t := 0; t+2 < len(obj.V); t += 3 {
+		n := faceNormal(obj.V[t], obj.V[t+1], obj.V[t+2])
+		obj.VN[t], obj.VN[t+1], obj.VN[t+2] = n, n, n
+	}
+}
+
+// SmoothNormals sets the normal of each vertex of obj to the average of the
+// normals of the triangles sharing its position, weighted by the angle of
+// each triangle at the vertex.  Only triangles in the same smoothing group
+// are averaged, and triangles whose normals differ by more than creaseAngle
+// radians keep a hard edge between them.  Triangles with smoothing off get
+// flat normals.  Triangles outside the Objects of obj, as when an Obj is built
+// by hand, form a single smoothing group.
+func (obj *Obj) SmoothNormals(creaseAngle float32) {
+	generateNormals(obj, nil, true, creaseAngle)
+}
+
+// FlatNormals sets the normals of vbo like Obj.FlatNormals.  Vertices shared
+// by triangles are split where their normals differ, so vbo is indexed again.
+func (vbo *VBO) FlatNormals() {
+	obj := vbo.Unindex()
+	obj.FlatNormals()
+	*vbo = *IndexVBO(obj)
+}
+
+// SmoothNormals sets the normals of vbo like Obj.SmoothNormals.  Vertices
+// shared by triangles are split where their normals differ, so vbo is indexed
+// again.
+func (vbo *VBO) SmoothNormals(creaseAngle float32) {
+	obj := vbo.Unindex()
+	obj.SmoothNormals(creaseAngle)
+	*vbo = *IndexVBO(obj)
+}
+
+// Unindex returns an Obj with the vertices of each triangle of vbo, the
+// inverse of IndexVBO.
+func (vbo *VBO) Unindex() *Obj {
+	obj := &Obj{
+		V:     make([]f32.Vec3, len(vbo.Index)),
+		VT:    make([]Vec2, len(vbo.Index)),
+		VN:    make([]f32.Vec3, len(vbo.Index)),
+		Model: vbo.Model,
+	}
+	if vbo.T != nil {
+		obj.T = make([]f32.Vec4, len(vbo.Index))
+	}
+	for i, j := range vbo.Index {
+		obj.V[i] = vbo.V[j]
+		obj.VT[i] = vbo.VT[j]
+		obj.VN[i] = vbo.VN[j]
+		if obj.T != nil {
+			obj.T[i] = vbo.T[j]
+		}
+	}
+	return obj
+}
+
+// generateNormals computes smooth normals for the vertices of obj which are
+// marked in missing, or for every vertex if missing is nil.  If groups is
+// false the smoothing groups of obj are ignored and every triangle is smoothed
+// as a single group.
+func generateNormals(obj *Obj, missing []bool, groups bool, creaseAngle float32) {
+	ntri := len(obj.V) / 3
+	smoothing := make([]int, ntri)
+	for t := range smoothing {
+		smoothing[t] = 1
+	}
+	if groups {
+		for _, o := range obj.Objects {
+			for _, g := range o.Groups {
+				for i := g.First; i < g.First+g.Count; i++ {
+					smoothing[i/3] = g.Smoothing
+				}
+			}
+		}
+	}
+
+	// the normal and corner angles of each triangle.
+	normals := make([]f32.Vec3, ntri)
+	angles := make([]float32, 3*ntri)
+	for t := range normals {
+		a, b, c := obj.V[3*t], obj.V[3*t+1], obj.V[3*t+2]
+		normals[t] = faceNormal(a, b, c)
+		angles[3*t] = cornerAngle(a, b, c)
+		angles[3*t+1] = cornerAngle(b, c, a)
+		angles[3*t+2] = cornerAngle(c, a, b)
+	}
+
+	// corners of smoothed triangles are collected by position and smoothing
+	// group.
+	type cornerKey struct {
+		v         f32.Vec3
+		smoothing int
+	}
+	corners := make(map[cornerKey][]int)
+	for i := 0; i < 3*ntri; i++ {
+		if s := smoothing[i/3]; s != 0 {
+			key := cornerKey{obj.V[i], s}
+			corners[key] = append(corners[key], i)
+		}
+	}
+
+	minCos := f32.Cos(creaseAngle)
+	for i := 0; i < 3*ntri; i++ {
+		if missing != nil && !missing[i] {
+			continue
+		}
+		n := normals[i/3]
+		if s := smoothing[i/3]; s != 0 {
+			var sum f32.Vec3
+			for _, j := range corners[cornerKey{obj.V[i], s}] {
+				nj := normals[j/3]
+				if n.Dot(&nj) < minCos {
+					continue
+				}
+				sum[0] += angles[j] * nj[0]
+				sum[1] += angles[j] * nj[1]
+				sum[2] += angles[j] * nj[2]
+			}
+			if normalize(&sum) {
+				n = sum
+			}
+		}
+		obj.VN[i] = n
+	}
+}
+
+// faceNormal returns the unit normal of the counter-clockwise triangle abc,
+// or a zero vector if the triangle is degenerate.
+func faceNormal(a, b, c f32.Vec3) f32.Vec3 {
+	var ab, ac, n f32.Vec3
+	ab.Sub(&b, &a)
+	ac.Sub(&c, &a)
+	n.Cross(&ab, &ac)
+	normalize(&n)
+	return n
+}
+
+// cornerAngle returns the angle of the triangle abc at a.
+func cornerAngle(a, b, c f32.Vec3) float32 {
+	var ab, ac f32.Vec3
+	ab.Sub(&b, &a)
+	ac.Sub(&c, &a)
+	if !normalize(&ab) || !normalize(&ac) {
+		return 0
+	}
+	cos := float64(ab.Dot(&ac))
+	if cos > 1 {
+		cos = 1
+	} else if cos < -1 {
+		cos = -1
+	}
+	return float32(math.Acos(cos))
+}
+
+// normalize scales v to unit length, returning false and leaving v unchanged
+// if v has zero length.
+func normalize(v *f32.Vec3) bool {
+	if v.Dot(v) == 0 {
+		return false
+	}
+	v.Normalize()
+	return true
+}
@@ -0,0 +1,254 @@
+package mobtex
+
+import (
+	"log"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/mobtex/mipmap"
+	"golang.org/x/mobile/gl"
+)
+
+// TextureOptions control how texture data is prepared before it is uploaded
+// and how the resulting texture is sampled.  A nil *TextureOptions, or any
+// zero field, selects the default behavior.
+type TextureOptions struct {
+	// MinFilter is the TEXTURE_MIN_FILTER of the texture.  The default is
+	// LINEAR_MIPMAP_LINEAR for textures with a complete mipmap chain and
+	// LINEAR otherwise.
+	MinFilter gl.Enum
+
+	// MagFilter is the TEXTURE_MAG_FILTER of the texture.  The default is
+	// LINEAR.
+	MagFilter gl.Enum
+
+	// WrapS and WrapT are the TEXTURE_WRAP_S and TEXTURE_WRAP_T modes of the
+	// texture.  The default is REPEAT, except for cubemaps and for textures
+	// with non-power-of-two dimensions which GLES2 can only clamp, which
+	// default to CLAMP_TO_EDGE.
+	WrapS gl.Enum
+	WrapT gl.Enum
+
+	// NoMipmaps disables the generation of mipmaps for uncompressed textures
+	// which do not supply a complete mipmap chain.
+	NoMipmaps bool
+
+	// Mipmaps builds the mipmaps of uncompressed textures which do not
+	// supply them on the CPU, with the given options, instead of with
+	// GenerateMipmap.  If Mipmaps is nil the CPU is used with the default
+	// options only where GenerateMipmap cannot be, for sRGB textures and for
+	// non-power-of-two textures on devices with GL_OES_texture_npot.  Other
+	// devices cannot sample mipmapped non-power-of-two textures so those are
+	// not mipmapped unless Mipmaps.Resize scales them to powers of two.
+	Mipmaps *mipmap.Options
+
+	// Anisotropy is the maximum degree of anisotropic filtering.  Values
+	// greater than one are clamped to the largest value supported and have
+	// no effect unless the context supports
+	// GL_EXT_texture_filter_anisotropic.
+	Anisotropy float32
+
+	// SRGB requests an sRGB internal format so that texels are converted to
+	// linear space when sampled.  RGB and RGBA data requires GL_EXT_sRGB,
+	// compressed data requires the sRGB variant of its format to be listed in
+	// COMPRESSED_TEXTURE_FORMATS.  The linear format is used otherwise.
+	SRGB bool
+
+	// PremultiplyAlpha multiplies the color components of uncompressed data
+	// with an alpha channel by their alpha before the data is uploaded.
+	PremultiplyAlpha bool
+
+	// FlipY reverses the order of the rows of uncompressed 2D data so that
+	// the opposite row is uploaded first.  Compressed data and cubemaps are
+	// never flipped.  The Origin of the resulting Texture always describes
+	// the uploaded data.
+	FlipY bool
+}
+
+var defaultTextureOptions TextureOptions
+
+// orDefault returns opts, or the default options if opts is nil.
+func (opts *TextureOptions) orDefault() *TextureOptions {
+	if opts == nil {
+		return &defaultTextureOptions
+	}
+	return opts
+}
+
+// GL_EXT_texture_filter_anisotropic and GL_EXT_sRGB enums.
+const (
+	glTextureMaxAnisotropy    = 0x84FE
+	glMaxTextureMaxAnisotropy = 0x84FF
+	glSRGB                    = 0x8C40
+	glSRGBAlpha               = 0x8C42
+)
+
+// srgbFormat returns the sRGB equivalent of the uncompressed format, if
+// the context supports one.
+func (opts *TextureOptions) srgbFormat(glctx gl.Context, format gl.Enum) gl.Enum {
+	if !opts.SRGB || !hasExtension(glctx, "GL_EXT_sRGB") {
+		return format
+	}
+	switch format {
+	case gl.RGB:
+		return glSRGB
+	case gl.RGBA:
+		return glSRGBAlpha
+	}
+	return format
+}
+
+// srgbCompressedFormats maps linear compressed formats to their sRGB
+// variants.
+var srgbCompressedFormats = map[gl.Enum]gl.Enum{
+	0x83F0: 0x8C4C, // S3TC DXT1 RGB
+	0x83F1: 0x8C4D, // S3TC DXT1 RGBA
+	0x83F2: 0x8C4E, // S3TC DXT3
+	0x83F3: 0x8C4F, // S3TC DXT5
+	0x9274: 0x9275, // ETC2 RGB8
+	0x9276: 0x9277, // ETC2 RGB8 punchthrough alpha
+	0x9278: 0x9279, // ETC2 RGBA8 EAC
+}
+
+func init() {
+	// ASTC sRGB formats parallel the linear formats
+	for i := gl.Enum(0); i < 14; i++ {
+		srgbCompressedFormats[0x93B0+i] = 0x93D0 + i
+	}
+}
+
+// srgbCompressedFormat returns the sRGB equivalent of the compressed format,
+// if the context supports one.
+func (opts *TextureOptions) srgbCompressedFormat(glctx gl.Context, format gl.Enum) gl.Enum {
+	if !opts.SRGB {
+		return format
+	}
+	srgb, ok := srgbCompressedFormats[format]
+	if !ok {
+		return format
+	}
+	if hasCompressedFormat(glctx, srgb) {
+		return srgb
+	}
+	return format
+}
+
+// cpuMipmaps returns the options used to build the mipmaps of a width by
+// height uncompressed texture with the given internal format on the CPU, or
+// nil if the mipmaps are left to finish.
+func (opts *TextureOptions) cpuMipmaps(glctx gl.Context, width, height int, internalFormat gl.Enum) *mipmap.Options {
+	if opts.NoMipmaps {
+		return nil
+	}
+	mopts := opts.Mipmaps
+	if mopts == nil {
+		mopts = &mipmap.Options{}
+	}
+	width, height = mopts.Resize.Dimensions(width, height)
+	if !isPowerOfTwo(width) || !isPowerOfTwo(height) {
+		if !hasExtension(glctx, "GL_OES_texture_npot") {
+			return nil
+		}
+		return mopts
+	}
+	if opts.Mipmaps != nil || internalFormat == glSRGB || internalFormat == glSRGBAlpha {
+		return mopts
+	}
+	return nil
+}
+
+// finish generates mipmaps as needed and sets the sampling parameters of the
+// texture bound to target, which holds the given number of levels of data
+// with a base level of width by height texels.  The number of levels in the
+// texture after any mipmaps are generated is returned.
+func (opts *TextureOptions) finish(glctx gl.Context, target gl.Enum, width, height, levels int, compressed bool) int {
+	npot := !isPowerOfTwo(width) || !isPowerOfTwo(height)
+	mipmapped := levels >= mipmapLevels(width, height)
+	if !mipmapped && !compressed && !npot && !opts.NoMipmaps {
+		glctx.GenerateMipmap(target)
+		if glerr := glctx.GetError(); glerr != 0 {
+			// GLES2 cannot generate mipmaps for some formats, like sRGB
+			log.Printf("unable to generate mipmaps: GL ERROR: %x", glerr)
+		} else {
+			mipmapped = true
+			levels = mipmapLevels(width, height)
+		}
+	}
+
+	minFilter := opts.MinFilter
+	if minFilter == 0 {
+		minFilter = gl.LINEAR
+		if mipmapped {
+			minFilter = gl.LINEAR_MIPMAP_LINEAR
+		}
+	}
+	magFilter := opts.MagFilter
+	if magFilter == 0 {
+		magFilter = gl.LINEAR
+	}
+	wrap := gl.Enum(gl.REPEAT)
+	if target == gl.TEXTURE_CUBE_MAP || npot {
+		// seams between cubemap faces are visible unless edges are clamped.
+		wrap = gl.CLAMP_TO_EDGE
+	}
+	wrapS, wrapT := opts.WrapS, opts.WrapT
+	if wrapS == 0 {
+		wrapS = wrap
+	}
+	if wrapT == 0 {
+		wrapT = wrap
+	}
+
+	glctx.TexParameteri(target, gl.TEXTURE_MIN_FILTER, int(minFilter))
+	glctx.TexParameteri(target, gl.TEXTURE_MAG_FILTER, int(magFilter))
+	glctx.TexParameteri(target, gl.TEXTURE_WRAP_S, int(wrapS))
+	glctx.TexParameteri(target, gl.TEXTURE_WRAP_T, int(wrapT))
+
+	if max := QueryCapabilities(glctx).MaxAnisotropy; opts.Anisotropy > 1 && max > 0 {
+		aniso := opts.Anisotropy
+		if aniso > max {
+			aniso = max
+		}
+		glctx.TexParameterf(target, glTextureMaxAnisotropy, aniso)
+	}
+	return levels
+}
+
+// setUnpackAlignment sets UNPACK_ALIGNMENT and returns a function which
+// restores its previous value.
+func setUnpackAlignment(glctx gl.Context, align int) (restore func()) {
+	prev := glctx.GetInteger(gl.UNPACK_ALIGNMENT)
+	if prev == align {
+		return func() {}
+	}
+	glctx.PixelStorei(gl.UNPACK_ALIGNMENT, int32(align))
+	return func() { glctx.PixelStorei(gl.UNPACK_ALIGNMENT, int32(prev)) }
+}
+
+// premultiply multiplies the color components of pix, which holds pixels
+// with the given number of components and alpha last, by alpha.
+func premultiply(pix []byte, channels int) {
+	for i := 0; i+channels <= len(pix); i += channels {
+		a := uint32(pix[i+channels-1])
+		if a == 0xff {
+			continue
+		}
+		for j := i; j < i+channels-1; j++ {
+			pix[j] = uint8((uint32(pix[j])*a + 0x7f) / 0xff)
+		}
+	}
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+// mipmapLevels returns the number of levels in a complete mipmap chain for a
+// texture of the given size.
+func mipmapLevels(width, height int) int {
+	n := 1
+	for width > 1 || height > 1 {
+		width /= 2
+		height /= 2
+		n++
+	}
+	return n
+}
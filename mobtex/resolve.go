@@ -0,0 +1,119 @@
+package mobtex
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/dds"
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/etc"
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/ktx"
+	"golang.org/x/mobile/asset"
+	"golang.org/x/mobile/gl"
+)
+
+// compressedVariants are the extensions of assets which may hold compressed
+// data, in order of preference.  ASTC data is distinguished from the ETC data
+// usually found in ktx files by its extension.
+var compressedVariants = []string{".astc.ktx", ".ktx", ".ktx2", ".dds"}
+
+// imageVariants are the extensions of uncompressed image assets, in order of
+// preference.
+var imageVariants = []string{".png", ".jpg", ".jpeg", ".tga", ".bmp"}
+
+// ResolvePath returns the path of the asset which best provides the texture
+// name, a path without an extension, on the device of glctx.  Compressed ktx
+// and dds assets are preferred if the device can sample their format, ASTC in
+// name.astc.ktx before ETC in name.ktx before S3TC in name.dds.  Otherwise an
+// uncompressed image such as name.png is used, and failing that a compressed
+// asset which mobtex can decompress in software.
+func ResolvePath(glctx gl.Context, name string) (string, error) {
+	caps := QueryCapabilities(glctx)
+	var fallback string
+	for _, ext := range compressedVariants {
+		path := name + ext
+		format, err := assetFormat(path)
+		if err != nil {
+			continue
+		}
+		if format == 0 || caps.HasCompressedFormat(format) {
+			return path, nil
+		}
+		if fallback == "" && softwareFormat(format) {
+			fallback = path
+		}
+	}
+	for _, ext := range imageVariants {
+		f, err := asset.Open(name + ext)
+		if err != nil {
+			continue
+		}
+		f.Close()
+		return name + ext, nil
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no texture asset found for %s", name)
+}
+
+// assetFormat returns the compressed internal format of the ktx or dds asset
+// at path, or zero if the asset holds uncompressed data.
+func assetFormat(path string) (gl.Enum, error) {
+	f, err := asset.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(path, ".dds") {
+		h, err := dds.DecodeHeader(f)
+		if err != nil {
+			return 0, err
+		}
+		format, err := h.Format()
+		if err != nil {
+			return 0, err
+		}
+		return gl.Enum(format.GLInternalFormat(h.SRGB())), nil
+	}
+
+	r := bufio.NewReader(f)
+	id, err := r.Peek(12)
+	if err != nil {
+		return 0, err
+	}
+	var header *ktx.Header
+	if ktx.IsKTX2(id) {
+		h2, err := ktx.DecodeKTX2Header(r)
+		if err != nil {
+			return 0, err
+		}
+		header, err = h2.GLHeader()
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		header, err = ktx.DecodeHeader(r)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if header.GLType != 0 {
+		return 0, nil
+	}
+	return gl.Enum(header.GLInternalFormat), nil
+}
+
+// softwareFormat returns true if mobtex can decompress format data for
+// devices which cannot sample it.
+func softwareFormat(format gl.Enum) bool {
+	if f, _, ok := etc.FormatFromGL(uint32(format)); ok {
+		return !f.Signed()
+	}
+	switch format {
+	case 0x83F0, 0x83F1, 0x83F2, 0x83F3, 0x8C4C, 0x8C4D, 0x8C4E, 0x8C4F: // S3TC
+		return true
+	}
+	return false
+}
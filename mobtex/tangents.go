@@ -0,0 +1,124 @@
+package mobtex
+
+import "golang.org/x/mobile/exp/f32"
+
+// GenerateTangents sets the tangents T of obj for normal mapping, following
+// the MikkTSpace algorithm used by common modeling tools and normal map
+// bakers, so that baked normal maps are shaded without seams.  The tangent of
+// each triangle is projected onto the tangent plane of each of its vertices,
+// then averaged, weighted by the angle of the triangle at the vertex, with the
+// tangents of the other triangles sharing the vertex's position, texture
+// coordinate, and normal.  Triangles with mirrored texture coordinates are
+// averaged separately and have a handedness of -1.
+//
+// Tangents depend on the normals and texture coordinates of obj, so they are
+// generated after any normals and before OrientUV.
+func (obj *Obj) GenerateTangents() {
+	ntri := len(obj.V) / 3
+
+	// the direction of increasing u on each triangle and whether its
+	// texture coordinates keep the winding of its vertices.  Triangles
+	// with degenerate texture coordinates have no direction.
+	dirs := make([]f32.Vec3, ntri)
+	preserving := make([]bool, ntri)
+	for t := range dirs {
+		p0, p1, p2 := obj.V[3*t], obj.V[3*t+1], obj.V[3*t+2]
+		t0, t1, t2 := obj.VT[3*t], obj.VT[3*t+1], obj.VT[3*t+2]
+		var d1, d2 f32.Vec3
+		d1.Sub(&p1, &p0)
+		d2.Sub(&p2, &p0)
+		du1, du2 := t1[0]-t0[0], t2[0]-t0[0]
+		dv1, dv2 := t1[1]-t0[1], t2[1]-t0[1]
+		area := du1*dv2 - dv1*du2
+		preserving[t] = area > 0
+		if area == 0 {
+			continue
+		}
+		for k := range dirs[t] {
+			dirs[t][k] = (dv2*d1[k] - dv1*d2[k]) / area
+		}
+		normalize(&dirs[t])
+	}
+
+	// corners are collected by vertex and orientation.
+	type tangentKey struct {
+		vertex     packedVertex
+		preserving bool
+	}
+	sums := make(map[tangentKey]f32.Vec3)
+	for i := 0; i < 3*ntri; i++ {
+		t := i / 3
+		if dirs[t] == (f32.Vec3{}) {
+			continue
+		}
+		tangent := projectTangent(dirs[t], obj.VN[i])
+		if !normalize(&tangent) {
+			continue
+		}
+		a, b, c := obj.V[i], obj.V[3*t+(i+1)%3], obj.V[3*t+(i+2)%3]
+		angle := cornerAngle(a, b, c)
+		key := tangentKey{obj.packedVertex(i), preserving[t]}
+		sum := sums[key]
+		for k := range sum {
+			sum[k] += angle * tangent[k]
+		}
+		sums[key] = sum
+	}
+
+	obj.T = make([]f32.Vec4, len(obj.V))
+	for i := 0; i < 3*ntri; i++ {
+		t := i / 3
+		vertex, keep := obj.packedVertex(i), preserving[t]
+		sum := sums[tangentKey{vertex, keep}]
+		if dirs[t] == (f32.Vec3{}) {
+			// triangles with degenerate texture coordinates share the
+			// tangent of any other triangle at the vertex.
+			if s, ok := sums[tangentKey{vertex, true}]; ok {
+				sum, keep = s, true
+			} else if s, ok := sums[tangentKey{vertex, false}]; ok {
+				sum, keep = s, false
+			} else {
+				keep = true
+			}
+		}
+		if !normalize(&sum) {
+			sum = perpendicular(obj.VN[i])
+		}
+		w := float32(1)
+		if !keep {
+			w = -1
+		}
+		obj.T[i] = f32.Vec4{sum[0], sum[1], sum[2], w}
+	}
+}
+
+// GenerateTangents sets the tangents of vbo like Obj.GenerateTangents.
+// Vertices shared by triangles are split where their tangents differ, as
+// along mirrored texture seams, so vbo is indexed again.
+func (vbo *VBO) GenerateTangents() {
+	obj := vbo.Unindex()
+	obj.GenerateTangents()
+	*vbo = *IndexVBO(obj)
+}
+
+func (obj *Obj) packedVertex(i int) packedVertex {
+	return packedVertex{V: obj.V[i], VT: obj.VT[i], VN: obj.VN[i]}
+}
+
+// projectTangent returns t without its component along the normal n.
+func projectTangent(t, n f32.Vec3) f32.Vec3 {
+	d := n.Dot(&t)
+	return f32.Vec3{t[0] - d*n[0], t[1] - d*n[1], t[2] - d*n[2]}
+}
+
+// perpendicular returns a unit vector perpendicular to n, the tangent of
+// vertices whose texture coordinates give no direction.
+func perpendicular(n f32.Vec3) f32.Vec3 {
+	axis := f32.Vec3{1, 0, 0}
+	if abs32(n[0]) > abs32(n[1]) && abs32(n[0]) > abs32(n[2]) {
+		axis = f32.Vec3{0, 1, 0}
+	}
+	t := projectTangent(axis, n)
+	normalize(&t)
+	return t
+}
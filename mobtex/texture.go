@@ -0,0 +1,107 @@
+package mobtex
+
+import "golang.org/x/mobile/gl"
+
+// Origin describes where the first row of texel data in a texture lies in the
+// source image.  Texture coordinate T=0 samples the first row so meshes must
+// account for the Origin of the texture applied to them.
+type Origin int
+
+// Possible Origin values.
+const (
+	// OriginBottomLeft textures store the bottom row of the image first, as
+	// in the OpenGL convention.  OBJ texture coordinates assume this origin.
+	OriginBottomLeft Origin = iota
+
+	// OriginTopLeft textures store the top row of the image first, as in
+	// KTX files written with "S=r,T=d" orientation, DDS files, and images
+	// decoded with the image package.
+	OriginTopLeft
+)
+
+func (o Origin) String() string {
+	switch o {
+	case OriginBottomLeft:
+		return "bottom-left"
+	case OriginTopLeft:
+		return "top-left"
+	default:
+		return "unknown"
+	}
+}
+
+// Texture describes a texture loaded into a gl.Context.
+type Texture struct {
+	gl.Texture
+
+	// Target is the target the texture must be bound to, TEXTURE_2D or
+	// TEXTURE_CUBE_MAP.
+	Target gl.Enum
+
+	// Width and Height are the dimensions of the base mipmap level in
+	// texels.
+	Width  int
+	Height int
+
+	// Levels is the number of mipmap levels in the texture, including any
+	// generated by the loader.
+	Levels int
+
+	// Format is the internal format of the texture, an uncompressed format
+	// like RGBA or a compressed format like COMPRESSED_RGBA_S3TC_DXT5_EXT.
+	Format gl.Enum
+
+	// Compressed is true if Format is a compressed format.
+	Compressed bool
+
+	// Alpha is true if Format has an alpha channel.
+	Alpha bool
+
+	// Origin is the location of the first row of texel data in the source
+	// image.
+	Origin Origin
+
+	glctx gl.Context
+}
+
+// Release deletes the texture from the gl.Context it was loaded into.  It is
+// safe to call Release more than once, or on a nil *Texture.
+func (t *Texture) Release() {
+	if t == nil || t.glctx == nil {
+		return
+	}
+	t.glctx.DeleteTexture(t.Texture)
+	t.Texture = gl.Texture{}
+	t.glctx = nil
+}
+
+// formatHasAlpha returns true if the internal format has an alpha channel.
+func formatHasAlpha(format gl.Enum) bool {
+	switch {
+	case format == gl.RGBA, format == gl.LUMINANCE_ALPHA, format == gl.ALPHA:
+		return true
+	case format == glSRGBAlpha:
+		return true
+	case format >= 0x83F1 && format <= 0x83F3: // S3TC RGBA
+		return true
+	case format >= 0x8C4D && format <= 0x8C4F: // S3TC sRGB alpha
+		return true
+	case format == 0x8C02 || format == 0x8C03: // PVRTC RGBA
+		return true
+	case format >= 0x9276 && format <= 0x9279: // ETC2 alpha
+		return true
+	case format == 0x8E8C || format == 0x8E8D: // BPTC unorm
+		return true
+	case format >= 0x93B0 && format <= 0x93BD, format >= 0x93D0 && format <= 0x93DD: // ASTC
+		return true
+	}
+	return false
+}
+
+// flip returns the Origin of texel data after its rows are reversed.
+func (o Origin) flip() Origin {
+	if o == OriginTopLeft {
+		return OriginBottomLeft
+	}
+	return OriginTopLeft
+}
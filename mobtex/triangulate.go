@@ -0,0 +1,95 @@
+package mobtex
+
+import "golang.org/x/mobile/exp/f32"
+
+// triangulate splits the polygon with vertices p into triangles by ear
+// clipping and returns the indices of their vertices in p, three for each
+// triangle, keeping the winding of the polygon.  Concave polygons are
+// supported, self-intersecting and degenerate polygons are split into a fan
+// where no ear can be found.
+func triangulate(p []f32.Vec3) []int {
+	n := len(p)
+	if n == 3 {
+		return []int{0, 1, 2}
+	}
+	tris := make([]int, 0, 3*(n-2))
+
+	// the polygon is projected onto the axis-aligned plane where its area
+	// is largest, found from its Newell normal.
+	var normal [3]float32
+	for i := range p {
+		a, b := p[i], p[(i+1)%n]
+		normal[0] += (a[1] - b[1]) * (a[2] + b[2])
+		normal[1] += (a[2] - b[2]) * (a[0] + b[0])
+		normal[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+	axis := 0
+	for k := 1; k < 3; k++ {
+		if abs32(normal[k]) > abs32(normal[axis]) {
+			axis = k
+		}
+	}
+	if normal[axis] == 0 {
+		return fan(tris, n)
+	}
+	// u and v follow axis cyclically so that the projected polygon winds
+	// counter-clockwise when the normal points along the positive axis.
+	u, v := (axis+1)%3, (axis+2)%3
+	sign := float32(1)
+	if normal[axis] < 0 {
+		sign = -1
+	}
+	cross := func(a, b, c int) float32 {
+		pa, pb, pc := p[a], p[b], p[c]
+		return sign * ((pb[u]-pa[u])*(pc[v]-pa[v]) - (pb[v]-pa[v])*(pc[u]-pa[u]))
+	}
+
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	for len(remaining) > 3 {
+		m := len(remaining)
+		ear := -1
+		for i := 0; i < m && ear < 0; i++ {
+			a, b, c := remaining[(i+m-1)%m], remaining[i], remaining[(i+1)%m]
+			if cross(a, b, c) <= 0 {
+				continue // reflex or degenerate
+			}
+			ear = i
+			for _, j := range remaining {
+				if j == a || j == b || j == c || p[j] == p[a] || p[j] == p[b] || p[j] == p[c] {
+					continue
+				}
+				if cross(a, b, j) >= 0 && cross(b, c, j) >= 0 && cross(c, a, j) >= 0 {
+					ear = -1
+					break
+				}
+			}
+		}
+		if ear < 0 {
+			for _, i := range fan(nil, m) {
+				tris = append(tris, remaining[i])
+			}
+			return tris
+		}
+		tris = append(tris, remaining[(ear+m-1)%m], remaining[ear], remaining[(ear+1)%m])
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+	return append(tris, remaining...)
+}
+
+// fan appends the triangles of a fan over a polygon with n vertices to tris.
+func fan(tris []int, n int) []int {
+	for i := 1; i+1 < n; i++ {
+		tris = append(tris, 0, i, i+1)
+	}
+	return tris
+}
+
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,508 @@
+// Package bmp decodes Windows and OS/2 bitmap images.  Importing the package
+// registers the "bmp" format with the image package.
+package bmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM", Decode, DecodeConfig)
+}
+
+// A FormatError reports that the input is not a valid bmp image.
+type FormatError string
+
+func (e FormatError) Error() string { return "bmp: invalid format: " + string(e) }
+
+// An UnsupportedError reports that the input uses a valid but unimplemented
+// bmp feature.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string { return "bmp: unsupported feature: " + string(e) }
+
+// MaxPixels is the largest number of pixels Decode will allocate an image
+// for.  Uncompressed images are only allocated once their pixel data has been
+// read, but a run-length encoded image may end after a few bytes of data, so
+// a short file can still make Decode allocate MaxPixels bytes.
+var MaxPixels = 1 << 26
+
+// Compression methods.
+const (
+	compressionRGB            = 0
+	compressionRLE8           = 1
+	compressionRLE4           = 2
+	compressionBitfields      = 3
+	compressionJPEG           = 4
+	compressionPNG            = 5
+	compressionAlphaBitfields = 6
+)
+
+// Info header sizes.
+const (
+	coreHeaderSize = 12
+	infoHeaderSize = 40
+	v2HeaderSize   = 52
+	v3HeaderSize   = 56
+	v4HeaderSize   = 108
+	v5HeaderSize   = 124
+)
+
+const fileHeaderSize = 14
+
+// Header contains the bitmap file and info header fields needed to decode
+// the pixel data.
+type Header struct {
+	// DataOffset is the offset of the pixel data from the start of the file.
+	DataOffset uint32
+
+	// InfoSize is the size of the info header, which identifies its version.
+	InfoSize uint32
+
+	Width  int
+	Height int
+
+	// TopDown is true if the first row of pixel data is the top of the
+	// image, indicated by a negative height in the file.
+	TopDown bool
+
+	BitCount    int
+	Compression uint32
+
+	// Masks select the red, green, blue, and alpha bits of 16 and 32-bit
+	// pixels.  A zero alpha mask indicates an opaque image.
+	RedMask   uint32
+	GreenMask uint32
+	BlueMask  uint32
+	AlphaMask uint32
+
+	Palette color.Palette
+}
+
+// Decode reads a bmp image from r.  Images with 8 or fewer bits per pixel are
+// returned as an *image.Paletted, all others as an *image.NRGBA.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := DecodeHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	return decodePixels(br, h)
+}
+
+// DecodeConfig returns the color model and dimensions of a bmp image without
+// decoding the entire image.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := DecodeHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return h.config(), nil
+}
+
+func (h *Header) config() image.Config {
+	var model color.Model = color.NRGBAModel
+	if h.Palette != nil {
+		model = h.Palette
+	}
+	return image.Config{ColorModel: model, Width: h.Width, Height: h.Height}
+}
+
+// DecodeHeader reads the file header, info header, bitfield masks, and color
+// table from r, leaving r positioned at the start of the pixel data.
+func DecodeHeader(r io.Reader) (*Header, error) {
+	var b [fileHeaderSize + 4]byte
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if b[0] != 'B' || b[1] != 'M' {
+		return nil, FormatError("not a bmp file")
+	}
+	h := &Header{
+		DataOffset: binary.LittleEndian.Uint32(b[10:14]),
+		InfoSize:   binary.LittleEndian.Uint32(b[14:18]),
+	}
+	switch h.InfoSize {
+	case coreHeaderSize, infoHeaderSize, v2HeaderSize, v3HeaderSize, v4HeaderSize, v5HeaderSize:
+	case 64: // OS/2 BITMAPINFOHEADER2 shares the fields of a 40-byte header
+	default:
+		return nil, UnsupportedError(fmt.Sprintf("info header size %d", h.InfoSize))
+	}
+	info := make([]byte, h.InfoSize-4)
+	_, err = io.ReadFull(r, info)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	read := fileHeaderSize + h.InfoSize
+
+	var height int
+	paletteEntrySize := 4
+	if h.InfoSize == coreHeaderSize {
+		h.Width = int(binary.LittleEndian.Uint16(info[0:2]))
+		height = int(int16(binary.LittleEndian.Uint16(info[2:4])))
+		h.BitCount = int(binary.LittleEndian.Uint16(info[6:8]))
+		paletteEntrySize = 3
+	} else {
+		h.Width = int(int32(binary.LittleEndian.Uint32(info[0:4])))
+		height = int(int32(binary.LittleEndian.Uint32(info[4:8])))
+		h.BitCount = int(binary.LittleEndian.Uint16(info[10:12]))
+		h.Compression = binary.LittleEndian.Uint32(info[12:16])
+	}
+	if height < 0 {
+		h.TopDown = true
+		height = -height
+	}
+	h.Height = height
+	if h.Width <= 0 || h.Height <= 0 {
+		return nil, FormatError(fmt.Sprintf("invalid dimensions %dx%d", h.Width, height))
+	}
+	if h.Width > MaxPixels/h.Height {
+		return nil, UnsupportedError(fmt.Sprintf("image size %dx%d", h.Width, h.Height))
+	}
+
+	switch h.Compression {
+	case compressionRGB:
+	case compressionRLE8:
+		if h.BitCount != 8 {
+			return nil, FormatError("rle8 compression requires 8 bits per pixel")
+		}
+	case compressionRLE4:
+		if h.BitCount != 4 {
+			return nil, FormatError("rle4 compression requires 4 bits per pixel")
+		}
+	case compressionBitfields, compressionAlphaBitfields:
+		if h.BitCount != 16 && h.BitCount != 32 {
+			return nil, UnsupportedError(fmt.Sprintf("bitfields with %d bits per pixel", h.BitCount))
+		}
+	case compressionJPEG, compressionPNG:
+		return nil, UnsupportedError("embedded jpeg or png data")
+	default:
+		return nil, UnsupportedError(fmt.Sprintf("compression method %d", h.Compression))
+	}
+	if h.InfoSize == 64 && h.Compression > compressionRLE4 {
+		// OS/2 uses these values for huffman and 24-bit rle compression
+		return nil, UnsupportedError(fmt.Sprintf("os/2 compression method %d", h.Compression))
+	}
+	if h.TopDown && (h.Compression == compressionRLE8 || h.Compression == compressionRLE4) {
+		return nil, FormatError("top-down images cannot be compressed")
+	}
+
+	// bitfield masks follow a 40-byte header but are part of later versions.
+	switch h.BitCount {
+	case 1, 2, 4, 8, 24:
+	case 16:
+		h.RedMask, h.GreenMask, h.BlueMask = 0x7c00, 0x03e0, 0x001f
+	case 32:
+		h.RedMask, h.GreenMask, h.BlueMask = 0xff0000, 0x00ff00, 0x0000ff
+	default:
+		return nil, UnsupportedError(fmt.Sprintf("%d bits per pixel", h.BitCount))
+	}
+	if h.Compression == compressionBitfields || h.Compression == compressionAlphaBitfields {
+		masks := info[36:]
+		if h.InfoSize == infoHeaderSize {
+			n := 12
+			if h.Compression == compressionAlphaBitfields {
+				n = 16
+			}
+			masks = make([]byte, n)
+			_, err = io.ReadFull(r, masks)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			read += uint32(n)
+		}
+		h.RedMask = binary.LittleEndian.Uint32(masks[0:4])
+		h.GreenMask = binary.LittleEndian.Uint32(masks[4:8])
+		h.BlueMask = binary.LittleEndian.Uint32(masks[8:12])
+		if len(masks) >= 16 {
+			h.AlphaMask = binary.LittleEndian.Uint32(masks[12:16])
+		}
+	} else if h.InfoSize >= v4HeaderSize && (h.BitCount == 16 || h.BitCount == 32) {
+		// V4 and V5 headers always define an alpha mask.
+		h.AlphaMask = binary.LittleEndian.Uint32(info[48:52])
+	}
+
+	if h.BitCount <= 8 {
+		n := 1 << uint(h.BitCount)
+		if h.InfoSize != coreHeaderSize {
+			if used := int(binary.LittleEndian.Uint32(info[28:32])); used > 0 && used < n {
+				n = used
+			}
+		}
+		table := make([]byte, n*paletteEntrySize)
+		_, err = io.ReadFull(r, table)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		read += uint32(len(table))
+		h.Palette = make(color.Palette, n)
+		for i := range h.Palette {
+			c := table[i*paletteEntrySize:]
+			h.Palette[i] = color.RGBA{c[2], c[1], c[0], 0xff}
+		}
+	}
+
+	if h.DataOffset != 0 {
+		if h.DataOffset < read {
+			return nil, FormatError(fmt.Sprintf("pixel data offset %d overlaps headers", h.DataOffset))
+		}
+		_, err = io.CopyN(ioutil.Discard, r, int64(h.DataOffset-read))
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+	return h, nil
+}
+
+// decodePixels reads the pixel data described by h from r.
+func decodePixels(r *bufio.Reader, h *Header) (image.Image, error) {
+	rect := image.Rect(0, 0, h.Width, h.Height)
+	switch h.Compression {
+	case compressionRLE8, compressionRLE4:
+		img := image.NewPaletted(rect, h.Palette)
+		err := decodeRLE(r, h, img)
+		if err != nil {
+			return nil, err
+		}
+		return img, nil
+	}
+
+	// the pixel data is read before the image is allocated so that a short
+	// file cannot claim a large image.
+	stride := ((h.BitCount*h.Width + 31) / 32) * 4
+	data, err := readFull(r, stride*h.Height)
+	if err != nil {
+		return nil, err
+	}
+	if h.BitCount <= 8 {
+		img := image.NewPaletted(rect, h.Palette)
+		for i := 0; i < h.Height; i++ {
+			row := data[i*stride : (i+1)*stride]
+			pix := img.Pix[h.row(i)*img.Stride:]
+			unpackIndices(pix[:h.Width], row, h.BitCount)
+		}
+		for _, index := range img.Pix {
+			if int(index) >= len(h.Palette) {
+				return nil, FormatError("color index outside of color table")
+			}
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(rect)
+	var r8, g8, b8, a8 bitfield
+	if h.BitCount != 24 {
+		r8, g8, b8, a8 = newBitfield(h.RedMask), newBitfield(h.GreenMask), newBitfield(h.BlueMask), newBitfield(h.AlphaMask)
+	}
+	for i := 0; i < h.Height; i++ {
+		row := data[i*stride : (i+1)*stride]
+		pix := img.Pix[h.row(i)*img.Stride:]
+		for x := 0; x < h.Width; x++ {
+			p := pix[x*4 : x*4+4]
+			var v uint32
+			switch h.BitCount {
+			case 24:
+				p[0], p[1], p[2], p[3] = row[x*3+2], row[x*3+1], row[x*3], 0xff
+				continue
+			case 16:
+				v = uint32(binary.LittleEndian.Uint16(row[x*2:]))
+			case 32:
+				v = binary.LittleEndian.Uint32(row[x*4:])
+			}
+			p[0], p[1], p[2] = r8.value(v), g8.value(v), b8.value(v)
+			p[3] = 0xff
+			if h.AlphaMask != 0 {
+				p[3] = a8.value(v)
+			}
+		}
+	}
+	if h.AlphaMask != 0 && zeroAlpha(img) {
+		// many writers declare an alpha channel but leave it zeroed, such
+		// images are meant to be opaque.
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = 0xff
+		}
+	}
+	return img, nil
+}
+
+// zeroAlpha returns true if every pixel in img is fully transparent.
+func zeroAlpha(img *image.NRGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// row returns the image row of the ith row of pixel data in the file.
+func (h *Header) row(i int) int {
+	if h.TopDown {
+		return i
+	}
+	return h.Height - 1 - i
+}
+
+// unpackIndices unpacks the color indices of len(dst) pixels with the given
+// bit depth from src, most significant bits first.
+func unpackIndices(dst, src []byte, depth int) {
+	if depth == 8 {
+		copy(dst, src)
+		return
+	}
+	perByte := 8 / depth
+	mask := byte(1<<uint(depth) - 1)
+	for x := range dst {
+		shift := uint(8 - depth*(x%perByte+1))
+		dst[x] = src[x/perByte] >> shift & mask
+	}
+}
+
+// bitfield extracts a color component selected by a mask and scales it to 8
+// bits.
+type bitfield struct {
+	mask  uint32
+	shift uint
+	max   uint32
+}
+
+func newBitfield(mask uint32) bitfield {
+	if mask == 0 {
+		return bitfield{}
+	}
+	var shift uint
+	for mask>>shift&1 == 0 {
+		shift++
+	}
+	return bitfield{mask: mask, shift: shift, max: mask >> shift}
+}
+
+func (f bitfield) value(v uint32) uint8 {
+	if f.mask == 0 {
+		return 0
+	}
+	return uint8(uint64(v&f.mask>>f.shift) * 0xff / uint64(f.max))
+}
+
+// decodeRLE decodes run-length encoded 4 or 8-bit pixel data into img.
+// Pixels skipped by delta or end-of-line escapes are left at index zero.
+func decodeRLE(r *bufio.Reader, h *Header, img *image.Paletted) error {
+	readByte := func() (int, error) {
+		c, err := r.ReadByte()
+		return int(c), unexpectedEOF(err)
+	}
+	set := func(x, y, index int) error {
+		if x >= h.Width || y >= h.Height {
+			return FormatError("run-length data overflows image")
+		}
+		if index >= len(h.Palette) {
+			return FormatError("color index outside of color table")
+		}
+		img.Pix[h.row(y)*img.Stride+x] = uint8(index)
+		return nil
+	}
+	nibble := func(c, i int) int {
+		if h.Compression == compressionRLE8 {
+			return c
+		}
+		if i%2 == 0 {
+			return c >> 4
+		}
+		return c & 0x0f
+	}
+
+	x, y := 0, 0
+	for {
+		n, err := readByte()
+		if err != nil {
+			return err
+		}
+		c, err := readByte()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			// encoded run of n pixels
+			for i := 0; i < n; i++ {
+				err = set(x, y, nibble(c, i))
+				if err != nil {
+					return err
+				}
+				x++
+			}
+			continue
+		}
+		switch c {
+		case 0: // end of line
+			x, y = 0, y+1
+		case 1: // end of bitmap
+			return nil
+		case 2: // delta
+			dx, err := readByte()
+			if err != nil {
+				return err
+			}
+			dy, err := readByte()
+			if err != nil {
+				return err
+			}
+			x, y = x+dx, y+dy
+		default: // absolute run of c pixels, padded to a 16-bit boundary
+			size := c
+			if h.Compression == compressionRLE4 {
+				size = (c + 1) / 2
+			}
+			b := make([]byte, size+size%2)
+			_, err = io.ReadFull(r, b)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			for i := 0; i < c; i++ {
+				var v byte
+				if h.Compression == compressionRLE4 {
+					v = b[i/2]
+				} else {
+					v = b[i]
+				}
+				err = set(x, y, nibble(int(v), i))
+				if err != nil {
+					return err
+				}
+				x++
+			}
+		}
+		if y > h.Height {
+			return FormatError("run-length data overflows image")
+		}
+	}
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF for reads which
+// occur after the start of the stream.
+// readFull reads n bytes from r into a buffer which grows as data is read,
+// rather than being allocated up front.
+func readFull(r io.Reader, n int) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return b, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,353 @@
+package bmp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDecodeGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "tutorial*", "assets", "uvtemplate.bmp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no uvtemplate.bmp assets")
+	}
+	for _, p := range files {
+		f, err := os.Open(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, format, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		if format != "bmp" {
+			t.Errorf("%s: decoded as %q", p, format)
+		}
+		nrgba, ok := img.(*image.NRGBA)
+		if !ok {
+			t.Errorf("%s: decoded %T, expected *image.NRGBA", p, img)
+			continue
+		}
+		if nrgba.Rect != image.Rect(0, 0, 512, 512) {
+			t.Errorf("%s: bounds %v", p, nrgba.Rect)
+		}
+		const golden = "47a25eeeef04faf3e70e989cab62200948c10a15c0055ff24747f06952d2e96a"
+		if sum := fmt.Sprintf("%x", sha256.Sum256(nrgba.Pix)); sum != golden {
+			t.Errorf("%s: pixel sha256 %s, expected %s", p, sum, golden)
+		}
+	}
+}
+
+// testBMP describes a bmp file to be encoded by hand.  Masks follow the info
+// header of 40-byte headers and are part of larger headers.
+type testBMP struct {
+	infoSize      int
+	width, height int32
+	bitCount      uint16
+	compression   uint32
+	masks         []uint32
+	palette       []color.RGBA
+	data          []byte
+}
+
+func (b *testBMP) encode() []byte {
+	var info, extra, table bytes.Buffer
+	le := func(buf *bytes.Buffer, v ...interface{}) {
+		for _, v := range v {
+			binary.Write(buf, binary.LittleEndian, v)
+		}
+	}
+	entrySize := 4
+	if b.infoSize == coreHeaderSize {
+		le(&info, uint32(b.infoSize), uint16(b.width), uint16(b.height), uint16(1), b.bitCount)
+		entrySize = 3
+	} else {
+		le(&info, uint32(b.infoSize), b.width, b.height, uint16(1), b.bitCount, b.compression,
+			uint32(len(b.data)), uint32(0), uint32(0), uint32(len(b.palette)), uint32(0))
+		if b.infoSize == infoHeaderSize {
+			le(&extra, b.masks)
+		} else {
+			le(&info, b.masks)
+		}
+		info.Write(make([]byte, b.infoSize-info.Len()))
+	}
+	for _, c := range b.palette {
+		table.Write([]byte{c.B, c.G, c.R, 0}[:entrySize])
+	}
+
+	// a gap between the headers and pixel data is skipped.
+	gap := []byte{0xde, 0xad}
+	offset := fileHeaderSize + info.Len() + extra.Len() + table.Len() + len(gap)
+	var f bytes.Buffer
+	f.WriteString("BM")
+	le(&f, uint32(offset+len(b.data)), uint32(0), uint32(offset))
+	f.Write(info.Bytes())
+	f.Write(extra.Bytes())
+	f.Write(table.Bytes())
+	f.Write(gap)
+	f.Write(b.data)
+	return f.Bytes()
+}
+
+var testPalette = []color.RGBA{{0, 0, 0, 0xff}, {0xff, 0, 0, 0xff}, {0, 0xff, 0, 0xff}, {0, 0, 0xff, 0xff}}
+
+// grayPalette is a complete 8-bit color table, which core headers require.
+var grayPalette = func() []color.RGBA {
+	p := make([]color.RGBA, 256)
+	for i := range p {
+		p[i] = color.RGBA{uint8(i), uint8(i), uint8(i), 0xff}
+	}
+	return p
+}()
+
+func TestDecode(t *testing.T) {
+	red, green, blue, white := color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0xff, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}, color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	for _, test := range []struct {
+		name string
+		bmp  testBMP
+		// pixels of the 2x2 image, top row first
+		pix []color.Color
+	}{
+		{
+			name: "24-bit bottom-up",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 24, data: []byte{
+				0xff, 0, 0, 0xff, 0xff, 0xff, 0, 0, // blue, white, padding
+				0, 0, 0xff, 0, 0xff, 0, 0, 0, // red, green, padding
+			}},
+			pix: []color.Color{red, green, blue, white},
+		},
+		{
+			name: "24-bit top-down",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: -2, bitCount: 24, data: []byte{
+				0, 0, 0xff, 0, 0xff, 0, 0, 0,
+				0xff, 0, 0, 0xff, 0xff, 0xff, 0, 0,
+			}},
+			pix: []color.Color{red, green, blue, white},
+		},
+		{
+			name: "core header 8-bit",
+			bmp: testBMP{infoSize: coreHeaderSize, width: 2, height: 2, bitCount: 8, palette: grayPalette,
+				data: []byte{0x80, 0xff, 0, 0, 0x00, 0x40, 0, 0}},
+			pix: []color.Color{grayPalette[0x00], grayPalette[0x40], grayPalette[0x80], grayPalette[0xff]},
+		},
+		{
+			name: "4-bit",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 4, palette: testPalette,
+				data: []byte{0x30, 0, 0, 0, 0x12, 0, 0, 0}},
+			pix: []color.Color{testPalette[1], testPalette[2], testPalette[3], testPalette[0]},
+		},
+		{
+			name: "1-bit",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 1, palette: testPalette[:2],
+				data: []byte{0x40, 0, 0, 0, 0x80, 0, 0, 0}},
+			pix: []color.Color{testPalette[1], testPalette[0], testPalette[0], testPalette[1]},
+		},
+		{
+			name: "16-bit 555",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 16, data: []byte{
+				0x1f, 0x00, 0xff, 0x7f,
+				0x00, 0x7c, 0xe0, 0x03,
+			}},
+			pix: []color.Color{red, green, blue, white},
+		},
+		{
+			name: "32-bit bitfields with alpha",
+			bmp: testBMP{infoSize: v4HeaderSize, width: 2, height: 2, bitCount: 32, compression: compressionBitfields,
+				masks: []uint32{0xff, 0xff00, 0xff0000, 0xff000000}, data: []byte{
+					0, 0, 0xff, 0x80, 0xff, 0xff, 0xff, 0xff,
+					0xff, 0, 0, 0xff, 0, 0xff, 0, 0x40,
+				}},
+			pix: []color.Color{red, color.NRGBA{0, 0xff, 0, 0x40}, color.NRGBA{0, 0, 0xff, 0x80}, white},
+		},
+		{
+			name: "32-bit zero alpha",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 32, compression: compressionAlphaBitfields,
+				masks: []uint32{0xff0000, 0xff00, 0xff, 0xff000000}, data: []byte{
+					0xff, 0, 0, 0, 0xff, 0xff, 0xff, 0,
+					0, 0, 0xff, 0, 0, 0xff, 0, 0,
+				}},
+			pix: []color.Color{red, green, blue, white},
+		},
+		{
+			name: "rle8",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 8, compression: compressionRLE8, palette: testPalette,
+				data: []byte{
+					1, 3, 0, 0, // blue, end of line
+					0, 2, 1, 0, // delta to (1, 1)
+					1, 2, 0, 1, // green, end of bitmap
+				}},
+			pix: []color.Color{testPalette[0], testPalette[2], testPalette[3], testPalette[0]},
+		},
+		{
+			name: "rle4",
+			bmp: testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 4, compression: compressionRLE4, palette: testPalette,
+				data: []byte{
+					2, 0x31, 0, 0, // blue and red, end of line
+					2, 0x22, 0, 1, // two green, end of bitmap
+				}},
+			pix: []color.Color{testPalette[2], testPalette[2], testPalette[3], testPalette[1]},
+		},
+	} {
+		img, err := Decode(bytes.NewReader(test.bmp.encode()))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if img.Bounds() != image.Rect(0, 0, 2, 2) {
+			t.Errorf("%s: bounds %v", test.name, img.Bounds())
+			continue
+		}
+		_, paletted := img.(*image.Paletted)
+		if paletted != (test.bmp.bitCount <= 8) {
+			t.Errorf("%s: decoded %T", test.name, img)
+		}
+		for i, want := range test.pix {
+			got := img.At(i%2, i/2)
+			if !reflect.DeepEqual(color.NRGBAModel.Convert(got), color.NRGBAModel.Convert(want)) {
+				t.Errorf("%s: pixel (%d, %d) is %v, expected %v", test.name, i%2, i/2, got, want)
+			}
+		}
+	}
+}
+
+func TestDecodeConfig(t *testing.T) {
+	b := &testBMP{infoSize: infoHeaderSize, width: 3, height: -5, bitCount: 4, palette: testPalette}
+	config, err := DecodeConfig(bytes.NewReader(b.encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Width != 3 || config.Height != 5 {
+		t.Errorf("dimensions %dx%d, expected 3x5", config.Width, config.Height)
+	}
+	if p, ok := config.ColorModel.(color.Palette); !ok || len(p) != len(testPalette) {
+		t.Errorf("color model %v, expected the color table", config.ColorModel)
+	}
+}
+
+// malformedBMP returns malformed images rejected by Decode along with the type
+// of error expected for each, including the dimensions which once made
+// LoadBMP allocate without bound.
+func malformedBMP() map[string]struct {
+	b   []byte
+	err error
+} {
+	rgb := func(b testBMP) []byte {
+		b.infoSize, b.bitCount = infoHeaderSize, 24
+		return b.encode()
+	}
+	pal := func(b testBMP) []byte {
+		b.infoSize, b.palette = infoHeaderSize, testPalette
+		return b.encode()
+	}
+	valid := rgb(testBMP{width: 2, height: 2, data: make([]byte, 16)})
+	overlap := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(overlap[10:], 20)
+	infoSize := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(infoSize[14:], 41)
+
+	return map[string]struct {
+		b   []byte
+		err error
+	}{
+		"empty":          {nil, io.ErrUnexpectedEOF},
+		"magic":          {append([]byte("MB"), valid[2:]...), FormatError("")},
+		"info size":      {infoSize, UnsupportedError("")},
+		"truncated info": {valid[:30], io.ErrUnexpectedEOF},
+		"zero width":     {rgb(testBMP{height: 2}), FormatError("")},
+		"zero height":    {rgb(testBMP{width: 2}), FormatError("")},
+		"huge":           {rgb(testBMP{width: 1 << 30, height: 1 << 30}), UnsupportedError("")},
+		"wide":           {rgb(testBMP{width: 1 << 27, height: 1}), UnsupportedError("")},
+		"bit count":      {(&testBMP{infoSize: infoHeaderSize, width: 1, height: 1, bitCount: 12}).encode(), UnsupportedError("")},
+		"rle8 bit count": {pal(testBMP{width: 2, height: 2, bitCount: 4, compression: compressionRLE8}), FormatError("")},
+		"rle top-down":   {pal(testBMP{width: 2, height: -2, bitCount: 8, compression: compressionRLE8}), FormatError("")},
+		"jpeg":           {rgb(testBMP{width: 2, height: 2, compression: compressionJPEG}), UnsupportedError("")},
+		"overlap":        {overlap, FormatError("")},
+		"truncated rows": {valid[:len(valid)-1], io.ErrUnexpectedEOF},
+		// a header alone once allocated the 256MB image it claimed.
+		"missing rows":    {rgb(testBMP{width: 8192, height: 8192}), io.ErrUnexpectedEOF},
+		"truncated table": {pal(testBMP{width: 2, height: 2, bitCount: 8})[:60], io.ErrUnexpectedEOF},
+		"color index": {
+			pal(testBMP{width: 2, height: 2, bitCount: 8, data: []byte{0, 4, 0, 0, 0, 0, 0, 0}}),
+			FormatError(""),
+		},
+		"rle overflow": {
+			pal(testBMP{width: 2, height: 2, bitCount: 8, compression: compressionRLE8, data: []byte{3, 1, 0, 1}}),
+			FormatError(""),
+		},
+		"rle delta": {
+			pal(testBMP{width: 2, height: 2, bitCount: 8, compression: compressionRLE8, data: []byte{0, 2, 0, 9, 1, 1, 0, 1}}),
+			FormatError(""),
+		},
+		"rle truncated": {
+			pal(testBMP{width: 2, height: 2, bitCount: 8, compression: compressionRLE8, data: []byte{0, 5, 1, 2}}),
+			io.ErrUnexpectedEOF,
+		},
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for name, test := range malformedBMP() {
+		_, err := Decode(bytes.NewReader(test.b))
+		if test.err == io.ErrUnexpectedEOF && err != test.err || reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+			t.Errorf("%s: error %#v, expected %T", name, err, test.err)
+		}
+	}
+}
+
+func TestDecodeShortAllocation(t *testing.T) {
+	b := (&testBMP{infoSize: infoHeaderSize, width: 8192, height: 8192, bitCount: 32, data: make([]byte, 1<<10)}).encode()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := Decode(bytes.NewReader(b))
+	runtime.ReadMemStats(&after)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("error %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes decoding a %d byte file", n, len(b))
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	// run-length encoded images are allocated before their data is read,
+	// small images keep each input fast to decode.
+	maxPixels := MaxPixels
+	MaxPixels = 1 << 16
+	f.Cleanup(func() { MaxPixels = maxPixels })
+
+	f.Add((&testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 24, data: make([]byte, 16)}).encode())
+	f.Add((&testBMP{infoSize: v5HeaderSize, width: 2, height: -2, bitCount: 32, compression: compressionBitfields,
+		masks: []uint32{0xff, 0xff00, 0xff0000, 0xff000000}, data: make([]byte, 16)}).encode())
+	f.Add((&testBMP{infoSize: infoHeaderSize, width: 2, height: 2, bitCount: 4, compression: compressionRLE4, palette: testPalette,
+		data: []byte{0, 2, 0x31, 0, 2, 0x22, 0, 1}}).encode())
+	for _, test := range malformedBMP() {
+		f.Add(test.b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		config, err := DecodeConfig(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		img, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		if img.Bounds() != image.Rect(0, 0, config.Width, config.Height) {
+			t.Fatalf("bounds %v, config %dx%d", img.Bounds(), config.Width, config.Height)
+		}
+	})
+}
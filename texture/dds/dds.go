@@ -0,0 +1,287 @@
+// Package dds reads DirectDraw Surface files, including files with the
+// DDS_HEADER_DXT10 extension.
+//
+// A dds file holds the mipmap chain of each of its images in turn.  Cubemaps
+// hold six images, the faces in the order +X, -X, +Y, -Y, +Z, -Z, and arrays of
+// textures or cubemaps hold the images of each element in turn.  Rows are
+// stored top to bottom.
+package dds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A FormatError reports that the input is not a valid dds file.
+type FormatError string
+
+func (e FormatError) Error() string { return "dds: invalid format: " + string(e) }
+
+// An UnsupportedError reports that the input uses a valid but unimplemented
+// dds feature.
+type UnsupportedError string
+
+func (e UnsupportedError) Error() string { return "dds: unsupported feature: " + string(e) }
+
+// MaxDimension is the largest width or height DecodeHeader will accept.
+var MaxDimension = 1 << 16
+
+// MaxSize is the largest amount of image data Read will allocate.
+var MaxSize = 1 << 28
+
+// MaxArraySize is the largest DX10 ArraySize DecodeHeader will accept, which
+// defaults to the largest texture array Direct3D 11 supports.
+var MaxArraySize = 2048
+
+var magic = []byte("DDS ")
+
+// headerSize is the size of DDS_HEADER, which follows the magic number.
+const headerSize = 124
+
+// Header flags.
+const (
+	FlagCaps        = 0x1
+	FlagHeight      = 0x2
+	FlagWidth       = 0x4
+	FlagPitch       = 0x8
+	FlagPixelFormat = 0x1000
+	FlagMipMapCount = 0x20000
+	FlagLinearSize  = 0x80000
+	FlagDepth       = 0x800000
+)
+
+// PixelFormat flags.
+const (
+	PixelAlphaPixels = 0x1
+	PixelAlpha       = 0x2
+	PixelFourCC      = 0x4
+	PixelRGB         = 0x40
+	PixelYUV         = 0x200
+	PixelLuminance   = 0x20000
+	PixelBumpDUDV    = 0x80000
+)
+
+// Caps2 flags.
+const (
+	Caps2Cubemap  = 0x200
+	Caps2AllFaces = 0xfc00
+	Caps2Volume   = 0x200000
+)
+
+// DX10 resource dimensions and flags.
+const (
+	DimensionTexture1D = 2
+	DimensionTexture2D = 3
+	DimensionTexture3D = 4
+	MiscTextureCube    = 0x4
+)
+
+// PixelFormat is the DDS_PIXELFORMAT structure describing the layout of image
+// data.  FourCC identifies compressed data, the bit masks describe
+// uncompressed pixels.
+type PixelFormat struct {
+	Flags       uint32
+	FourCC      [4]byte
+	RGBBitCount uint32
+	RBitMask    uint32
+	GBitMask    uint32
+	BBitMask    uint32
+	ABitMask    uint32
+}
+
+// DX10Header is the DDS_HEADER_DXT10 extension present in files whose FourCC
+// is "DX10".
+type DX10Header struct {
+	DXGIFormat        uint32
+	ResourceDimension uint32
+	MiscFlag          uint32
+	ArraySize         uint32
+	MiscFlags2        uint32
+}
+
+// Header is the DDS_HEADER structure of a dds file.
+type Header struct {
+	Flags             uint32
+	Height            uint32
+	Width             uint32
+	PitchOrLinearSize uint32
+	Depth             uint32
+	MipMapCount       uint32
+	PixelFormat       PixelFormat
+	Caps              uint32
+	Caps2             uint32
+
+	// DX10 is nil unless the PixelFormat FourCC is "DX10".
+	DX10 *DX10Header
+}
+
+// DecodeHeader reads the magic number and header of a dds file from r,
+// including the DX10 extension when it is present.
+func DecodeHeader(r io.Reader) (*Header, error) {
+	var b [4 + headerSize]byte
+	_, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if !bytes.Equal(b[:4], magic) {
+		return nil, FormatError("not a dds file")
+	}
+	if size := binary.LittleEndian.Uint32(b[4:]); size != headerSize {
+		return nil, FormatError(fmt.Sprintf("header size %d", size))
+	}
+	u32 := func(off int) uint32 { return binary.LittleEndian.Uint32(b[4+off:]) }
+
+	h := &Header{
+		Flags:             u32(4),
+		Height:            u32(8),
+		Width:             u32(12),
+		PitchOrLinearSize: u32(16),
+		Depth:             u32(20),
+		MipMapCount:       u32(24),
+		PixelFormat: PixelFormat{
+			Flags:       u32(76),
+			RGBBitCount: u32(84),
+			RBitMask:    u32(88),
+			GBitMask:    u32(92),
+			BBitMask:    u32(96),
+			ABitMask:    u32(100),
+		},
+		Caps:  u32(104),
+		Caps2: u32(108),
+	}
+	copy(h.PixelFormat.FourCC[:], b[4+80:])
+
+	if h.PixelFormat.Flags&PixelFourCC != 0 && string(h.PixelFormat.FourCC[:]) == "DX10" {
+		var ext [20]byte
+		_, err := io.ReadFull(r, ext[:])
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		h.DX10 = &DX10Header{
+			DXGIFormat:        binary.LittleEndian.Uint32(ext[0:]),
+			ResourceDimension: binary.LittleEndian.Uint32(ext[4:]),
+			MiscFlag:          binary.LittleEndian.Uint32(ext[8:]),
+			ArraySize:         binary.LittleEndian.Uint32(ext[12:]),
+			MiscFlags2:        binary.LittleEndian.Uint32(ext[16:]),
+		}
+	}
+
+	err = h.validate()
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// validate checks the dimensions and image layout of h.
+func (h *Header) validate() error {
+	if h.Width == 0 || h.Height == 0 {
+		return FormatError(fmt.Sprintf("dimensions %dx%d", h.Width, h.Height))
+	}
+	if h.Width > uint32(MaxDimension) || h.Height > uint32(MaxDimension) {
+		return UnsupportedError(fmt.Sprintf("dimensions %dx%d", h.Width, h.Height))
+	}
+	if h.DX10 != nil && h.DX10.ArraySize > uint32(MaxArraySize) {
+		return UnsupportedError(fmt.Sprintf("array of %d textures", h.DX10.ArraySize))
+	}
+	if h.Caps2&Caps2Volume != 0 && h.Depth > 1 || h.DX10 != nil && h.DX10.ResourceDimension == DimensionTexture3D {
+		return UnsupportedError("volume texture")
+	}
+	if h.DX10 == nil && h.Caps2&Caps2Cubemap != 0 && h.Caps2&Caps2AllFaces != Caps2AllFaces {
+		return UnsupportedError("partial cubemap")
+	}
+	if h.IsCubemap() && h.Width != h.Height {
+		return FormatError(fmt.Sprintf("cubemap faces are %dx%d", h.Width, h.Height))
+	}
+	if h.NumLevels() > maxLevels(int(h.Width), int(h.Height)) {
+		return FormatError(fmt.Sprintf("%d mipmap levels for a %dx%d image", h.MipMapCount, h.Width, h.Height))
+	}
+	return nil
+}
+
+// IsCubemap returns true if the file holds cubemap faces.
+func (h *Header) IsCubemap() bool {
+	if h.DX10 != nil {
+		return h.DX10.MiscFlag&MiscTextureCube != 0
+	}
+	return h.Caps2&Caps2Cubemap != 0
+}
+
+// NumFaces returns 6 for cubemaps and 1 for other textures.
+func (h *Header) NumFaces() int {
+	if h.IsCubemap() {
+		return 6
+	}
+	return 1
+}
+
+// NumArrayElements returns the number of textures or cubemaps in the file,
+// which is 1 unless the file is an array.
+func (h *Header) NumArrayElements() int {
+	if h.DX10 != nil && h.DX10.ArraySize > 1 {
+		return int(h.DX10.ArraySize)
+	}
+	return 1
+}
+
+// NumImages returns the total number of images, array elements multiplied by
+// cubemap faces, in the file.
+func (h *Header) NumImages() int {
+	return h.NumArrayElements() * h.NumFaces()
+}
+
+// NumLevels returns the number of mipmap levels of each image.  Many writers
+// omit FlagMipMapCount so the MipMapCount field is used whenever it is
+// non-zero.
+func (h *Header) NumLevels() int {
+	if h.MipMapCount == 0 {
+		return 1
+	}
+	return int(h.MipMapCount)
+}
+
+// LevelDimensions returns the width and height of the given mipmap level.
+func (h *Header) LevelDimensions(level int) (width, height int) {
+	width, height = int(h.Width)>>uint(level), int(h.Height)>>uint(level)
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// LevelSize returns the number of bytes of data in the given mipmap level of
+// one image.  Uncompressed rows are not padded.  LevelSize returns 0 if the
+// format of h is not supported.
+func (h *Header) LevelSize(level int) int {
+	f, pf, err := h.format()
+	if err != nil {
+		return 0
+	}
+	w, ht := h.LevelDimensions(level)
+	if f.Compressed() {
+		return f.Size(w, ht)
+	}
+	return (w*int(pf.RGBBitCount) + 7) / 8 * ht
+}
+
+// maxLevels returns the length of a complete mipmap chain.
+func maxLevels(width, height int) int {
+	n := 1
+	for width > 1 || height > 1 {
+		width, height = width/2, height/2
+		n++
+	}
+	return n
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,399 @@
+package dds
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// goldenDDS describes the dds files shipped as tutorial assets.  Files with
+// the same name in different tutorials are identical.
+var goldenDDS = map[string]struct {
+	size   uint32
+	levels []int
+	sha256 string
+}{
+	"uvmap.dds": {
+		size:   512,
+		levels: []int{262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "124e3046e716941af03912558f226061c3e1a24e18b7a19997f28815587cda6c",
+	},
+	"Holstein.dds": {
+		size:   1024,
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "1ab8a41ac3f781820fdd5e8ef15c7b05be5137f809ba39011b4f360bc40d5a4e",
+	},
+	"diffuse.DDS": {
+		size:   1024,
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "a4e19ed705084c6d7b8921757cabe992f964ddd7a47e4ed8aed04811e493e0a7",
+	},
+	"specular.DDS": {
+		size:   1024,
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "b85733cc16b07e843287835528166e63a043f5baf3a15f246b5250dfa2638b03",
+	},
+}
+
+// assetFiles returns the tutorial assets matching any of patterns.
+func assetFiles(t testing.TB, patterns ...string) []string {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join("..", "..", "tutorial*", "assets", pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no assets match %v", patterns)
+	}
+	return files
+}
+
+func TestReadGolden(t *testing.T) {
+	for _, p := range assetFiles(t, "*.dds", "*.DDS") {
+		golden, ok := goldenDDS[filepath.Base(p)]
+		if !ok {
+			t.Errorf("%s: no golden description", p)
+			continue
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, data, err := Read(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		f, err := h.Format()
+		if err != nil || f != BC2 || h.SRGB() {
+			t.Errorf("%s: format %v (srgb %v), expected BC2: %v", p, f, h.SRGB(), err)
+		}
+		if h.Width != golden.size || h.Height != golden.size {
+			t.Errorf("%s: dimensions %dx%d, expected %dx%d", p, h.Width, h.Height, golden.size, golden.size)
+		}
+		if h.IsCubemap() || len(data) != 1 {
+			t.Errorf("%s: %d images (cubemap %v), expected 1", p, len(data), h.IsCubemap())
+			continue
+		}
+		var levels []int
+		sum := sha256.New()
+		for _, level := range data[0] {
+			levels = append(levels, len(level))
+			sum.Write(level)
+		}
+		if !reflect.DeepEqual(levels, golden.levels) {
+			t.Errorf("%s: level sizes %v, expected %v", p, levels, golden.levels)
+		}
+		if s := fmt.Sprintf("%x", sum.Sum(nil)); s != golden.sha256 {
+			t.Errorf("%s: level data sha256 %s, expected %s", p, s, golden.sha256)
+		}
+	}
+}
+
+// testDDS describes a dds file to be encoded by hand.
+type testDDS struct {
+	width, height, depth, mips uint32
+	pf                         PixelFormat
+	caps2                      uint32
+	dx10                       *DX10Header
+	data                       []byte
+}
+
+func (d *testDDS) encode() []byte {
+	var b bytes.Buffer
+	u32 := func(v ...uint32) {
+		for _, v := range v {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+	}
+	b.Write(magic)
+	u32(headerSize, FlagCaps|FlagHeight|FlagWidth|FlagPixelFormat|FlagMipMapCount, d.height, d.width, 0, d.depth, d.mips)
+	u32(make([]uint32, 11)...)
+	u32(32, d.pf.Flags)
+	b.Write(d.pf.FourCC[:])
+	u32(d.pf.RGBBitCount, d.pf.RBitMask, d.pf.GBitMask, d.pf.BBitMask, d.pf.ABitMask)
+	u32(0x1000, d.caps2, 0, 0, 0)
+	if d.dx10 != nil {
+		binary.Write(&b, binary.LittleEndian, d.dx10)
+	}
+	b.Write(d.data)
+	return b.Bytes()
+}
+
+func fourCC(s string) PixelFormat {
+	pf := PixelFormat{Flags: PixelFourCC}
+	copy(pf.FourCC[:], s)
+	return pf
+}
+
+// bgraDDS returns a 3x2 BGRA image with a complete mipmap chain.
+func bgraDDS() *testDDS {
+	var data []byte
+	for i := 0; i < 3*2+1; i++ {
+		data = append(data, byte(30*i), byte(20*i), byte(10*i), byte(255-i))
+	}
+	return &testDDS{
+		width:  3,
+		height: 2,
+		mips:   2,
+		pf:     PixelFormat{Flags: PixelRGB | PixelAlphaPixels, RGBBitCount: 32, RBitMask: 0xff0000, GBitMask: 0xff00, BBitMask: 0xff, ABitMask: 0xff000000},
+		data:   data,
+	}
+}
+
+func TestReadUncompressed(t *testing.T) {
+	h, data, err := Read(bytes.NewReader(bgraDDS().encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, _ := h.Format(); f != RGBA {
+		t.Errorf("format %v, expected RGBA", f)
+	}
+	if len(data) != 1 || len(data[0]) != 2 || len(data[0][0]) != 24 || len(data[0][1]) != 4 {
+		t.Fatalf("unexpected data layout")
+	}
+	img, err := h.DecodeImage(data[0][0], 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		t.Fatalf("decoded %T, expected *image.NRGBA", img)
+	}
+	for i := 0; i < 6; i++ {
+		want := []byte{byte(10 * i), byte(20 * i), byte(30 * i), byte(255 - i)}
+		if p := nrgba.Pix[4*i : 4*i+4]; !bytes.Equal(p, want) {
+			t.Errorf("pixel %d is %v, expected %v", i, p, want)
+		}
+	}
+}
+
+func TestDecodeImageMasks(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		pf   PixelFormat
+		data []byte
+		f    Format
+		pix  []byte
+	}{
+		{
+			name: "565",
+			pf:   PixelFormat{Flags: PixelRGB, RGBBitCount: 16, RBitMask: 0xf800, GBitMask: 0x7e0, BBitMask: 0x1f},
+			data: []byte{0x00, 0xf8, 0xe0, 0x07},
+			f:    RGB,
+			pix:  []byte{255, 0, 0, 255, 0, 255, 0, 255},
+		},
+		{
+			name: "luminance alpha",
+			pf:   PixelFormat{Flags: PixelLuminance | PixelAlphaPixels, RGBBitCount: 16, RBitMask: 0xff, ABitMask: 0xff00},
+			data: []byte{0x40, 0x80, 0xff, 0x00},
+			f:    LuminanceAlpha,
+			pix:  []byte{0x40, 0x40, 0x40, 0x80, 0xff, 0xff, 0xff, 0x00},
+		},
+		{
+			name: "luminance",
+			pf:   PixelFormat{Flags: PixelLuminance, RGBBitCount: 8, RBitMask: 0xff},
+			data: []byte{0x40, 0xc0},
+			f:    Luminance,
+			pix:  []byte{0x40, 0xc0},
+		},
+		{
+			name: "alpha",
+			pf:   PixelFormat{Flags: PixelAlpha, RGBBitCount: 8, ABitMask: 0xff},
+			data: []byte{0x40, 0xc0},
+			f:    Alpha,
+			pix:  []byte{0x40, 0xc0},
+		},
+	} {
+		d := &testDDS{width: 2, height: 1, pf: test.pf, data: test.data}
+		h, data, err := Read(bytes.NewReader(d.encode()))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if f, _ := h.Format(); f != test.f {
+			t.Errorf("%s: format %v, expected %v", test.name, f, test.f)
+		}
+		img, err := h.DecodeImage(data[0][0], 2, 1)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var pix []byte
+		switch img := img.(type) {
+		case *image.NRGBA:
+			pix = img.Pix
+		case *image.Gray:
+			pix = img.Pix
+		case *image.Alpha:
+			pix = img.Pix
+		}
+		if !bytes.Equal(pix, test.pix) {
+			t.Errorf("%s: pixels %v, expected %v", test.name, pix, test.pix)
+		}
+	}
+}
+
+func TestReadDX10Cubemap(t *testing.T) {
+	d := &testDDS{
+		width:  4,
+		height: 4,
+		mips:   3,
+		pf:     fourCC("DX10"),
+		dx10:   &DX10Header{DXGIFormat: 99, ResourceDimension: DimensionTexture2D, MiscFlag: MiscTextureCube, ArraySize: 1},
+		data:   make([]byte, 6*3*16),
+	}
+	h, data, err := Read(bytes.NewReader(d.encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _ := h.Format()
+	if f != BC7 || !h.SRGB() || !h.IsCubemap() {
+		t.Errorf("format %v srgb %v cubemap %v, expected sRGB BC7 cubemap", f, h.SRGB(), h.IsCubemap())
+	}
+	if len(data) != 6 || len(data[0]) != 3 {
+		t.Errorf("%d images of %d levels, expected 6 of 3", len(data), len(data[0]))
+	}
+	if gl := f.GLInternalFormat(h.SRGB()); gl != glCompressedSRGBAlphaBPTC {
+		t.Errorf("GL internal format %#x", gl)
+	}
+}
+
+func TestReadLegacyCubemap(t *testing.T) {
+	d := &testDDS{width: 4, height: 4, mips: 1, pf: fourCC("DXT1"), caps2: Caps2Cubemap | Caps2AllFaces, data: make([]byte, 6*8)}
+	h, data, err := Read(bytes.NewReader(d.encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.NumFaces() != 6 || len(data) != 6 {
+		t.Errorf("%d faces and %d images, expected 6", h.NumFaces(), len(data))
+	}
+}
+
+// malformedDDS returns the malformed files rejected by Read along with the
+// type of error expected for each, including the inputs which once made
+// LoadDDS panic or allocate without bound.
+func malformedDDS() map[string]struct {
+	b   []byte
+	err error
+} {
+	valid := bgraDDS().encode()
+	dxt1 := func(d testDDS) []byte {
+		d.pf = fourCC("DXT1")
+		return d.encode()
+	}
+	badSize := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(badSize[4:], 100)
+
+	return map[string]struct {
+		b   []byte
+		err error
+	}{
+		"empty":            {nil, io.ErrUnexpectedEOF},
+		"magic":            {[]byte("DDS!" + string(valid[4:])), FormatError("")},
+		"header size":      {badSize, FormatError("")},
+		"truncated header": {valid[:64], io.ErrUnexpectedEOF},
+		"truncated dx10": {
+			(&testDDS{width: 4, height: 4, pf: fourCC("DX10")}).encode(),
+			io.ErrUnexpectedEOF,
+		},
+		"zero width":    {(&testDDS{height: 4, pf: fourCC("DXT1")}).encode(), FormatError("")},
+		"huge width":    {dxt1(testDDS{width: 1 << 30, height: 4}), UnsupportedError("")},
+		"huge data":     {dxt1(testDDS{width: 1 << 16, height: 1 << 16, mips: 1}), UnsupportedError("")},
+		"too many mips": {dxt1(testDDS{width: 4, height: 4, mips: 9, data: make([]byte, 48)}), FormatError("")},
+		"volume":        {dxt1(testDDS{width: 4, height: 4, depth: 2, caps2: Caps2Volume}), UnsupportedError("")},
+		"partial cube":  {dxt1(testDDS{width: 4, height: 4, caps2: Caps2Cubemap | 0x400, data: make([]byte, 8)}), UnsupportedError("")},
+		"cube faces":    {dxt1(testDDS{width: 8, height: 4, caps2: Caps2Cubemap | Caps2AllFaces}), FormatError("")},
+		"fourcc":        {(&testDDS{width: 4, height: 4, pf: fourCC("ABCD")}).encode(), UnsupportedError("")},
+		"dxgi format": {
+			(&testDDS{width: 4, height: 4, pf: fourCC("DX10"), dx10: &DX10Header{DXGIFormat: 2}}).encode(),
+			UnsupportedError(""),
+		},
+		// a 1x1 image repeated 1<<25 times once allocated about 800MB.
+		"array size": {
+			(&testDDS{width: 1, height: 1, pf: fourCC("DX10"), dx10: &DX10Header{DXGIFormat: 71, ResourceDimension: DimensionTexture2D, ArraySize: 1 << 25}}).encode(),
+			UnsupportedError(""),
+		},
+		"bit count": {
+			(&testDDS{width: 1, height: 1, pf: PixelFormat{Flags: PixelRGB, RGBBitCount: 12}}).encode(),
+			UnsupportedError(""),
+		},
+		"truncated level": {valid[:len(valid)-1], io.ErrUnexpectedEOF},
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	for name, test := range malformedDDS() {
+		_, _, err := Read(bytes.NewReader(test.b))
+		if err == nil {
+			t.Errorf("%s: no error", name)
+			continue
+		}
+		switch test.err {
+		case io.ErrUnexpectedEOF:
+			if err != io.ErrUnexpectedEOF && !strings.HasSuffix(err.Error(), io.ErrUnexpectedEOF.Error()) {
+				t.Errorf("%s: error %v, expected %v", name, err, test.err)
+			}
+		default:
+			if reflect.TypeOf(err) != reflect.TypeOf(test.err) {
+				t.Errorf("%s: error %#v, expected %T", name, err, test.err)
+			}
+		}
+	}
+}
+
+func TestDecodeImageShort(t *testing.T) {
+	h, data, err := Read(bytes.NewReader(bgraDDS().encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.DecodeImage(data[0][1], 3, 2)
+	if err == nil {
+		t.Errorf("decoded a 3x2 image from %d bytes", len(data[0][1]))
+	}
+}
+
+func FuzzRead(f *testing.F) {
+	// small images keep each input fast to read.
+	maxSize := MaxSize
+	MaxSize = 1 << 20
+	f.Cleanup(func() { MaxSize = maxSize })
+
+	f.Add(bgraDDS().encode())
+	f.Add((&testDDS{width: 4, height: 4, mips: 1, pf: fourCC("DXT1"), caps2: Caps2Cubemap | Caps2AllFaces, data: make([]byte, 6*8)}).encode())
+	for _, test := range malformedDDS() {
+		f.Add(test.b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h, data, err := Read(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		if len(data) != h.NumImages() {
+			t.Fatalf("%d images, expected %d", len(data), h.NumImages())
+		}
+		f, _ := h.Format()
+		for _, levels := range data {
+			for level, b := range levels {
+				if len(b) != h.LevelSize(level) {
+					t.Fatalf("level %d is %d bytes, expected %d", level, len(b), h.LevelSize(level))
+				}
+				w, ht := h.LevelDimensions(level)
+				if !f.Compressed() && w*ht <= 1<<16 {
+					h.DecodeImage(b, w, ht)
+				}
+			}
+		}
+	})
+}
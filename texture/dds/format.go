@@ -0,0 +1,258 @@
+package dds
+
+import "fmt"
+
+// Format identifies the layout of dds image data.
+type Format int
+
+// Supported formats.  Block compressed formats are identified but only
+// uncompressed formats can be decoded by this package, package s3tc decodes
+// BC1, BC2, and BC3 data.
+const (
+	BC1            Format = iota + 1 // DXT1
+	BC2                              // DXT2 and DXT3
+	BC3                              // DXT4 and DXT5
+	BC4                              // ATI1, unsigned single channel
+	BC4Signed                        // signed single channel
+	BC5                              // ATI2, unsigned two channel
+	BC5Signed                        // signed two channel
+	BC7                              // BPTC RGBA
+	RGB                              // uncompressed, described by bit masks
+	RGBA                             // uncompressed with alpha, described by bit masks
+	Luminance                        // uncompressed single channel
+	LuminanceAlpha                   // uncompressed luminance with alpha
+	Alpha                            // uncompressed alpha only
+)
+
+var formatNames = map[Format]string{
+	BC1:            "BC1",
+	BC2:            "BC2",
+	BC3:            "BC3",
+	BC4:            "BC4",
+	BC4Signed:      "BC4_SNORM",
+	BC5:            "BC5",
+	BC5Signed:      "BC5_SNORM",
+	BC7:            "BC7",
+	RGB:            "RGB",
+	RGBA:           "RGBA",
+	Luminance:      "LUMINANCE",
+	LuminanceAlpha: "LUMINANCE_ALPHA",
+	Alpha:          "ALPHA",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Compressed returns true if f is a block compressed format.
+func (f Format) Compressed() bool {
+	return f >= BC1 && f <= BC7
+}
+
+// HasAlpha returns true if f encodes an alpha channel.  BC1 data may or may
+// not use its 1-bit alpha.
+func (f Format) HasAlpha() bool {
+	switch f {
+	case BC1, BC2, BC3, BC7, RGBA, LuminanceAlpha, Alpha:
+		return true
+	}
+	return false
+}
+
+// BlockSize returns the number of bytes in each 4x4 block of a compressed
+// format, or 0 for uncompressed formats.
+func (f Format) BlockSize() int {
+	switch f {
+	case BC1, BC4, BC4Signed:
+		return 8
+	case BC2, BC3, BC5, BC5Signed, BC7:
+		return 16
+	}
+	return 0
+}
+
+// Size returns the number of bytes of compressed f data encoding an image
+// with the given dimensions.
+func (f Format) Size(width, height int) int {
+	return ((width + 3) / 4) * ((height + 3) / 4) * f.BlockSize()
+}
+
+// GL internal formats of compressed data.
+const (
+	glCompressedRGBAS3TCDXT1      = 0x83F1
+	glCompressedRGBAS3TCDXT3      = 0x83F2
+	glCompressedRGBAS3TCDXT5      = 0x83F3
+	glCompressedSRGBAlphaS3TCDXT1 = 0x8C4D
+	glCompressedSRGBAlphaS3TCDXT3 = 0x8C4E
+	glCompressedSRGBAlphaS3TCDXT5 = 0x8C4F
+	glCompressedRedRGTC1          = 0x8DBB
+	glCompressedSignedRedRGTC1    = 0x8DBC
+	glCompressedRGRGTC2           = 0x8DBD
+	glCompressedSignedRGRGTC2     = 0x8DBE
+	glCompressedRGBABPTCUnorm     = 0x8E8C
+	glCompressedSRGBAlphaBPTC     = 0x8E8D
+)
+
+// GLInternalFormat returns the GL internal format of compressed f data.  If
+// srgb is true the sRGB variant is returned for formats which have one.  Zero
+// is returned for uncompressed formats.
+func (f Format) GLInternalFormat(srgb bool) uint32 {
+	pick := func(linear, s uint32) uint32 {
+		if srgb {
+			return s
+		}
+		return linear
+	}
+	switch f {
+	case BC1:
+		return pick(glCompressedRGBAS3TCDXT1, glCompressedSRGBAlphaS3TCDXT1)
+	case BC2:
+		return pick(glCompressedRGBAS3TCDXT3, glCompressedSRGBAlphaS3TCDXT3)
+	case BC3:
+		return pick(glCompressedRGBAS3TCDXT5, glCompressedSRGBAlphaS3TCDXT5)
+	case BC4:
+		return glCompressedRedRGTC1
+	case BC4Signed:
+		return glCompressedSignedRedRGTC1
+	case BC5:
+		return glCompressedRGRGTC2
+	case BC5Signed:
+		return glCompressedSignedRGRGTC2
+	case BC7:
+		return pick(glCompressedRGBABPTCUnorm, glCompressedSRGBAlphaBPTC)
+	}
+	return 0
+}
+
+// Format returns the format of the image data described by h.  An
+// UnsupportedError is returned for valid formats this package does not
+// handle, such as floating point and YUV data.
+func (h *Header) Format() (Format, error) {
+	f, _, err := h.format()
+	return f, err
+}
+
+// SRGB returns true if a DX10 header marks the data as sRGB encoded.
+func (h *Header) SRGB() bool {
+	if h.DX10 == nil {
+		return false
+	}
+	return dxgiFormats[h.DX10.DXGIFormat].srgb
+}
+
+// format returns the format of h and, for uncompressed formats, the pixel
+// format describing its bit masks.  DX10 formats are translated into the
+// equivalent legacy masks.
+func (h *Header) format() (Format, PixelFormat, error) {
+	if h.DX10 != nil {
+		d, ok := dxgiFormats[h.DX10.DXGIFormat]
+		if !ok {
+			return 0, PixelFormat{}, UnsupportedError(fmt.Sprintf("DXGI format %d", h.DX10.DXGIFormat))
+		}
+		return d.format, d.pixel, nil
+	}
+
+	pf := h.PixelFormat
+	switch {
+	case pf.Flags&PixelFourCC != 0:
+		if f, ok := fourCCFormats[string(pf.FourCC[:])]; ok {
+			return f, PixelFormat{}, nil
+		}
+		return 0, PixelFormat{}, UnsupportedError(fmt.Sprintf("FourCC %q", pf.FourCC[:]))
+	case pf.Flags&(PixelYUV|PixelBumpDUDV) != 0:
+		return 0, PixelFormat{}, UnsupportedError(fmt.Sprintf("pixel format flags %#x", pf.Flags))
+	}
+
+	switch pf.RGBBitCount {
+	case 8, 16, 24, 32:
+	default:
+		return 0, PixelFormat{}, UnsupportedError(fmt.Sprintf("%d bits per pixel", pf.RGBBitCount))
+	}
+	alpha := pf.Flags&PixelAlphaPixels != 0 && pf.ABitMask != 0
+	switch {
+	case pf.Flags&PixelRGB != 0:
+		if alpha {
+			return RGBA, pf, nil
+		}
+		return RGB, pf, nil
+	case pf.Flags&PixelLuminance != 0:
+		if alpha {
+			return LuminanceAlpha, pf, nil
+		}
+		return Luminance, pf, nil
+	case pf.Flags&PixelAlpha != 0 && pf.ABitMask != 0:
+		return Alpha, pf, nil
+	}
+	return 0, PixelFormat{}, FormatError(fmt.Sprintf("pixel format flags %#x", pf.Flags))
+}
+
+var fourCCFormats = map[string]Format{
+	"DXT1": BC1,
+	"DXT2": BC2,
+	"DXT3": BC2,
+	"DXT4": BC3,
+	"DXT5": BC3,
+	"ATI1": BC4,
+	"BC4U": BC4,
+	"BC4S": BC4Signed,
+	"ATI2": BC5,
+	"BC5U": BC5,
+	"BC5S": BC5Signed,
+}
+
+// dxgiFormat describes a DXGI_FORMAT value.
+type dxgiFormat struct {
+	format Format
+	pixel  PixelFormat
+	srgb   bool
+}
+
+func masks(bits, r, g, b, a uint32) PixelFormat {
+	pf := PixelFormat{RGBBitCount: bits, RBitMask: r, GBitMask: g, BBitMask: b, ABitMask: a}
+	if a != 0 {
+		pf.Flags |= PixelAlphaPixels
+	}
+	return pf
+}
+
+// dxgiFormats maps the supported DXGI_FORMAT values to formats.  Typeless
+// formats are treated as unsigned normalized data.
+var dxgiFormats = map[uint32]dxgiFormat{
+	24:  {RGBA, masks(32, 0x3ff, 0xffc00, 0x3ff00000, 0xc0000000), false}, // R10G10B10A2_UNORM
+	27:  {RGBA, masks(32, 0xff, 0xff00, 0xff0000, 0xff000000), false},     // R8G8B8A8_TYPELESS
+	28:  {RGBA, masks(32, 0xff, 0xff00, 0xff0000, 0xff000000), false},     // R8G8B8A8_UNORM
+	29:  {RGBA, masks(32, 0xff, 0xff00, 0xff0000, 0xff000000), true},      // R8G8B8A8_UNORM_SRGB
+	49:  {RGB, masks(16, 0xff, 0xff00, 0, 0), false},                      // R8G8_UNORM
+	61:  {Luminance, masks(8, 0xff, 0, 0, 0), false},                      // R8_UNORM
+	65:  {Alpha, masks(8, 0, 0, 0, 0xff), false},                          // A8_UNORM
+	70:  {BC1, PixelFormat{}, false},                                      // BC1_TYPELESS
+	71:  {BC1, PixelFormat{}, false},                                      // BC1_UNORM
+	72:  {BC1, PixelFormat{}, true},                                       // BC1_UNORM_SRGB
+	73:  {BC2, PixelFormat{}, false},                                      // BC2_TYPELESS
+	74:  {BC2, PixelFormat{}, false},                                      // BC2_UNORM
+	75:  {BC2, PixelFormat{}, true},                                       // BC2_UNORM_SRGB
+	76:  {BC3, PixelFormat{}, false},                                      // BC3_TYPELESS
+	77:  {BC3, PixelFormat{}, false},                                      // BC3_UNORM
+	78:  {BC3, PixelFormat{}, true},                                       // BC3_UNORM_SRGB
+	79:  {BC4, PixelFormat{}, false},                                      // BC4_TYPELESS
+	80:  {BC4, PixelFormat{}, false},                                      // BC4_UNORM
+	81:  {BC4Signed, PixelFormat{}, false},                                // BC4_SNORM
+	82:  {BC5, PixelFormat{}, false},                                      // BC5_TYPELESS
+	83:  {BC5, PixelFormat{}, false},                                      // BC5_UNORM
+	84:  {BC5Signed, PixelFormat{}, false},                                // BC5_SNORM
+	85:  {RGB, masks(16, 0xf800, 0x7e0, 0x1f, 0), false},                  // B5G6R5_UNORM
+	86:  {RGBA, masks(16, 0x7c00, 0x3e0, 0x1f, 0x8000), false},            // B5G5R5A1_UNORM
+	87:  {RGBA, masks(32, 0xff0000, 0xff00, 0xff, 0xff000000), false},     // B8G8R8A8_UNORM
+	88:  {RGB, masks(32, 0xff0000, 0xff00, 0xff, 0), false},               // B8G8R8X8_UNORM
+	90:  {RGBA, masks(32, 0xff0000, 0xff00, 0xff, 0xff000000), false},     // B8G8R8A8_TYPELESS
+	91:  {RGBA, masks(32, 0xff0000, 0xff00, 0xff, 0xff000000), true},      // B8G8R8A8_UNORM_SRGB
+	92:  {RGB, masks(32, 0xff0000, 0xff00, 0xff, 0), false},               // B8G8R8X8_TYPELESS
+	93:  {RGB, masks(32, 0xff0000, 0xff00, 0xff, 0), true},                // B8G8R8X8_UNORM_SRGB
+	97:  {BC7, PixelFormat{}, false},                                      // BC7_TYPELESS
+	98:  {BC7, PixelFormat{}, false},                                      // BC7_UNORM
+	99:  {BC7, PixelFormat{}, true},                                       // BC7_UNORM_SRGB
+	115: {RGBA, masks(16, 0xf00, 0xf0, 0xf, 0xf000), false},               // B4G4R4A4_UNORM
+}
@@ -0,0 +1,134 @@
+package dds
+
+import (
+	"fmt"
+	"image"
+	"io"
+)
+
+// Read reads a dds file from r.  The image data is indexed as
+// data[image][level], where images are ordered as described in the package
+// documentation, and each level holds LevelSize(level) bytes.  Read fails
+// with an UnsupportedError if the format of the data is not supported.
+func Read(r io.Reader) (h *Header, data [][][]byte, err error) {
+	h, err = DecodeHeader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = h.Format()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numImages, numLevels := h.NumImages(), h.NumLevels()
+	total := 0
+	for level := 0; level < numLevels; level++ {
+		total += h.LevelSize(level)
+	}
+	if total > MaxSize/numImages {
+		return nil, nil, UnsupportedError(fmt.Sprintf("%d images of %d bytes", numImages, total))
+	}
+
+	// images are appended as they are read so that a short file cannot make
+	// Read allocate every image its header claims.
+	for i := 0; i < numImages; i++ {
+		levels := make([][]byte, numLevels)
+		for level := range levels {
+			b := make([]byte, h.LevelSize(level))
+			_, err = io.ReadFull(r, b)
+			if err != nil {
+				return nil, nil, fmt.Errorf("dds: image %d level %d: %v", i, level, unexpectedEOF(err))
+			}
+			levels[level] = b
+		}
+		data = append(data, levels)
+	}
+	return h, data, nil
+}
+
+// DecodeImage converts one level of uncompressed image data with the given
+// dimensions into an image.  RGB, RGBA, and LuminanceAlpha data yields an
+// *image.NRGBA, Luminance data an *image.Gray, and Alpha data an *image.Alpha.
+// Channels with more than 8 bits are reduced to 8 bits and missing channels
+// are zero, or opaque for alpha.
+func (h *Header) DecodeImage(b []byte, width, height int) (image.Image, error) {
+	f, pf, err := h.format()
+	if err != nil {
+		return nil, err
+	}
+	if f.Compressed() {
+		return nil, UnsupportedError(fmt.Sprintf("decoding %v data", f))
+	}
+	bpp := int(pf.RGBBitCount) / 8
+	if width <= 0 || height <= 0 || len(b) < width*height*bpp {
+		return nil, fmt.Errorf("dds: %d bytes is too short for a %dx%d %v image", len(b), width, height, f)
+	}
+
+	r, g, bl, a := newChannel(pf.RBitMask), newChannel(pf.GBitMask), newChannel(pf.BBitMask), newChannel(pf.ABitMask)
+	rect := image.Rect(0, 0, width, height)
+	pixels := func(fn func(i int, v uint32)) {
+		for i := 0; i < width*height; i++ {
+			var v uint32
+			for j := bpp - 1; j >= 0; j-- {
+				v = v<<8 | uint32(b[i*bpp+j])
+			}
+			fn(i, v)
+		}
+	}
+
+	switch f {
+	case Luminance:
+		img := image.NewGray(rect)
+		pixels(func(i int, v uint32) { img.Pix[i] = r.value(v, 0) })
+		return img, nil
+	case Alpha:
+		img := image.NewAlpha(rect)
+		pixels(func(i int, v uint32) { img.Pix[i] = a.value(v, 0xff) })
+		return img, nil
+	case LuminanceAlpha:
+		img := image.NewNRGBA(rect)
+		pixels(func(i int, v uint32) {
+			p := img.Pix[i*4 : i*4+4]
+			p[0] = r.value(v, 0)
+			p[1], p[2], p[3] = p[0], p[0], a.value(v, 0xff)
+		})
+		return img, nil
+	}
+	if f == RGB {
+		a = channel{}
+	}
+	img := image.NewNRGBA(rect)
+	pixels(func(i int, v uint32) {
+		p := img.Pix[i*4 : i*4+4]
+		p[0], p[1], p[2], p[3] = r.value(v, 0), g.value(v, 0), bl.value(v, 0), a.value(v, 0xff)
+	})
+	return img, nil
+}
+
+// channel extracts one component of a pixel using a bit mask.
+type channel struct {
+	mask  uint32
+	shift uint
+	max   uint32
+}
+
+func newChannel(mask uint32) channel {
+	if mask == 0 {
+		return channel{}
+	}
+	var shift uint
+	for mask>>shift&1 == 0 {
+		shift++
+	}
+	return channel{mask: mask, shift: shift, max: mask >> shift}
+}
+
+// value returns the component of pixel v scaled to 8 bits, or def if the
+// channel is not present.
+func (c channel) value(v uint32, def uint8) uint8 {
+	if c.mask == 0 {
+		return def
+	}
+	x := uint64(v&c.mask) >> c.shift
+	return uint8((x*255 + uint64(c.max)/2) / uint64(c.max))
+}
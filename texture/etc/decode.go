@@ -0,0 +1,302 @@
+package etc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// Decode decodes an image with the given dimensions from the f data in b.
+// Color formats are returned as an *image.NRGBA, R11 formats as an
+// *image.Gray16, and RG11 formats as an *image.NRGBA64 with zero blue and
+// opaque alpha.  Signed values are biased so that -1 maps to zero and 1 maps
+// to the largest unsigned value.
+func Decode(f Format, b []byte, width, height int) (image.Image, error) {
+	if !f.valid() {
+		return nil, fmt.Errorf("etc: unknown format %v", f)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("etc: invalid dimensions %dx%d", width, height)
+	}
+	if len(b) < f.Size(width, height) {
+		return nil, fmt.Errorf("etc: %d bytes is too short for a %dx%d %v image", len(b), width, height, f)
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	blockSize := f.BlockSize()
+	switch f {
+	case R11, SignedR11:
+		img := image.NewGray16(rect)
+		var r [16]uint16
+		forEachBlock(width, height, func(bx, by int) {
+			decodeEAC11(&r, binary.BigEndian.Uint64(b), f.Signed())
+			b = b[blockSize:]
+			forEachTexel(width, height, bx, by, func(x, y, i int) {
+				o := img.PixOffset(x, y)
+				img.Pix[o], img.Pix[o+1] = uint8(r[i]>>8), uint8(r[i])
+			})
+		})
+		return img, nil
+	case RG11, SignedRG11:
+		img := image.NewNRGBA64(rect)
+		var r, g [16]uint16
+		forEachBlock(width, height, func(bx, by int) {
+			decodeEAC11(&r, binary.BigEndian.Uint64(b), f.Signed())
+			decodeEAC11(&g, binary.BigEndian.Uint64(b[8:]), f.Signed())
+			b = b[blockSize:]
+			forEachTexel(width, height, bx, by, func(x, y, i int) {
+				o := img.PixOffset(x, y)
+				p := img.Pix[o : o+8]
+				p[0], p[1] = uint8(r[i]>>8), uint8(r[i])
+				p[2], p[3] = uint8(g[i]>>8), uint8(g[i])
+				p[4], p[5], p[6], p[7] = 0, 0, 0xff, 0xff
+			})
+		})
+		return img, nil
+	}
+
+	img := image.NewNRGBA(rect)
+	var block [16][4]byte
+	forEachBlock(width, height, func(bx, by int) {
+		DecodeBlock(f, &block, b[:blockSize])
+		b = b[blockSize:]
+		forEachTexel(width, height, bx, by, func(x, y, i int) {
+			copy(img.Pix[img.PixOffset(x, y):], block[i][:])
+		})
+	})
+	return img, nil
+}
+
+// DecodeBlock decodes a single block of color data in one of the formats
+// ETC1, RGB8, RGB8A1, or RGBA8 into dst, which receives the non-premultiplied
+// RGBA value of each texel in row-major order.
+func DecodeBlock(f Format, dst *[16][4]byte, block []byte) {
+	switch f {
+	case ETC1, RGB8:
+		// valid ETC1 blocks never use the ETC2 modes so one decoder serves
+		decodeColor(dst, binary.BigEndian.Uint64(block), false)
+	case RGB8A1:
+		decodeColor(dst, binary.BigEndian.Uint64(block), true)
+	case RGBA8:
+		decodeColor(dst, binary.BigEndian.Uint64(block[8:]), false)
+		var a [16]uint8
+		decodeEAC8(&a, binary.BigEndian.Uint64(block))
+		for i := range dst {
+			dst[i][3] = a[i]
+		}
+	}
+}
+
+// forEachBlock calls fn with the texel coordinates of each block in an image
+// of the given size, in the order blocks are stored.
+func forEachBlock(width, height int, fn func(bx, by int)) {
+	for by := 0; by < height; by += 4 {
+		for bx := 0; bx < width; bx += 4 {
+			fn(bx, by)
+		}
+	}
+}
+
+// forEachTexel calls fn with the coordinates of each texel in the block at
+// bx, by which lies within the image and its row-major index in the block.
+func forEachTexel(width, height, bx, by int, fn func(x, y, i int)) {
+	for y := 0; y < 4 && by+y < height; y++ {
+		for x := 0; x < 4 && bx+x < width; x++ {
+			fn(bx+x, by+y, y*4+x)
+		}
+	}
+}
+
+// pixelIndex returns the 2-bit index of the texel at x, y from the low 32
+// bits of a color block.  Texels are stored in column-major order with the
+// most significant bits of all indices preceding the least significant bits.
+func pixelIndex(b uint64, x, y int) int {
+	p := uint(x*4 + y)
+	return int(b>>(p+16)&1)<<1 | int(b>>p&1)
+}
+
+// decodeColor decodes an ETC1 or ETC2 color block.  If punchthrough is true
+// the block is interpreted as RGB8A1 data, where bit 33 marks opaque blocks
+// rather than selecting differential mode.
+func decodeColor(dst *[16][4]byte, b uint64, punchthrough bool) {
+	differential := b>>33&1 != 0
+	opaque := true
+	if punchthrough {
+		opaque = differential
+		differential = true
+	}
+
+	var base [2][3]int
+	if differential {
+		r, g, bl := int(b>>59&31), int(b>>51&31), int(b>>43&31)
+		dr, dg, db := signed3(b>>56), signed3(b>>48), signed3(b>>40)
+		switch {
+		case r+dr < 0 || r+dr > 31:
+			decodeT(dst, b, opaque)
+			return
+		case g+dg < 0 || g+dg > 31:
+			decodeH(dst, b, opaque)
+			return
+		case bl+db < 0 || bl+db > 31:
+			decodePlanar(dst, b)
+			return
+		}
+		base[0] = [3]int{extend5(r), extend5(g), extend5(bl)}
+		base[1] = [3]int{extend5(r + dr), extend5(g + dg), extend5(bl + db)}
+	} else {
+		base[0] = [3]int{extend4(int(b >> 60 & 15)), extend4(int(b >> 52 & 15)), extend4(int(b >> 44 & 15))}
+		base[1] = [3]int{extend4(int(b >> 56 & 15)), extend4(int(b >> 48 & 15)), extend4(int(b >> 40 & 15))}
+	}
+	tables := [2]int{int(b >> 37 & 7), int(b >> 34 & 7)}
+	flip := b>>32&1 != 0
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			sub := x / 2
+			if flip {
+				sub = y / 2
+			}
+			i := pixelIndex(b, x, y)
+			if !opaque && i == 2 {
+				dst[y*4+x] = [4]byte{}
+				continue
+			}
+			m := modifier(tables[sub], i)
+			if !opaque && i == 0 {
+				m = 0
+			}
+			c := base[sub]
+			dst[y*4+x] = [4]byte{clamp255(c[0] + m), clamp255(c[1] + m), clamp255(c[2] + m), 0xff}
+		}
+	}
+}
+
+// signed3 returns the 3-bit two's complement value in the low bits of v.
+func signed3(v uint64) int {
+	d := int(v & 7)
+	if d >= 4 {
+		d -= 8
+	}
+	return d
+}
+
+// decodeT decodes an ETC2 T mode block.
+func decodeT(dst *[16][4]byte, b uint64, opaque bool) {
+	c1 := [3]int{
+		extend4(int(b>>59&3)<<2 | int(b>>56&3)),
+		extend4(int(b >> 52 & 15)),
+		extend4(int(b >> 48 & 15)),
+	}
+	c2 := [3]int{extend4(int(b >> 44 & 15)), extend4(int(b >> 40 & 15)), extend4(int(b >> 36 & 15))}
+	d := thDistances[int(b>>34&3)<<1|int(b>>32&1)]
+	paint := [4][3]int{c1, offset(c2, d), c2, offset(c2, -d)}
+	decodePaint(dst, b, &paint, opaque)
+}
+
+// decodeH decodes an ETC2 H mode block.
+func decodeH(dst *[16][4]byte, b uint64, opaque bool) {
+	r1, g1, b1 := int(b>>59&15), int(b>>56&7)<<1|int(b>>52&1), int(b>>51&1)<<3|int(b>>47&7)
+	r2, g2, b2 := int(b>>43&15), int(b>>39&15), int(b>>35&15)
+	di := int(b>>34&1)<<2 | int(b>>32&1)<<1
+	if r1<<8|g1<<4|b1 >= r2<<8|g2<<4|b2 {
+		di |= 1
+	}
+	d := thDistances[di]
+	c1 := [3]int{extend4(r1), extend4(g1), extend4(b1)}
+	c2 := [3]int{extend4(r2), extend4(g2), extend4(b2)}
+	paint := [4][3]int{offset(c1, d), offset(c1, -d), offset(c2, d), offset(c2, -d)}
+	decodePaint(dst, b, &paint, opaque)
+}
+
+func offset(c [3]int, d int) [3]int {
+	return [3]int{c[0] + d, c[1] + d, c[2] + d}
+}
+
+// decodePaint assigns each texel the paint color selected by its index.
+func decodePaint(dst *[16][4]byte, b uint64, paint *[4][3]int, opaque bool) {
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			i := pixelIndex(b, x, y)
+			if !opaque && i == 2 {
+				dst[y*4+x] = [4]byte{}
+				continue
+			}
+			c := paint[i]
+			dst[y*4+x] = [4]byte{clamp255(c[0]), clamp255(c[1]), clamp255(c[2]), 0xff}
+		}
+	}
+}
+
+// decodePlanar decodes an ETC2 planar mode block, which interpolates between
+// colors at the origin, horizontal, and vertical corners of the block.
+func decodePlanar(dst *[16][4]byte, b uint64) {
+	o := [3]int{
+		extend6(int(b >> 57 & 63)),
+		extend7(int(b>>56&1)<<6 | int(b>>49&63)),
+		extend6(int(b>>48&1)<<5 | int(b>>43&3)<<3 | int(b>>39&7)),
+	}
+	h := [3]int{
+		extend6(int(b>>34&31)<<1 | int(b>>32&1)),
+		extend7(int(b >> 25 & 127)),
+		extend6(int(b >> 19 & 63)),
+	}
+	v := [3]int{
+		extend6(int(b >> 13 & 63)),
+		extend7(int(b >> 6 & 127)),
+		extend6(int(b & 63)),
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			var c [4]byte
+			for i := 0; i < 3; i++ {
+				c[i] = clamp255((x*(h[i]-o[i]) + y*(v[i]-o[i]) + 4*o[i] + 2) >> 2)
+			}
+			c[3] = 0xff
+			dst[y*4+x] = c
+		}
+	}
+}
+
+// eacIndex returns the 3-bit index of the texel at x, y in an EAC block.
+// Texels are stored in column-major order, most significant bits first.
+func eacIndex(b uint64, x, y int) int {
+	return int(b >> (45 - 3*uint(x*4+y)) & 7)
+}
+
+// decodeEAC8 decodes an 8-bit EAC alpha block into dst in row-major order.
+func decodeEAC8(dst *[16]uint8, b uint64) {
+	base := int(b >> 56)
+	mult := int(b >> 52 & 15)
+	table := &eacModifiers[b>>48&15]
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			dst[y*4+x] = clamp255(base + table[eacIndex(b, x, y)]*mult)
+		}
+	}
+}
+
+// decodeEAC11 decodes an 11-bit EAC block into dst in row-major order,
+// extending each value to 16 bits.
+func decodeEAC11(dst *[16]uint16, b uint64, signed bool) {
+	mult := int(b >> 52 & 15)
+	table := &eacModifiers[b>>48&15]
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			m := table[eacIndex(b, x, y)]
+			if mult != 0 {
+				m *= mult * 8
+			}
+			if signed {
+				base := int(int8(b >> 56))
+				if base == -128 {
+					base = -127
+				}
+				v := clamp(base*8+m, -1023, 1023)
+				dst[y*4+x] = uint16((v + 1023) * 0xffff / 2046)
+			} else {
+				v := clamp(int(b>>56)*8+4+m, 0, 2047)
+				dst[y*4+x] = uint16(v<<5 | v>>6)
+			}
+		}
+	}
+}
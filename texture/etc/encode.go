@@ -0,0 +1,596 @@
+package etc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Quality selects the trade-off between encoding speed and fidelity.
+type Quality int
+
+// Available qualities.
+const (
+	// QualityFast derives block colors directly from texel averages.
+	QualityFast Quality = iota
+
+	// QualityMedium searches colors adjacent to the averages and, for ETC2
+	// formats, tries planar mode.
+	QualityMedium
+
+	// QualityHigh searches a larger neighborhood of colors and refines
+	// planar and EAC blocks further.
+	QualityHigh
+)
+
+// Encode compresses img with f at quality q.  Color formats use the RGB(A)
+// channels of img, R11 formats use the red channel and RG11 formats the red
+// and green channels, with signed values biased as described for Decode.
+// The ETC2 T and H modes are never produced, ETC2 data is encoded with the
+// ETC1 compatible modes and planar mode.
+func Encode(f Format, img image.Image, q Quality) ([]byte, error) {
+	if !f.valid() {
+		return nil, fmt.Errorf("etc: unknown format %v", f)
+	}
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return nil, fmt.Errorf("etc: empty image")
+	}
+	width, height := bounds.Dx(), bounds.Dy()
+	out := make([]byte, 0, f.Size(width, height))
+
+	switch f {
+	case R11, SignedR11, RG11, SignedRG11:
+		src := image.NewNRGBA64(image.Rect(0, 0, width, height))
+		draw.Draw(src, src.Rect, img, bounds.Min, draw.Src)
+		var r, g [16]int
+		forEachBlock(width, height, func(bx, by int) {
+			for i := 0; i < 16; i++ {
+				x, y := clampTexel(bx+i%4, by+i/4, width, height)
+				o := src.PixOffset(x, y)
+				r[i] = int(src.Pix[o])<<8 | int(src.Pix[o+1])
+				g[i] = int(src.Pix[o+2])<<8 | int(src.Pix[o+3])
+			}
+			out = appendUint64(out, encodeEAC11(&r, f.Signed(), q))
+			if f == RG11 || f == SignedRG11 {
+				out = appendUint64(out, encodeEAC11(&g, f.Signed(), q))
+			}
+		})
+		return out, nil
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(src, src.Rect, img, bounds.Min, draw.Src)
+	var block [16][4]byte
+	forEachBlock(width, height, func(bx, by int) {
+		for i := 0; i < 16; i++ {
+			x, y := clampTexel(bx+i%4, by+i/4, width, height)
+			copy(block[i][:], src.Pix[src.PixOffset(x, y):])
+		}
+		out = append(out, EncodeBlock(f, &block, q)...)
+	})
+	return out, nil
+}
+
+// EncodeBlock compresses the 16 non-premultiplied RGBA texels of block, in
+// row-major order, with one of the color formats ETC1, RGB8, RGB8A1, or RGBA8.
+func EncodeBlock(f Format, block *[16][4]byte, q Quality) []byte {
+	enc := colorEncoder{q: q, etc2: f != ETC1}
+	switch f {
+	case RGB8A1:
+		enc.punchthrough = true
+		for i := range block {
+			if block[i][3] < 128 {
+				enc.transparent[i] = true
+				enc.anyTransparent = true
+			}
+		}
+	case RGBA8:
+		var a [16]int
+		for i := range block {
+			a[i] = int(block[i][3])
+		}
+		b := appendUint64(nil, encodeEAC8(&a, q))
+		return appendUint64(b, enc.encode(block))
+	}
+	return appendUint64(nil, enc.encode(block))
+}
+
+// clampTexel replicates edge texels into the padding of partial blocks.
+func clampTexel(x, y, width, height int) (int, int) {
+	if x >= width {
+		x = width - 1
+	}
+	if y >= height {
+		y = height - 1
+	}
+	return x, y
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// colorEncoder encodes ETC1 and ETC2 color blocks.
+type colorEncoder struct {
+	q    Quality
+	etc2 bool
+
+	// punchthrough blocks with transparent texels are encoded in
+	// differential mode with the opaque bit clear.
+	punchthrough   bool
+	anyTransparent bool
+	transparent    [16]bool
+}
+
+// subblock holds the result of encoding half of a block with one base color.
+type subblock struct {
+	err     int
+	table   int
+	indices [8]int
+}
+
+// subblockTexels lists the row-major texel indices in each half of a block
+// for each flip value.  Unflipped halves are 2x4 columns, flipped halves are
+// 4x2 rows.
+var subblockTexels = [2][2][8]int{
+	{{0, 1, 4, 5, 8, 9, 12, 13}, {2, 3, 6, 7, 10, 11, 14, 15}},
+	{{0, 1, 2, 3, 4, 5, 6, 7}, {8, 9, 10, 11, 12, 13, 14, 15}},
+}
+
+func (enc *colorEncoder) encode(block *[16][4]byte) uint64 {
+	bestErr := -1
+	var best uint64
+	try := func(b uint64, err int) {
+		if bestErr < 0 || err < bestErr {
+			bestErr, best = err, b
+		}
+	}
+
+	for flip := 0; flip < 2; flip++ {
+		if !enc.punchthrough {
+			b, err := enc.individual(block, flip)
+			try(b, err)
+		}
+		try(enc.differential(block, flip))
+	}
+	if enc.etc2 && enc.q >= QualityMedium && !enc.anyTransparent {
+		b := enc.planar(block)
+		try(b, blockError(block, b, enc.punchthrough))
+	}
+	return best
+}
+
+// individual encodes block in individual mode, with a 4-bit base color for
+// each half.
+func (enc *colorEncoder) individual(block *[16][4]byte, flip int) (uint64, int) {
+	var bases [2][3]int
+	var subs [2]subblock
+	for s := 0; s < 2; s++ {
+		texels := &subblockTexels[flip][s]
+		avg := enc.average(block, texels)
+		bestErr := -1
+		for _, c := range enc.candidates(avg, 15) {
+			sub := enc.fitSubblock(block, texels, [3]int{extend4(c[0]), extend4(c[1]), extend4(c[2])})
+			if bestErr < 0 || sub.err < bestErr {
+				bestErr = sub.err
+				bases[s], subs[s] = c, sub
+			}
+		}
+	}
+	b := uint64(bases[0][0])<<60 | uint64(bases[1][0])<<56 |
+		uint64(bases[0][1])<<52 | uint64(bases[1][1])<<48 |
+		uint64(bases[0][2])<<44 | uint64(bases[1][2])<<40
+	b |= enc.packTables(subs, flip)
+	return b, subs[0].err + subs[1].err
+}
+
+// differential encodes block in differential mode, with a 5-bit base color
+// for the first half and a 3-bit signed offset from it for the second.
+func (enc *colorEncoder) differential(block *[16][4]byte, flip int) (uint64, int) {
+	type candidate struct {
+		c   [3]int
+		sub subblock
+	}
+	var cands [2][]candidate
+	for s := 0; s < 2; s++ {
+		texels := &subblockTexels[flip][s]
+		avg := enc.average(block, texels)
+		for _, c := range enc.candidates(avg, 31) {
+			sub := enc.fitSubblock(block, texels, [3]int{extend5(c[0]), extend5(c[1]), extend5(c[2])})
+			cands[s] = append(cands[s], candidate{c, sub})
+		}
+	}
+
+	bestErr := -1
+	var c0, c1 candidate
+	for _, a := range cands[0] {
+		for _, b := range cands[1] {
+			if !deltaOK(a.c, b.c) {
+				continue
+			}
+			if err := a.sub.err + b.sub.err; bestErr < 0 || err < bestErr {
+				bestErr, c0, c1 = err, a, b
+			}
+		}
+	}
+	if bestErr < 0 {
+		// pull the second color toward the first until it can be encoded
+		a := cands[0][0]
+		var c [3]int
+		for i := range c {
+			c[i] = clamp(cands[1][0].c[i], a.c[i]-4, a.c[i]+3)
+		}
+		texels := &subblockTexels[flip][1]
+		sub := enc.fitSubblock(block, texels, [3]int{extend5(c[0]), extend5(c[1]), extend5(c[2])})
+		c0, c1 = a, candidate{c, sub}
+		bestErr = c0.sub.err + c1.sub.err
+	}
+
+	var b uint64
+	for i, shift := range []uint{59, 51, 43} {
+		d := c1.c[i] - c0.c[i]
+		b |= uint64(c0.c[i])<<shift | uint64(d&7)<<(shift-3)
+	}
+	b |= 1 << 33
+	if enc.punchthrough && enc.anyTransparent {
+		b &^= 1 << 33
+	}
+	b |= enc.packTables([2]subblock{c0.sub, c1.sub}, flip)
+	return b, bestErr
+}
+
+func deltaOK(a, b [3]int) bool {
+	for i := range a {
+		d := b[i] - a[i]
+		if d < -4 || d > 3 {
+			return false
+		}
+	}
+	return true
+}
+
+// packTables packs the table indices, flip bit, and texel indices of a block.
+func (enc *colorEncoder) packTables(subs [2]subblock, flip int) uint64 {
+	b := uint64(subs[0].table)<<37 | uint64(subs[1].table)<<34 | uint64(flip)<<32
+	for s := 0; s < 2; s++ {
+		for j, t := range subblockTexels[flip][s] {
+			p := uint((t%4)*4 + t/4)
+			i := uint64(subs[s].indices[j])
+			b |= (i>>1)<<(p+16) | (i&1)<<p
+		}
+	}
+	return b
+}
+
+// average returns the mean color of the opaque texels in texels.
+func (enc *colorEncoder) average(block *[16][4]byte, texels *[8]int) [3]int {
+	var sum [3]int
+	n := 0
+	for _, t := range texels {
+		if enc.transparent[t] {
+			continue
+		}
+		for i := range sum {
+			sum[i] += int(block[t][i])
+		}
+		n++
+	}
+	if n == 0 {
+		return sum
+	}
+	for i := range sum {
+		sum[i] = (sum[i] + n/2) / n
+	}
+	return sum
+}
+
+// candidates returns the quantized base colors, with components in [0, max],
+// to try for a half block with the given average color.
+func (enc *colorEncoder) candidates(avg [3]int, max int) [][3]int {
+	var q [3]int
+	for i := range q {
+		q[i] = (avg[i]*max + 127) / 255
+	}
+	cands := [][3]int{q}
+	switch enc.q {
+	case QualityMedium:
+		for i := 0; i < 3; i++ {
+			for _, d := range []int{-1, 1} {
+				c := q
+				c[i] += d
+				if c[i] >= 0 && c[i] <= max {
+					cands = append(cands, c)
+				}
+			}
+		}
+	case QualityHigh:
+		for dr := -1; dr <= 1; dr++ {
+			for dg := -1; dg <= 1; dg++ {
+				for db := -1; db <= 1; db++ {
+					c := [3]int{q[0] + dr, q[1] + dg, q[2] + db}
+					if c == q || !inRange(c, max) {
+						continue
+					}
+					cands = append(cands, c)
+				}
+			}
+		}
+	}
+	return cands
+}
+
+func inRange(c [3]int, max int) bool {
+	for _, v := range c {
+		if v < 0 || v > max {
+			return false
+		}
+	}
+	return true
+}
+
+// fitSubblock chooses the modifier table and texel indices which best
+// represent texels with the given 8-bit base color.
+func (enc *colorEncoder) fitSubblock(block *[16][4]byte, texels *[8]int, base [3]int) subblock {
+	opaque := !(enc.punchthrough && enc.anyTransparent)
+	var best subblock
+	best.err = -1
+	for t := 0; t < 8; t++ {
+		var sub subblock
+		sub.table = t
+		for j, texel := range texels {
+			if !opaque && enc.transparent[texel] {
+				sub.indices[j] = 2
+				continue
+			}
+			bestI, bestE := 0, -1
+			for i := 0; i < 4; i++ {
+				m := modifier(t, i)
+				if !opaque {
+					if i == 2 {
+						continue
+					}
+					if i == 0 {
+						m = 0
+					}
+				}
+				e := colorError(block[texel], base[0]+m, base[1]+m, base[2]+m)
+				if bestE < 0 || e < bestE {
+					bestI, bestE = i, e
+				}
+			}
+			sub.indices[j] = bestI
+			sub.err += bestE
+		}
+		if best.err < 0 || sub.err < best.err {
+			best = sub
+		}
+	}
+	return best
+}
+
+// colorError returns the squared error between texel c and an unclamped color.
+func colorError(c [4]byte, r, g, b int) int {
+	dr := int(c[0]) - int(clamp255(r))
+	dg := int(c[1]) - int(clamp255(g))
+	db := int(c[2]) - int(clamp255(b))
+	return dr*dr + dg*dg + db*db
+}
+
+// blockError returns the squared error of the encoded color block b.
+func blockError(block *[16][4]byte, b uint64, punchthrough bool) int {
+	var dec [16][4]byte
+	decodeColor(&dec, b, punchthrough)
+	err := 0
+	for i := range block {
+		err += colorError(block[i], int(dec[i][0]), int(dec[i][1]), int(dec[i][2]))
+	}
+	return err
+}
+
+// planar encodes block in ETC2 planar mode by fitting a plane to each
+// channel.
+func (enc *colorEncoder) planar(block *[16][4]byte) uint64 {
+	maxes := [3]int{63, 127, 63}
+	var o, h, v [3]int
+	for i := 0; i < 3; i++ {
+		// least squares fit of c = a + bx*x + by*y with x, y in [0, 3]
+		var sum, sumX, sumY float64
+		for t := range block {
+			c := float64(block[t][i])
+			sum += c
+			sumX += (float64(t%4) - 1.5) * c
+			sumY += (float64(t/4) - 1.5) * c
+		}
+		bx, by := sumX/20, sumY/20
+		a := sum/16 - 1.5*bx - 1.5*by
+		quant := func(c float64) int {
+			return clamp(int(c*float64(maxes[i])/255+0.5), 0, maxes[i])
+		}
+		o[i], h[i], v[i] = quant(a), quant(a+4*bx), quant(a+4*by)
+	}
+	b := packPlanar(o, h, v)
+	if enc.q < QualityHigh {
+		return b
+	}
+
+	// refine each quantized value while the error improves
+	err := blockError(block, b, enc.punchthrough)
+	params := []*[3]int{&o, &h, &v}
+	for improved := true; improved; {
+		improved = false
+		for _, p := range params {
+			for i := 0; i < 3; i++ {
+				for _, d := range []int{-1, 1} {
+					p[i] += d
+					if p[i] >= 0 && p[i] <= maxes[i] {
+						nb := packPlanar(o, h, v)
+						if nerr := blockError(block, nb, enc.punchthrough); nerr < err {
+							b, err, improved = nb, nerr, true
+							continue
+						}
+					}
+					p[i] -= d
+				}
+			}
+		}
+	}
+	return b
+}
+
+// packPlanar packs the origin, horizontal, and vertical colors of a planar
+// block, with 6-bit red and blue and 7-bit green components.  The unused bits
+// are set so that the red and green differential sums are valid and the blue
+// sum overflows, which selects planar mode.
+func packPlanar(o, h, v [3]int) uint64 {
+	b := uint64(o[0])<<57 |
+		uint64(o[1]>>6)<<56 | uint64(o[1]&63)<<49 |
+		uint64(o[2]>>5)<<48 | uint64(o[2]>>3&3)<<43 | uint64(o[2]&7)<<39 |
+		uint64(h[0]>>1)<<34 | uint64(h[0]&1)<<32 |
+		uint64(h[1])<<25 | uint64(h[2])<<19 |
+		uint64(v[0])<<13 | uint64(v[1])<<6 | uint64(v[2])
+	b |= 1 << 33
+
+	if r, dr := int(b>>59&31), signed3(b>>56); r+dr < 0 || r+dr > 31 {
+		b |= 1 << 63
+	}
+	if g, dg := int(b>>51&31), signed3(b>>48); g+dg < 0 || g+dg > 31 {
+		b |= 1 << 55
+	}
+	if xx, yy := int(b>>43&3), int(b>>40&3); xx+yy >= 4 {
+		b |= 7 << 45
+	} else {
+		b |= 1 << 42
+	}
+	return b
+}
+
+// eacParams describes the value range of an EAC block.
+type eacParams struct {
+	minBase, maxBase int
+	scale            int  // multiplier units in the value domain
+	offset           int  // added to base*scale
+	zeroMult         bool // multiplier zero selects unit steps
+	minValue         int
+	maxValue         int
+}
+
+var (
+	eac8Params        = eacParams{0, 255, 1, 0, false, 0, 255}
+	eac11Params       = eacParams{0, 255, 8, 4, true, 0, 2047}
+	eac11SignedParams = eacParams{-127, 127, 8, 0, true, -1023, 1023}
+)
+
+func (p *eacParams) value(base, mult, m int) int {
+	if mult == 0 && p.zeroMult {
+		return clamp(base*p.scale+p.offset+m, p.minValue, p.maxValue)
+	}
+	step := mult
+	if p.zeroMult {
+		step *= 8
+	}
+	return clamp(base*p.scale+p.offset+m*step, p.minValue, p.maxValue)
+}
+
+// encodeEAC8 encodes 8-bit alpha values in row-major order.
+func encodeEAC8(a *[16]int, q Quality) uint64 {
+	return encodeEAC(a, &eac8Params, q)
+}
+
+// encodeEAC11 encodes 16-bit values in row-major order as an 11-bit EAC
+// block.
+func encodeEAC11(v *[16]int, signed bool, q Quality) uint64 {
+	var vals [16]int
+	p := &eac11Params
+	for i, x := range v {
+		if signed {
+			vals[i] = (x*2046+0x7fff)/0xffff - 1023
+		} else {
+			vals[i] = x >> 5
+		}
+	}
+	if signed {
+		p = &eac11SignedParams
+	}
+	return encodeEAC(&vals, p, q)
+}
+
+// encodeEAC searches for the base, multiplier, and table which best represent
+// vals.  Signed bases are stored as two's complement bytes.
+func encodeEAC(vals *[16]int, p *eacParams, q Quality) uint64 {
+	lo, hi := vals[0], vals[0]
+	for _, v := range vals {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+
+	baseRadius, multRadius := 0, 0
+	switch q {
+	case QualityMedium:
+		baseRadius, multRadius = 1, 1
+	case QualityHigh:
+		baseRadius, multRadius = 2, 2
+	}
+
+	bestErr := -1
+	var best uint64
+	for t := range eacModifiers {
+		mods := &eacModifiers[t]
+		span := mods[7] - mods[3]
+		unit, minMult := 1, 1
+		if p.zeroMult {
+			unit, minMult = 8, 0
+		}
+		m0 := clamp(((hi-lo)+span*unit/2)/(span*unit), minMult, 15)
+		for mult := m0 - multRadius; mult <= m0+multRadius; mult++ {
+			if mult < minMult || mult > 15 {
+				continue
+			}
+			// center the modifier range on the value range
+			step := mult * unit
+			if mult == 0 {
+				step = 1
+			}
+			center := (hi+lo)/2 - (mods[7]+mods[3])*step/2 - p.offset
+			b0 := (center + p.scale/2) / p.scale
+			if center < 0 {
+				b0 = -((-center + p.scale/2) / p.scale)
+			}
+			b0 = clamp(b0, p.minBase, p.maxBase)
+			for base := b0 - baseRadius; base <= b0+baseRadius; base++ {
+				if base < p.minBase || base > p.maxBase {
+					continue
+				}
+				var err int
+				var indices uint64
+				for i, v := range vals {
+					bestI, bestE := 0, -1
+					for j, m := range mods {
+						d := p.value(base, mult, m) - v
+						if e := d * d; bestE < 0 || e < bestE {
+							bestI, bestE = j, e
+						}
+					}
+					err += bestE
+					x, y := i%4, i/4
+					indices |= uint64(bestI) << (45 - 3*uint(x*4+y))
+				}
+				if bestErr < 0 || err < bestErr {
+					bestErr = err
+					best = uint64(uint8(base))<<56 | uint64(mult)<<52 | uint64(t)<<48 | indices
+				}
+				if bestErr == 0 {
+					return best
+				}
+			}
+		}
+	}
+	return best
+}
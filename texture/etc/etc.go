@@ -0,0 +1,235 @@
+// Package etc encodes and decodes ETC1 and ETC2 compressed image data,
+// including the EAC formats used for alpha and one and two channel images.
+//
+// Every format compresses blocks of 4x4 texels.  Images whose dimensions are
+// not multiples of four are padded to whole blocks when encoded and cropped
+// when decoded.
+package etc
+
+import "fmt"
+
+// Format identifies an ETC compressed format.
+type Format int
+
+// Supported formats.
+const (
+	ETC1       Format = iota + 1 // ETC1 RGB, 4 bits per texel
+	RGB8                         // ETC2 RGB, a superset of ETC1
+	RGB8A1                       // ETC2 RGB with punchthrough (1-bit) alpha
+	RGBA8                        // ETC2 RGB with an EAC alpha block
+	R11                          // EAC unsigned single channel
+	SignedR11                    // EAC signed single channel
+	RG11                         // EAC unsigned two channel
+	SignedRG11                   // EAC signed two channel
+)
+
+var formatNames = map[Format]string{
+	ETC1:       "ETC1",
+	RGB8:       "ETC2_RGB8",
+	RGB8A1:     "ETC2_RGB8A1",
+	RGBA8:      "ETC2_RGBA8",
+	R11:        "EAC_R11",
+	SignedR11:  "EAC_SIGNED_R11",
+	RG11:       "EAC_RG11",
+	SignedRG11: "EAC_SIGNED_RG11",
+}
+
+func (f Format) String() string {
+	if name, ok := formatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+func (f Format) valid() bool {
+	return f >= ETC1 && f <= SignedRG11
+}
+
+// BlockSize returns the number of bytes in each 4x4 block of f.
+func (f Format) BlockSize() int {
+	switch f {
+	case RGBA8, RG11, SignedRG11:
+		return 16
+	}
+	return 8
+}
+
+// Size returns the number of bytes of f data encoding an image with the
+// given dimensions.
+func (f Format) Size(width, height int) int {
+	return ((width + 3) / 4) * ((height + 3) / 4) * f.BlockSize()
+}
+
+// HasAlpha returns true if f encodes an alpha channel.
+func (f Format) HasAlpha() bool {
+	return f == RGB8A1 || f == RGBA8
+}
+
+// Signed returns true if f encodes signed values.
+func (f Format) Signed() bool {
+	return f == SignedR11 || f == SignedRG11
+}
+
+// GL internal formats for ETC data.
+const (
+	glETC1RGB8        = 0x8D64
+	glR11EAC          = 0x9270
+	glSignedR11EAC    = 0x9271
+	glRG11EAC         = 0x9272
+	glSignedRG11EAC   = 0x9273
+	glRGB8ETC2        = 0x9274
+	glSRGB8ETC2       = 0x9275
+	glRGB8A1ETC2      = 0x9276
+	glSRGB8A1ETC2     = 0x9277
+	glRGBA8ETC2EAC    = 0x9278
+	glSRGB8Alpha8ETC2 = 0x9279
+	glRGB             = 0x1907
+	glRGBA            = 0x1908
+	glRed             = 0x1903
+	glRG              = 0x8227
+)
+
+// GLInternalFormat returns the GL internal format of f data.  If srgb is true
+// the sRGB variant is returned for formats which have one.
+func (f Format) GLInternalFormat(srgb bool) uint32 {
+	switch f {
+	case ETC1:
+		return glETC1RGB8
+	case RGB8:
+		if srgb {
+			return glSRGB8ETC2
+		}
+		return glRGB8ETC2
+	case RGB8A1:
+		if srgb {
+			return glSRGB8A1ETC2
+		}
+		return glRGB8A1ETC2
+	case RGBA8:
+		if srgb {
+			return glSRGB8Alpha8ETC2
+		}
+		return glRGBA8ETC2EAC
+	case R11:
+		return glR11EAC
+	case SignedR11:
+		return glSignedR11EAC
+	case RG11:
+		return glRG11EAC
+	case SignedRG11:
+		return glSignedRG11EAC
+	}
+	return 0
+}
+
+// GLBaseInternalFormat returns the GL base internal format of f data.
+func (f Format) GLBaseInternalFormat() uint32 {
+	switch f {
+	case ETC1, RGB8:
+		return glRGB
+	case RGB8A1, RGBA8:
+		return glRGBA
+	case R11, SignedR11:
+		return glRed
+	case RG11, SignedRG11:
+		return glRG
+	}
+	return 0
+}
+
+// FormatFromGL returns the Format of data with the given GL internal format.
+// The srgb result is true for the sRGB variants of the ETC2 formats.
+func FormatFromGL(internalFormat uint32) (f Format, srgb bool, ok bool) {
+	switch internalFormat {
+	case glETC1RGB8:
+		return ETC1, false, true
+	case glRGB8ETC2:
+		return RGB8, false, true
+	case glSRGB8ETC2:
+		return RGB8, true, true
+	case glRGB8A1ETC2:
+		return RGB8A1, false, true
+	case glSRGB8A1ETC2:
+		return RGB8A1, true, true
+	case glRGBA8ETC2EAC:
+		return RGBA8, false, true
+	case glSRGB8Alpha8ETC2:
+		return RGBA8, true, true
+	case glR11EAC:
+		return R11, false, true
+	case glSignedR11EAC:
+		return SignedR11, false, true
+	case glRG11EAC:
+		return RG11, false, true
+	case glSignedRG11EAC:
+		return SignedRG11, false, true
+	}
+	return 0, false, false
+}
+
+// etc1Modifiers are the intensity modifier tables shared by ETC1 and ETC2.
+// Each row holds the small and large modifier, the pixel index selects +small,
+// +large, -small, or -large.
+var etc1Modifiers = [8][2]int{
+	{2, 8},
+	{5, 17},
+	{9, 29},
+	{13, 42},
+	{18, 60},
+	{24, 80},
+	{33, 106},
+	{47, 183},
+}
+
+// modifier returns the intensity modifier for pixel index i of table t.
+func modifier(t, i int) int {
+	m := etc1Modifiers[t][i&1]
+	if i&2 != 0 {
+		return -m
+	}
+	return m
+}
+
+// thDistances are the distances used by the ETC2 T and H modes.
+var thDistances = [8]int{3, 6, 11, 16, 23, 32, 41, 64}
+
+// eacModifiers are the modifier tables of the EAC formats.
+var eacModifiers = [16][8]int{
+	{-3, -6, -9, -15, 2, 5, 8, 14},
+	{-3, -7, -10, -13, 2, 6, 9, 12},
+	{-2, -5, -8, -13, 1, 4, 7, 12},
+	{-2, -4, -6, -13, 1, 3, 5, 12},
+	{-3, -6, -8, -12, 2, 5, 7, 11},
+	{-3, -7, -9, -11, 2, 6, 8, 10},
+	{-4, -7, -8, -11, 3, 6, 7, 10},
+	{-3, -5, -8, -11, 2, 4, 7, 10},
+	{-2, -6, -8, -10, 1, 5, 7, 9},
+	{-2, -5, -8, -10, 1, 4, 7, 9},
+	{-2, -4, -8, -10, 1, 3, 7, 9},
+	{-2, -5, -7, -10, 1, 4, 6, 9},
+	{-3, -4, -7, -10, 2, 3, 6, 9},
+	{-1, -2, -3, -10, 0, 1, 2, 9},
+	{-4, -6, -8, -9, 3, 5, 7, 8},
+	{-3, -5, -7, -9, 2, 4, 6, 8},
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func clamp255(v int) uint8 {
+	return uint8(clamp(v, 0, 255))
+}
+
+// extend4 and the other extend functions replicate the high bits of a
+// quantized color component to fill 8 bits.
+func extend4(c int) int { return c<<4 | c }
+func extend5(c int) int { return c<<3 | c>>2 }
+func extend6(c int) int { return c<<2 | c>>4 }
+func extend7(c int) int { return c<<1 | c>>6 }
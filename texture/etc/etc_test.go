@@ -0,0 +1,93 @@
+package etc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		f             Format
+		b             []byte
+		width, height int
+	}{
+		{"unknown format", Format(0), make([]byte, 8), 4, 4},
+		{"unknown format", SignedRG11 + 1, make([]byte, 16), 4, 4},
+		{"zero width", ETC1, make([]byte, 8), 0, 4},
+		{"negative height", ETC1, make([]byte, 8), 4, -4},
+		{"short", RGB8, make([]byte, 15), 5, 4},
+		{"short", RGBA8, make([]byte, 8), 4, 4},
+		{"short", RG11, make([]byte, 16), 4, 5},
+	} {
+		_, err := Decode(test.f, test.b, test.width, test.height)
+		if err == nil {
+			t.Errorf("%s %v %dx%d: no error", test.name, test.f, test.width, test.height)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 6, 5))
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 6; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(40 * x), uint8(50 * y), 0x80, 0xff})
+		}
+	}
+	for f := ETC1; f <= SignedRG11; f++ {
+		b, err := Encode(f, img, QualityFast)
+		if err != nil {
+			t.Errorf("%v: %v", f, err)
+			continue
+		}
+		if len(b) != f.Size(6, 5) {
+			t.Errorf("%v: encoded %d bytes, expected %d", f, len(b), f.Size(6, 5))
+		}
+		dec, err := Decode(f, b, 6, 5)
+		if err != nil {
+			t.Errorf("%v: %v", f, err)
+			continue
+		}
+		if dec.Bounds() != img.Bounds() {
+			t.Errorf("%v: decoded bounds %v", f, dec.Bounds())
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(uint8(ETC1), uint8(4), uint8(4), make([]byte, 8))
+	f.Add(uint8(RGB8), uint8(20), uint8(3), []byte{
+		// individual and differential blocks, then differential blocks
+		// whose red, green and blue overflow into the T, H and planar modes.
+		0x12, 0x34, 0x56, 0x00, 0xff, 0x00, 0xff, 0x00,
+		0x12, 0x34, 0x56, 0x02, 0x0f, 0xf0, 0x0f, 0xf0,
+		0xf9, 0x34, 0x56, 0x02, 0x0f, 0xf0, 0x0f, 0xf0,
+		0x12, 0xf9, 0x56, 0x02, 0x0f, 0xf0, 0x0f, 0xf0,
+		0x12, 0x34, 0xf9, 0x02, 0x0f, 0xf0, 0x0f, 0xf0,
+	})
+	f.Add(uint8(RGB8A1), uint8(4), uint8(4), []byte{0x12, 0x34, 0x56, 0x00, 0xff, 0x00, 0xff, 0x00})
+	f.Add(uint8(RGBA8), uint8(1), uint8(1), []byte{0x80, 0x1f, 0x24, 0x92, 0x49, 0x24, 0x92, 0x49, 0x12, 0x34, 0x56, 0x02, 0, 0, 0, 0})
+	f.Add(uint8(SignedR11), uint8(4), uint8(4), []byte{0x80, 0x1f, 0x24, 0x92, 0x49, 0x24, 0x92, 0x49})
+	f.Add(uint8(RG11), uint8(2), uint8(7), make([]byte, 32))
+	f.Add(uint8(0), uint8(4), uint8(4), make([]byte, 8))
+	f.Add(uint8(ETC1), uint8(0), uint8(4), make([]byte, 8))
+	f.Add(uint8(RGBA8), uint8(4), uint8(4), make([]byte, 8))
+	f.Fuzz(func(t *testing.T, format, width, height uint8, b []byte) {
+		fm, w, h := Format(format), int(width), int(height)
+		img, err := Decode(fm, b, w, h)
+		if err != nil {
+			return
+		}
+		if img.Bounds() != image.Rect(0, 0, w, h) {
+			t.Fatalf("%v %dx%d: decoded bounds %v", fm, w, h, img.Bounds())
+		}
+		enc, err := Encode(fm, img, QualityFast)
+		if err != nil {
+			t.Fatalf("%v %dx%d: failed to encode decoded image: %v", fm, w, h, err)
+		}
+		if len(enc) != fm.Size(w, h) {
+			t.Fatalf("%v %dx%d: encoded %d bytes, expected %d", fm, w, h, len(enc), fm.Size(w, h))
+		}
+	})
+}
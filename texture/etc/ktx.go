@@ -0,0 +1,83 @@
+package etc
+
+import (
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/bmatsuo/mobile-gl-tutorial/texture/ktx"
+)
+
+// KTXOptions controls how WriteKTX stores encoded images.
+type KTXOptions struct {
+	// Quality is passed to Encode for every level.
+	Quality Quality
+
+	// SRGB selects the sRGB internal format for formats which have one.
+	SRGB bool
+
+	// Writer is stored in the KTXwriter metadata key if it is not empty.
+	Writer string
+}
+
+// WriteKTX encodes levels, a mipmap chain beginning with the base level, with
+// f and writes them to w as a ktx file.  Each level must be half the size of
+// the previous one, rounded down but at least 1.  The first row of each image
+// is stored first and the file's KTXorientation metadata is set to "S=r,T=d"
+// to describe it.
+func WriteKTX(w io.Writer, f Format, levels []image.Image, opts *KTXOptions) error {
+	if opts == nil {
+		opts = &KTXOptions{}
+	}
+	if !f.valid() {
+		return fmt.Errorf("etc: unknown format %v", f)
+	}
+	if len(levels) == 0 {
+		return fmt.Errorf("etc: no images to write")
+	}
+
+	base := levels[0].Bounds()
+	h := &ktx.Header{
+		Endianness:           ktx.NativeEndianness,
+		GLTypeSize:           1,
+		GLInternalFormat:     f.GLInternalFormat(opts.SRGB),
+		GLBaseInternalFormat: f.GLBaseInternalFormat(),
+		PixelWidth:           uint32(base.Dx()),
+		PixelHeight:          uint32(base.Dy()),
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: uint32(len(levels)),
+	}
+
+	data := make([][]byte, len(levels))
+	width, height := base.Dx(), base.Dy()
+	for i, img := range levels {
+		size := img.Bounds().Size()
+		if size.X != width || size.Y != height {
+			return fmt.Errorf("etc: level %d is %dx%d, expected %dx%d", i, size.X, size.Y, width, height)
+		}
+		b, err := Encode(f, img, opts.Quality)
+		if err != nil {
+			return fmt.Errorf("etc: level %d: %v", i, err)
+		}
+		data[i] = b
+		width, height = halve(width), halve(height)
+	}
+
+	var meta ktx.Metadata
+	meta.SetOrientation(ktx.Orientation{S: ktx.Right, T: ktx.Down})
+	if opts.Writer != "" {
+		meta.SetWriter(opts.Writer)
+	}
+	metab, err := meta.Encode(h)
+	if err != nil {
+		return err
+	}
+	return ktx.Write(w, h, metab, data)
+}
+
+func halve(n int) int {
+	if n > 1 {
+		return n / 2
+	}
+	return 1
+}
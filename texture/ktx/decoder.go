@@ -0,0 +1,316 @@
+package ktx
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A FormatError reports that the input is not a valid ktx stream.
+type FormatError string
+
+func (e FormatError) Error() string { return "ktx: invalid format: " + string(e) }
+
+// A LimitError reports that a value in a ktx stream exceeds one of the limits
+// configured for a Decoder.
+type LimitError struct {
+	Field string
+	Value uint64
+	Limit uint64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("ktx: %s %d exceeds limit %d", e.Field, e.Value, e.Limit)
+}
+
+// Limits bound the amount of memory a Decoder will allocate on behalf of an
+// untrusted stream.  A zero field imposes no limit.
+type Limits struct {
+	// MaxDimension bounds PixelWidth, PixelHeight, and PixelDepth.
+	MaxDimension uint32
+
+	// MaxArrayElements bounds NumberOfArrayElements.
+	MaxArrayElements uint32
+
+	// MaxKeyValueData bounds BytesOfKeyValueData.
+	MaxKeyValueData uint32
+
+	// MaxLevelSize bounds the number of bytes of image data in a single
+	// mipmap level, including every face and array element.
+	MaxLevelSize uint64
+}
+
+// DefaultLimits are the limits used by Read and by a Decoder returned from
+// NewDecoder.
+var DefaultLimits = Limits{
+	MaxDimension:     16384,
+	MaxArrayElements: 2048,
+	MaxKeyValueData:  1 << 20,
+	MaxLevelSize:     1 << 28,
+}
+
+// Decoder reads a ktx stream incrementally.  The header and metadata are read
+// on demand and mipmap levels are returned one at a time by NextLevel so that
+// only a single level need be held in memory.
+type Decoder struct {
+	// Limits are checked against the header and every level size.  Changes
+	// to Limits only take effect if made before the header is read.
+	Limits Limits
+
+	r     *bufio.Reader
+	h     *Header
+	meta  []byte
+	level int
+	err   error
+}
+
+// NewDecoder returns a Decoder reading from r using DefaultLimits.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		Limits: DefaultLimits,
+		r:      bufio.NewReader(r),
+	}
+}
+
+// Header reads and validates the ktx header if it has not already been read
+// and returns it.
+func (d *Decoder) Header() (*Header, error) {
+	if d.h != nil || d.err != nil {
+		return d.h, d.err
+	}
+	h, err := DecodeHeader(d.r)
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	err = d.Limits.check(h)
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	d.h = h
+	return h, nil
+}
+
+// Metadata returns the raw key-value data following the header, which may be
+// decoded with DecodeMetadata.
+func (d *Decoder) Metadata() ([]byte, error) {
+	if d.meta != nil {
+		return d.meta, nil
+	}
+	h, err := d.Header()
+	if err != nil {
+		return nil, err
+	}
+	meta, err := readFull(d.r, uint64(h.BytesOfKeyValueData))
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	d.meta = meta
+	return meta, nil
+}
+
+// NumLevels returns the number of mipmap levels stored in the stream, which
+// is one when the header specifies zero levels.  NumLevels must only be called
+// after Header returns successfully.
+func (d *Decoder) NumLevels() int {
+	if d.h.NumberOfMipmapLevels == 0 {
+		return 1
+	}
+	return int(d.h.NumberOfMipmapLevels)
+}
+
+// NextLevel reads the next mipmap level from the stream and returns its index
+// and data.  The data for a level is laid out as described for Read.  After
+// the last level NextLevel returns io.EOF, or a FormatError if the stream
+// contains trailing bytes.
+func (d *Decoder) NextLevel() (level int, data []byte, err error) {
+	if d.err != nil {
+		return 0, nil, d.err
+	}
+	_, err = d.Metadata()
+	if err != nil {
+		return 0, nil, err
+	}
+	if d.level >= d.NumLevels() {
+		d.err = d.checkEOF()
+		return 0, nil, d.err
+	}
+
+	data, err = d.readLevel()
+	if err != nil {
+		d.err = err
+		return 0, nil, err
+	}
+	level = d.level
+	d.level++
+	return level, data, nil
+}
+
+func (d *Decoder) readLevel() ([]byte, error) {
+	var bufSize [4]byte
+	_, err := io.ReadFull(d.r, bufSize[:])
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	size, _ := decodeUint32(d.h.Endianness, bufSize[:])
+	if want, ok := d.h.ImageSize(d.level); ok {
+		err = d.h.checkLevelSize(d.level, uint64(size), want)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if d.h.isCubePadded() {
+		// imageSize is the size of a single face and each face is padded
+		// individually.
+		err = d.Limits.checkLevelSize(6 * uint64(size))
+		if err != nil {
+			return nil, err
+		}
+		cubePad := 3 - (size+3)%4
+		var b []byte
+		for i := 0; i < 6; i++ {
+			face, err := readFull(d.r, uint64(size))
+			if err != nil {
+				return nil, err
+			}
+			if b == nil {
+				b = make([]byte, 0, 6*len(face))
+			}
+			b = append(b, face...)
+			err = d.skipPadding(cubePad)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	err = d.Limits.checkLevelSize(uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	b, err := readFull(d.r, uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	err = d.skipPadding(3 - (size+3)%4)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *Decoder) skipPadding(n uint32) error {
+	var pad [3]byte
+	_, err := io.ReadFull(d.r, pad[:n])
+	return unexpectedEOF(err)
+}
+
+func (d *Decoder) checkEOF() error {
+	_, err := d.r.ReadByte()
+	if err == nil {
+		return FormatError("bytes remaining in ktx stream")
+	}
+	return err
+}
+
+// check validates the fields of h against the ktx specification and l.
+func (l *Limits) check(h *Header) error {
+	if h.PixelWidth == 0 {
+		return FormatError("zero pixel width")
+	}
+	if h.PixelHeight == 0 && h.PixelDepth != 0 {
+		return FormatError("zero pixel height with non-zero pixel depth")
+	}
+	if h.NumberOfFaces != 1 && h.NumberOfFaces != 6 {
+		return FormatError(fmt.Sprintf("invalid number of faces: %d", h.NumberOfFaces))
+	}
+	if h.IsCubemap() && (h.PixelWidth != h.PixelHeight || h.PixelDepth != 0) {
+		return FormatError("cubemap faces must be square and 2-dimensional")
+	}
+	if h.GLType == 0 && h.GLFormat != 0 {
+		return FormatError("compressed data with non-zero glFormat")
+	}
+	switch h.GLTypeSize {
+	case 1, 2, 4, 8:
+	default:
+		return FormatError(fmt.Sprintf("invalid glTypeSize: %d", h.GLTypeSize))
+	}
+	if h.BytesOfKeyValueData%4 != 0 {
+		return FormatError("key-value data is not 4-byte aligned")
+	}
+
+	maxDim := h.PixelWidth
+	if h.PixelHeight > maxDim {
+		maxDim = h.PixelHeight
+	}
+	if h.PixelDepth > maxDim {
+		maxDim = h.PixelDepth
+	}
+	maxLevels := uint32(1)
+	for dim := maxDim; dim > 1; dim >>= 1 {
+		maxLevels++
+	}
+	if h.NumberOfMipmapLevels > maxLevels {
+		return FormatError(fmt.Sprintf("%d mipmap levels for %d pixel texture", h.NumberOfMipmapLevels, maxDim))
+	}
+
+	if l.MaxDimension != 0 && maxDim > l.MaxDimension {
+		return &LimitError{"pixel dimension", uint64(maxDim), uint64(l.MaxDimension)}
+	}
+	if l.MaxArrayElements != 0 && h.NumberOfArrayElements > l.MaxArrayElements {
+		return &LimitError{"number of array elements", uint64(h.NumberOfArrayElements), uint64(l.MaxArrayElements)}
+	}
+	if l.MaxKeyValueData != 0 && h.BytesOfKeyValueData > l.MaxKeyValueData {
+		return &LimitError{"bytes of key-value data", uint64(h.BytesOfKeyValueData), uint64(l.MaxKeyValueData)}
+	}
+	return nil
+}
+
+func (l *Limits) checkLevelSize(size uint64) error {
+	if l.MaxLevelSize != 0 && size > l.MaxLevelSize {
+		return &LimitError{"level size", size, l.MaxLevelSize}
+	}
+	return nil
+}
+
+// readChunkSize is the largest buffer readFull will allocate before any of
+// its data has been read.
+const readChunkSize = 1 << 20
+
+// readFull reads exactly n bytes from r.  Large reads are buffered
+// incrementally so that a truncated stream claiming a large size cannot force
+// an allocation much larger than the data actually present.
+func readFull(r io.Reader, n uint64) ([]byte, error) {
+	if n <= readChunkSize {
+		b := make([]byte, n)
+		_, err := io.ReadFull(r, b)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return b, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(readChunkSize)
+	m, err := io.CopyN(&buf, r, int64(n))
+	if uint64(m) != n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF for reads which
+// occur after the start of the stream.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
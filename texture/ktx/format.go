@@ -0,0 +1,207 @@
+package ktx
+
+import "fmt"
+
+// Family is a family of related compressed texture formats.
+type Family int
+
+// Compressed format families.
+const (
+	S3TC Family = iota + 1
+	RGTC
+	BPTC
+	ETC
+	ASTC
+	PVRTC
+)
+
+var familyNames = map[Family]string{
+	S3TC:  "S3TC",
+	RGTC:  "RGTC",
+	BPTC:  "BPTC",
+	ETC:   "ETC",
+	ASTC:  "ASTC",
+	PVRTC: "PVRTC",
+}
+
+func (f Family) String() string {
+	if name, ok := familyNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("Family(%d)", int(f))
+}
+
+// CompressedFormat describes the block layout of a compressed GL internal
+// format.
+type CompressedFormat struct {
+	// InternalFormat is the GL enum of the format.
+	InternalFormat uint32
+
+	// Name is the name of the GL enum without its GL_COMPRESSED_ prefix.
+	Name string
+
+	Family Family
+
+	// BlockWidth and BlockHeight are the dimensions of each block in texels
+	// and BlockSize is the number of bytes in each block.
+	BlockWidth  int
+	BlockHeight int
+	BlockSize   int
+
+	// MinBlocks is the smallest number of blocks along either dimension of
+	// an image.  PVRTC images occupy at least 2x2 blocks however small they
+	// are, other formats need only one.
+	MinBlocks int
+}
+
+func (f *CompressedFormat) String() string {
+	return fmt.Sprintf("%s (%#x)", f.Name, f.InternalFormat)
+}
+
+// Size returns the number of bytes of f data encoding a 2D image with the
+// given dimensions.
+func (f *CompressedFormat) Size(width, height int) int {
+	bw := (width + f.BlockWidth - 1) / f.BlockWidth
+	bh := (height + f.BlockHeight - 1) / f.BlockHeight
+	if bw < f.MinBlocks {
+		bw = f.MinBlocks
+	}
+	if bh < f.MinBlocks {
+		bh = f.MinBlocks
+	}
+	return bw * bh * f.BlockSize
+}
+
+// compressedFormats maps GL internal formats to their descriptions.
+var compressedFormats = map[uint32]*CompressedFormat{}
+
+func addFormat(internalFormat uint32, name string, family Family, bw, bh, size int) {
+	minBlocks := 1
+	if family == PVRTC {
+		minBlocks = 2
+	}
+	compressedFormats[internalFormat] = &CompressedFormat{
+		InternalFormat: internalFormat,
+		Name:           name,
+		Family:         family,
+		BlockWidth:     bw,
+		BlockHeight:    bh,
+		BlockSize:      size,
+		MinBlocks:      minBlocks,
+	}
+}
+
+func init() {
+	addFormat(0x83F0, "RGB_S3TC_DXT1_EXT", S3TC, 4, 4, 8)
+	addFormat(0x83F1, "RGBA_S3TC_DXT1_EXT", S3TC, 4, 4, 8)
+	addFormat(0x83F2, "RGBA_S3TC_DXT3_EXT", S3TC, 4, 4, 16)
+	addFormat(0x83F3, "RGBA_S3TC_DXT5_EXT", S3TC, 4, 4, 16)
+	addFormat(0x8C4C, "SRGB_S3TC_DXT1_EXT", S3TC, 4, 4, 8)
+	addFormat(0x8C4D, "SRGB_ALPHA_S3TC_DXT1_EXT", S3TC, 4, 4, 8)
+	addFormat(0x8C4E, "SRGB_ALPHA_S3TC_DXT3_EXT", S3TC, 4, 4, 16)
+	addFormat(0x8C4F, "SRGB_ALPHA_S3TC_DXT5_EXT", S3TC, 4, 4, 16)
+
+	addFormat(0x8DBB, "RED_RGTC1", RGTC, 4, 4, 8)
+	addFormat(0x8DBC, "SIGNED_RED_RGTC1", RGTC, 4, 4, 8)
+	addFormat(0x8DBD, "RG_RGTC2", RGTC, 4, 4, 16)
+	addFormat(0x8DBE, "SIGNED_RG_RGTC2", RGTC, 4, 4, 16)
+
+	addFormat(0x8E8C, "RGBA_BPTC_UNORM", BPTC, 4, 4, 16)
+	addFormat(0x8E8D, "SRGB_ALPHA_BPTC_UNORM", BPTC, 4, 4, 16)
+	addFormat(0x8E8E, "RGB_BPTC_SIGNED_FLOAT", BPTC, 4, 4, 16)
+	addFormat(0x8E8F, "RGB_BPTC_UNSIGNED_FLOAT", BPTC, 4, 4, 16)
+
+	addFormat(0x8D64, "ETC1_RGB8_OES", ETC, 4, 4, 8)
+	addFormat(0x9270, "R11_EAC", ETC, 4, 4, 8)
+	addFormat(0x9271, "SIGNED_R11_EAC", ETC, 4, 4, 8)
+	addFormat(0x9272, "RG11_EAC", ETC, 4, 4, 16)
+	addFormat(0x9273, "SIGNED_RG11_EAC", ETC, 4, 4, 16)
+	addFormat(0x9274, "RGB8_ETC2", ETC, 4, 4, 8)
+	addFormat(0x9275, "SRGB8_ETC2", ETC, 4, 4, 8)
+	addFormat(0x9276, "RGB8_PUNCHTHROUGH_ALPHA1_ETC2", ETC, 4, 4, 8)
+	addFormat(0x9277, "SRGB8_PUNCHTHROUGH_ALPHA1_ETC2", ETC, 4, 4, 8)
+	addFormat(0x9278, "RGBA8_ETC2_EAC", ETC, 4, 4, 16)
+	addFormat(0x9279, "SRGB8_ALPHA8_ETC2_EAC", ETC, 4, 4, 16)
+
+	addFormat(0x8C00, "RGB_PVRTC_4BPPV1_IMG", PVRTC, 4, 4, 8)
+	addFormat(0x8C01, "RGB_PVRTC_2BPPV1_IMG", PVRTC, 8, 4, 8)
+	addFormat(0x8C02, "RGBA_PVRTC_4BPPV1_IMG", PVRTC, 4, 4, 8)
+	addFormat(0x8C03, "RGBA_PVRTC_2BPPV1_IMG", PVRTC, 8, 4, 8)
+	addFormat(0x8A54, "SRGB_PVRTC_2BPPV1_EXT", PVRTC, 8, 4, 8)
+	addFormat(0x8A55, "SRGB_PVRTC_4BPPV1_EXT", PVRTC, 4, 4, 8)
+	addFormat(0x8A56, "SRGB_ALPHA_PVRTC_2BPPV1_EXT", PVRTC, 8, 4, 8)
+	addFormat(0x8A57, "SRGB_ALPHA_PVRTC_4BPPV1_EXT", PVRTC, 4, 4, 8)
+
+	// every ASTC block is 16 bytes, the linear and sRGB enums for each of
+	// the 14 block sizes are contiguous.
+	astcBlocks := [14][2]int{
+		{4, 4}, {5, 4}, {5, 5}, {6, 5}, {6, 6}, {8, 5}, {8, 6},
+		{8, 8}, {10, 5}, {10, 6}, {10, 8}, {10, 10}, {12, 10}, {12, 12},
+	}
+	for i, b := range astcBlocks {
+		dims := fmt.Sprintf("%dx%d", b[0], b[1])
+		addFormat(glCompressedRGBAASTC+uint32(i), "RGBA_ASTC_"+dims+"_KHR", ASTC, b[0], b[1], 16)
+		addFormat(glCompressedSRGBAASTC+uint32(i), "SRGB8_ALPHA8_ASTC_"+dims+"_KHR", ASTC, b[0], b[1], 16)
+	}
+}
+
+// LookupCompressedFormat returns the description of a compressed GL internal
+// format.  If the format is not known ok is false.
+func LookupCompressedFormat(internalFormat uint32) (f *CompressedFormat, ok bool) {
+	f, ok = compressedFormats[internalFormat]
+	return f, ok
+}
+
+// FormatName returns a readable name for a GL internal format, its enum name
+// if it is a known compressed format and its hexadecimal value otherwise.
+func FormatName(internalFormat uint32) string {
+	if f, ok := compressedFormats[internalFormat]; ok {
+		return f.String()
+	}
+	return fmt.Sprintf("%#x", internalFormat)
+}
+
+// LevelSize returns the number of bytes of compressed data in the given
+// mipmap level described by h, including every face and array element.  If
+// the internal format of h is not a known compressed format ok is false.
+func (h *Header) LevelSize(level int) (size uint64, ok bool) {
+	f, ok := compressedFormats[h.GLInternalFormat]
+	if !ok || h.GLType != 0 {
+		return 0, false
+	}
+	w, ht, d := levelDim(h.PixelWidth, level), levelDim(h.PixelHeight, level), levelDim(h.PixelDepth, level)
+	return uint64(f.Size(w, ht)) * uint64(d) * uint64(h.NumberOfImages()), true
+}
+
+// ImageSize returns the imageSize field expected before the given mipmap
+// level of compressed data described by h, which is the size of a single face
+// for non-array cubemaps and LevelSize otherwise.
+func (h *Header) ImageSize(level int) (size uint64, ok bool) {
+	size, ok = h.LevelSize(level)
+	if ok && h.isCubePadded() {
+		size /= 6
+	}
+	return size, ok
+}
+
+// checkLevelSize returns a FormatError if size bytes of data cannot hold the
+// given mipmap level of compressed data described by h.
+func (h *Header) checkLevelSize(level int, size, want uint64) error {
+	if size == want {
+		return nil
+	}
+	f := compressedFormats[h.GLInternalFormat]
+	w, ht := levelDim(h.PixelWidth, level), levelDim(h.PixelHeight, level)
+	return FormatError(fmt.Sprintf("level %d of %dx%d %s data is %d bytes, expected %d bytes of %dx%d blocks",
+		level, w, ht, f.Name, size, want, f.BlockWidth, f.BlockHeight))
+}
+
+// levelDim returns the size of a texture dimension at the given mipmap level.
+// Zero dimensions, unused by textures with fewer dimensions, are treated as 1.
+func levelDim(dim uint32, level int) int {
+	n := int(dim >> uint(level))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
@@ -1,11 +1,11 @@
 package ktx
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
+	"unsafe"
 )
 
 var fileID = [12]byte{0xAB, 0x4B, 0x54, 0x58, 0x20, 0x31, 0x31, 0xBB, 0x0D, 0x0A, 0x1A, 0x0A}
@@ -28,6 +28,45 @@ const (
 	LittleEndian
 )
 
+// NativeEndianness is the byte order of the host machine.  GL expects
+// uncompressed texture data with multi-byte components to be in this order.
+var NativeEndianness = nativeEndianness()
+
+func nativeEndianness() Endianness {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return LittleEndian
+	}
+	return BigEndian
+}
+
+// ConvertEndianness converts the image data b, encoded as described by h, to
+// the byte order end in place by reversing each GLTypeSize-byte element.  Data
+// with a GLTypeSize of 1, which includes all compressed data, is unchanged.
+func ConvertEndianness(h *Header, b []byte, end Endianness) error {
+	if h.Endianness == end {
+		return nil
+	}
+	size := int(h.GLTypeSize)
+	switch size {
+	case 0, 1:
+		return nil
+	case 2, 4, 8:
+	default:
+		return fmt.Errorf("invalid type size: %d", size)
+	}
+	if len(b)%size != 0 {
+		return fmt.Errorf("data size %d is not a multiple of type size %d", len(b), size)
+	}
+	for i := 0; i < len(b); i += size {
+		elem := b[i : i+size]
+		for j, k := 0, size-1; j < k; j, k = j+1, k-1 {
+			elem[j], elem[k] = elem[k], elem[j]
+		}
+	}
+	return nil
+}
+
 // Header contains ktx file header metadata
 type Header struct {
 	Endianness
@@ -45,84 +84,98 @@ type Header struct {
 	BytesOfKeyValueData   uint32
 }
 
-// Read reads a ktx format byte stream from r.
+// Read reads a ktx format byte stream from r.  Each element of data holds
+// every image for one mipmap level, use Header.Images to split a level into
+// its individual array elements and cubemap faces.  The stream is checked
+// against DefaultLimits, use a Decoder to read with other limits or to avoid
+// holding every level in memory at once.
 func Read(r io.Reader) (h *Header, meta []byte, data [][]byte, err error) {
-	r = bufio.NewReader(r)
-	h, err = DecodeHeader(r)
+	d := NewDecoder(r)
+	h, err = d.Header()
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	meta = make([]byte, h.BytesOfKeyValueData)
-	_, err = io.ReadFull(r, meta)
+	meta, err = d.Metadata()
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	for level := uint32(0); level < h.NumberOfMipmapLevels; level++ {
-		var bufSize [4]byte
-		_, err = io.ReadFull(r, bufSize[:])
-		if err != nil {
-			return nil, nil, nil, err
+	for {
+		_, b, err := d.NextLevel()
+		if err == io.EOF {
+			break
 		}
-		size, _ := decodeUint32(h.Endianness, bufSize[:])
-		mipPad := 3 - (size+3)%4
-		b := make([]byte, size+mipPad)
-		_, err = io.ReadFull(r, b)
 		if err != nil {
 			return nil, nil, nil, err
 		}
-		b = b[:size]
 		data = append(data, b)
 	}
+	return h, meta, data, nil
+}
 
-	var dummy [1]byte
-	_, err = r.Read(dummy[:])
-	if err != io.EOF {
-		if err == nil {
-			return nil, nil, nil, fmt.Errorf("bytes renamining in ktx stream")
-		}
-		return nil, nil, nil, err
+// NumberOfImages returns the number of images stored in each mipmap level --
+// the product of the number of array elements and the number of faces.
+func (h *Header) NumberOfImages() int {
+	n := int(h.NumberOfFaces)
+	if n == 0 {
+		n = 1
 	}
-	return h, meta, data, nil
+	if h.NumberOfArrayElements > 0 {
+		n *= int(h.NumberOfArrayElements)
+	}
+	return n
+}
+
+// IsCubemap returns true if h describes a cubemap or cubemap array texture.
+func (h *Header) IsCubemap() bool {
+	return h.NumberOfFaces == 6
+}
+
+// Images splits the data for a single mipmap level into its individual images.
+// The images are ordered by array element and then by face, so face f of
+// array element e is images[e*NumberOfFaces+f].  Cubemap faces are in the
+// order +X, -X, +Y, -Y, +Z, -Z.
+func (h *Header) Images(level []byte) ([][]byte, error) {
+	n := h.NumberOfImages()
+	if len(level) < n || len(level)%n != 0 {
+		return nil, fmt.Errorf("level size %d is not divisible into %d images", len(level), n)
+	}
+	size := len(level) / n
+	images := make([][]byte, n)
+	for i := range images {
+		images[i] = level[i*size : (i+1)*size : (i+1)*size]
+	}
+	return images, nil
+}
+
+// isCubePadded returns true if the encoded image data for h stores imageSize
+// per face with each face padded to a 4-byte boundary, which is only the case
+// for non-array cubemaps.
+func (h *Header) isCubePadded() bool {
+	return h.IsCubemap() && h.NumberOfArrayElements == 0
 }
 
 // DecodeMetadata decodes metadata key-value pairs given in a ktx file.  The
 // specification is quite confused about how to handle the value and while it
 // should typically be a utf-8 string it may be binary and it will include any
-// terminating null included in the keyAndValueByteSize.
+// terminating null included in the keyAndValueByteSize.  ParseMetadata
+// preserves the order of the pairs and provides access to well-known keys.
 func DecodeMetadata(h *Header, meta []byte) (map[string][][]byte, error) {
 	if len(meta) == 0 {
 		return nil, nil
 	}
+	pairs, err := ParseMetadata(h, meta)
+	if err != nil {
+		return nil, err
+	}
 	m := map[string][][]byte{}
-	for len(meta) > 0 {
-		var kvsize uint32
-		kvsize, meta = decodeUint32(h.Endianness, meta)
-		padsize := (3 - int(kvsize)%4)
-		kvdata := meta[:int(kvsize)+padsize]
-		meta = meta[int(kvsize)+padsize:]
-		klen := bytes.Index(kvdata, []byte{'0'})
-		if klen < 0 {
-			return nil, fmt.Errorf("metadata pair missing null terminated key")
-		}
-		vlen := int(kvsize) - klen - 1
-		if vlen < 0 {
-			return nil, fmt.Errorf("invalid metadata pair")
-		}
-		k := string(meta[:klen])
-		meta = meta[klen+1:] // skip the terminating null
-		v := meta[:vlen]
-		meta = meta[vlen:]
-		for i := range meta {
-			if meta[i] != 0 {
-				return nil, fmt.Errorf("sanity check failure")
-			}
-		}
-		m[k] = append(m[k], v)
+	for _, kv := range pairs {
+		m[kv.Key] = append(m[kv.Key], kv.Value)
 	}
 	return m, nil
 }
 
-// DecodeHeader decodes a ktx file header from r.
+// DecodeHeader decodes a ktx file header from r.  The header fields are not
+// validated, a Decoder should be used to read untrusted streams.
 func DecodeHeader(r io.Reader) (*Header, error) {
 	b := make([]byte, headerSize)
 	_, err := io.ReadFull(r, b)
@@ -132,13 +185,13 @@ func DecodeHeader(r io.Reader) (*Header, error) {
 
 	id, b := b[:12], b[12:]
 	if !bytes.Equal(id, fileID[:]) {
-		return nil, fmt.Errorf("not a ktx header")
+		return nil, FormatError("not a ktx header")
 	}
 
 	endianness, ok := byteOrder(b[:4])
 	b = b[4:]
 	if !ok {
-		return nil, fmt.Errorf("cannot determine endianness")
+		return nil, FormatError("cannot determine endianness")
 	}
 
 	h := &Header{}
@@ -154,10 +207,7 @@ func DecodeHeader(r io.Reader) (*Header, error) {
 	h.NumberOfArrayElements, b = decodeUint32(h.Endianness, b)
 	h.NumberOfFaces, b = decodeUint32(h.Endianness, b)
 	h.NumberOfMipmapLevels, b = decodeUint32(h.Endianness, b)
-	h.BytesOfKeyValueData, b = decodeUint32(h.Endianness, b)
-	if len(b) != 0 {
-		panic(fmt.Sprintf("leftover bytes %q", b))
-	}
+	h.BytesOfKeyValueData, _ = decodeUint32(h.Endianness, b)
 	return h, nil
 }
 
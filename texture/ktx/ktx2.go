@@ -0,0 +1,589 @@
+package ktx
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+var fileID2 = [12]byte{0xAB, 0x4B, 0x54, 0x58, 0x20, 0x32, 0x30, 0xBB, 0x0D, 0x0A, 0x1A, 0x0A}
+
+// headerSize2 is the size of an encoded ktx2 file header and its section
+// index -- 12 byte identifier + 36 bytes of uint32 metadata + 32 bytes of
+// section offsets and lengths.  The level index follows immediately.
+const headerSize2 = 80
+
+// levelIndexEntrySize is the size of one entry in the ktx2 level index.
+const levelIndexEntrySize = 24
+
+// IsKTX2 returns true if id begins with the ktx2 file identifier.  Callers
+// may use it to choose between Read and ReadKTX2 after peeking at a stream.
+func IsKTX2(id []byte) bool {
+	return len(id) >= len(fileID2) && bytes.Equal(id[:len(fileID2)], fileID2[:])
+}
+
+// Supercompression schemes which may be applied to ktx2 level data.
+const (
+	SupercompressionNone      uint32 = 0
+	SupercompressionBasisLZ   uint32 = 1
+	SupercompressionZstandard uint32 = 2
+	SupercompressionZLIB      uint32 = 3
+)
+
+// KTX2Header contains ktx2 file header metadata along with the section and
+// level indices.  All ktx2 data is little endian.
+type KTX2Header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+
+	DFDByteOffset uint32
+	DFDByteLength uint32
+	KVDByteOffset uint32
+	KVDByteLength uint32
+	SGDByteOffset uint64
+	SGDByteLength uint64
+
+	// Levels is the level index, with level 0 (the base level) first.
+	Levels []KTX2Level
+}
+
+// KTX2Level is an entry in the ktx2 level index.
+type KTX2Level struct {
+	ByteOffset             uint64
+	ByteLength             uint64
+	UncompressedByteLength uint64
+}
+
+// KTX2 contains the sections of a ktx2 file other than the level data.
+type KTX2 struct {
+	*KTX2Header
+
+	// DFD is the parsed data format descriptor.
+	DFD *DataFormatDescriptor
+
+	// Meta contains the key-value data, which is encoded the same way as
+	// ktx metadata and can be decoded with DecodeMetadata using the header
+	// returned by GLHeader.
+	Meta []byte
+
+	// SGD is the raw supercompression global data, if any.
+	SGD []byte
+}
+
+// ReadKTX2 reads a ktx2 format byte stream from r.  The returned level data
+// is stored as it appears in the file, with data[0] holding the base level.
+// If the header specifies a supercompression scheme the levels must be passed
+// through Decompress before use.  The stream is checked against
+// DefaultLimits, use a KTX2Decoder to read with other limits.
+func ReadKTX2(r io.Reader) (k *KTX2, data [][]byte, err error) {
+	return NewKTX2Decoder(r).Read()
+}
+
+// KTX2Decoder reads a ktx2 stream, bounding the memory allocated on behalf of
+// an untrusted stream like a Decoder.
+type KTX2Decoder struct {
+	// Limits are checked against the header and the size of every section.
+	// Changes to Limits only take effect if made before the header is read.
+	Limits Limits
+
+	r   *countReader
+	h   *KTX2Header
+	err error
+}
+
+// NewKTX2Decoder returns a KTX2Decoder reading from r using DefaultLimits.
+func NewKTX2Decoder(r io.Reader) *KTX2Decoder {
+	return &KTX2Decoder{
+		Limits: DefaultLimits,
+		r:      &countReader{r: bufio.NewReader(r)},
+	}
+}
+
+// Header reads and validates the ktx2 header, section index, and level index
+// if they have not already been read and returns them.
+func (d *KTX2Decoder) Header() (*KTX2Header, error) {
+	if d.h != nil || d.err != nil {
+		return d.h, d.err
+	}
+	h, err := DecodeKTX2Header(d.r)
+	if err == nil {
+		err = d.Limits.checkKTX2(h)
+	}
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	d.h = h
+	return h, nil
+}
+
+// Read reads the sections of the ktx2 stream following the header and
+// returns them as described for ReadKTX2.  Read must only be called once.
+func (d *KTX2Decoder) Read() (k *KTX2, data [][]byte, err error) {
+	h, err := d.Header()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k = &KTX2{KTX2Header: h}
+	data = make([][]byte, len(h.Levels))
+
+	// sections are read in file order so that r need not support seeking.
+	type section struct {
+		offset uint64
+		length uint64
+		dst    *[]byte
+	}
+	var dfd []byte
+	sections := []section{
+		{uint64(h.DFDByteOffset), uint64(h.DFDByteLength), &dfd},
+		{uint64(h.KVDByteOffset), uint64(h.KVDByteLength), &k.Meta},
+		{h.SGDByteOffset, h.SGDByteLength, &k.SGD},
+	}
+	for i := range h.Levels {
+		sections = append(sections, section{h.Levels[i].ByteOffset, h.Levels[i].ByteLength, &data[i]})
+	}
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].offset < sections[j].offset
+	})
+	cr := d.r
+	for _, s := range sections {
+		if s.length == 0 {
+			continue
+		}
+		if s.offset < cr.n {
+			return nil, nil, FormatError(fmt.Sprintf("overlapping ktx2 sections at offset %d", s.offset))
+		}
+		_, err = io.CopyN(ioutil.Discard, cr, int64(s.offset-cr.n))
+		if err != nil {
+			return nil, nil, unexpectedEOF(err)
+		}
+		b, err := readFull(cr, s.length)
+		if err != nil {
+			return nil, nil, err
+		}
+		*s.dst = b
+	}
+
+	// levels without supercompression are checked when their format is known
+	if glh, err := h.GLHeader(); err == nil && h.SupercompressionScheme == SupercompressionNone {
+		for i := range data {
+			if want, ok := glh.LevelSize(i); ok {
+				err = glh.checkLevelSize(i, uint64(len(data[i])), want)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	if dfd != nil {
+		k.DFD, err = DecodeDFD(dfd)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return k, data, nil
+}
+
+// DecodeKTX2Header decodes a ktx2 file header, section index, and level index
+// from r.  The structure of the header is validated but it is not checked
+// against any Limits, a KTX2Decoder should be used to read untrusted streams.
+func DecodeKTX2Header(r io.Reader) (*KTX2Header, error) {
+	b := make([]byte, headerSize2)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return nil, err
+	}
+
+	id, b := b[:12], b[12:]
+	if !IsKTX2(id) {
+		return nil, FormatError("not a ktx2 header")
+	}
+
+	h := &KTX2Header{}
+	h.VkFormat, b = decodeUint32(LittleEndian, b)
+	h.TypeSize, b = decodeUint32(LittleEndian, b)
+	h.PixelWidth, b = decodeUint32(LittleEndian, b)
+	h.PixelHeight, b = decodeUint32(LittleEndian, b)
+	h.PixelDepth, b = decodeUint32(LittleEndian, b)
+	h.LayerCount, b = decodeUint32(LittleEndian, b)
+	h.FaceCount, b = decodeUint32(LittleEndian, b)
+	h.LevelCount, b = decodeUint32(LittleEndian, b)
+	h.SupercompressionScheme, b = decodeUint32(LittleEndian, b)
+	h.DFDByteOffset, b = decodeUint32(LittleEndian, b)
+	h.DFDByteLength, b = decodeUint32(LittleEndian, b)
+	h.KVDByteOffset, b = decodeUint32(LittleEndian, b)
+	h.KVDByteLength, b = decodeUint32(LittleEndian, b)
+	h.SGDByteOffset, b = decodeUint64(b)
+	h.SGDByteLength, _ = decodeUint64(b)
+
+	if h.FaceCount != 1 && h.FaceCount != 6 {
+		return nil, FormatError(fmt.Sprintf("invalid face count: %d", h.FaceCount))
+	}
+	if h.PixelWidth == 0 {
+		return nil, FormatError("zero pixel width")
+	}
+	numLevels := h.LevelCount
+	if numLevels == 0 {
+		numLevels = 1
+	}
+	if numLevels > 32 {
+		return nil, FormatError(fmt.Sprintf("invalid level count: %d", h.LevelCount))
+	}
+
+	b = make([]byte, int(numLevels)*levelIndexEntrySize)
+	_, err = io.ReadFull(r, b)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	h.Levels = make([]KTX2Level, numLevels)
+	for i := range h.Levels {
+		h.Levels[i].ByteOffset, b = decodeUint64(b)
+		h.Levels[i].ByteLength, b = decodeUint64(b)
+		h.Levels[i].UncompressedByteLength, b = decodeUint64(b)
+	}
+
+	return h, nil
+}
+
+// checkKTX2 checks the dimensions, section sizes, and level sizes of h
+// against l.
+func (l *Limits) checkKTX2(h *KTX2Header) error {
+	for _, dim := range []uint32{h.PixelWidth, h.PixelHeight, h.PixelDepth} {
+		if l.MaxDimension != 0 && dim > l.MaxDimension {
+			return &LimitError{"pixel dimension", uint64(dim), uint64(l.MaxDimension)}
+		}
+	}
+	if l.MaxArrayElements != 0 && h.LayerCount > l.MaxArrayElements {
+		return &LimitError{"layer count", uint64(h.LayerCount), uint64(l.MaxArrayElements)}
+	}
+	if l.MaxKeyValueData != 0 && h.KVDByteLength > l.MaxKeyValueData {
+		return &LimitError{"bytes of key-value data", uint64(h.KVDByteLength), uint64(l.MaxKeyValueData)}
+	}
+	if l.MaxKeyValueData != 0 && h.DFDByteLength > l.MaxKeyValueData {
+		return &LimitError{"bytes of data format descriptor", uint64(h.DFDByteLength), uint64(l.MaxKeyValueData)}
+	}
+	err := l.checkLevelSize(h.SGDByteLength)
+	if err != nil {
+		return err
+	}
+	for _, level := range h.Levels {
+		err = l.checkLevelSize(level.ByteLength)
+		if err != nil {
+			return err
+		}
+		err = l.checkLevelSize(level.UncompressedByteLength)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decompress returns the level data b for the given level with any
+// supercompression removed.  Only the ZLIB scheme is supported, BasisLZ and
+// Zstandard data must be transcoded by other means.
+func (h *KTX2Header) Decompress(level int, b []byte) ([]byte, error) {
+	switch h.SupercompressionScheme {
+	case SupercompressionNone:
+		return b, nil
+	case SupercompressionZLIB:
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, FormatError(fmt.Sprintf("level %d: %v", level, err))
+		}
+		defer zr.Close()
+		size := h.Levels[level].UncompressedByteLength
+		out := make([]byte, size)
+		_, err = io.ReadFull(zr, out)
+		if err != nil {
+			return nil, FormatError(fmt.Sprintf("level %d: %v", level, err))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported supercompression scheme: %d", h.SupercompressionScheme)
+	}
+}
+
+// GLHeader returns a ktx Header describing the same texture as h with GL
+// format enums in place of the VkFormat so that ktx2 data can be uploaded
+// along the same path as ktx data.  An error is returned if VkFormat has no
+// known GL equivalent.
+func (h *KTX2Header) GLHeader() (*Header, error) {
+	f, ok := vkFormats[h.VkFormat]
+	if !ok {
+		return nil, fmt.Errorf("unsupported vkFormat: %d", h.VkFormat)
+	}
+	return &Header{
+		Endianness:            LittleEndian,
+		GLType:                f.glType,
+		GLTypeSize:            h.TypeSize,
+		GLFormat:              f.glFormat,
+		GLInternalFormat:      f.glInternalFormat,
+		GLBaseInternalFormat:  f.glBaseInternalFormat,
+		PixelWidth:            h.PixelWidth,
+		PixelHeight:           h.PixelHeight,
+		PixelDepth:            h.PixelDepth,
+		NumberOfArrayElements: h.LayerCount,
+		NumberOfFaces:         h.FaceCount,
+		NumberOfMipmapLevels:  h.LevelCount,
+		BytesOfKeyValueData:   h.KVDByteLength,
+	}, nil
+}
+
+// DataFormatDescriptor is a Khronos data format descriptor as embedded in a
+// ktx2 file.
+type DataFormatDescriptor struct {
+	Blocks []DFDBlock
+}
+
+// Basic returns the first basic descriptor block in d, or nil if there is
+// none.
+func (d *DataFormatDescriptor) Basic() *DFDBlock {
+	for i := range d.Blocks {
+		if d.Blocks[i].VendorID == 0 && d.Blocks[i].DescriptorType == 0 {
+			return &d.Blocks[i]
+		}
+	}
+	return nil
+}
+
+// DFDBlock is a single descriptor block.  The color model and sample fields
+// are only populated for basic descriptor blocks, other blocks only carry
+// their raw Data.
+type DFDBlock struct {
+	VendorID       uint32
+	DescriptorType uint32
+	VersionNumber  uint16
+
+	ColorModel          uint8
+	ColorPrimaries      uint8
+	TransferFunction    uint8
+	Flags               uint8
+	TexelBlockDimension [4]uint8
+	BytesPlane          [8]uint8
+	Samples             []DFDSample
+
+	// Data is the block content following the 8 byte block header.
+	Data []byte
+}
+
+// DFDSample describes one sample of a basic descriptor block.
+type DFDSample struct {
+	BitOffset      uint16
+	BitLength      uint8
+	ChannelType    uint8
+	SamplePosition [4]uint8
+	SampleLower    uint32
+	SampleUpper    uint32
+}
+
+// basicBlockSize is the size of a basic descriptor block without samples.
+const basicBlockSize = 24
+
+// dfdSampleSize is the size of a single basic descriptor block sample.
+const dfdSampleSize = 16
+
+// DecodeDFD decodes a data format descriptor, including its leading
+// dfdTotalSize field.
+func DecodeDFD(b []byte) (*DataFormatDescriptor, error) {
+	if len(b) < 4 {
+		return nil, FormatError("short data format descriptor")
+	}
+	total, b := decodeUint32(LittleEndian, b)
+	if int(total) != len(b)+4 {
+		return nil, FormatError("data format descriptor size mismatch")
+	}
+
+	d := &DataFormatDescriptor{}
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, FormatError("short descriptor block")
+		}
+		var word uint32
+		var blk DFDBlock
+		word, b = decodeUint32(LittleEndian, b)
+		blk.VendorID = word & 0x1FFFF
+		blk.DescriptorType = word >> 17
+		word, b = decodeUint32(LittleEndian, b)
+		blk.VersionNumber = uint16(word)
+		size := int(word >> 16)
+		if size < 8 || size-8 > len(b) {
+			return nil, FormatError(fmt.Sprintf("invalid descriptor block size: %d", size))
+		}
+		blk.Data, b = b[:size-8], b[size-8:]
+
+		if blk.VendorID == 0 && blk.DescriptorType == 0 {
+			err := decodeBasicBlock(&blk)
+			if err != nil {
+				return nil, err
+			}
+		}
+		d.Blocks = append(d.Blocks, blk)
+	}
+	return d, nil
+}
+
+func decodeBasicBlock(blk *DFDBlock) error {
+	b := blk.Data
+	if len(b) < basicBlockSize-8 || (len(b)-(basicBlockSize-8))%dfdSampleSize != 0 {
+		return FormatError(fmt.Sprintf("invalid basic descriptor block size: %d", len(b)+8))
+	}
+	blk.ColorModel = b[0]
+	blk.ColorPrimaries = b[1]
+	blk.TransferFunction = b[2]
+	blk.Flags = b[3]
+	copy(blk.TexelBlockDimension[:], b[4:8])
+	copy(blk.BytesPlane[:], b[8:16])
+	b = b[16:]
+	for len(b) > 0 {
+		var s DFDSample
+		s.BitOffset = binary.LittleEndian.Uint16(b[0:2])
+		s.BitLength = b[2]
+		s.ChannelType = b[3]
+		copy(s.SamplePosition[:], b[4:8])
+		s.SampleLower = binary.LittleEndian.Uint32(b[8:12])
+		s.SampleUpper = binary.LittleEndian.Uint32(b[12:16])
+		blk.Samples = append(blk.Samples, s)
+		b = b[dfdSampleSize:]
+	}
+	return nil
+}
+
+func decodeUint64(b []byte) (uint64, []byte) {
+	return binary.LittleEndian.Uint64(b[:8]), b[8:]
+}
+
+// countReader counts the bytes read from r so that absolute file offsets can
+// be tracked on a stream.
+type countReader struct {
+	r io.Reader
+	n uint64
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += uint64(n)
+	return n, err
+}
+
+// glFormat holds the GL enums corresponding to a VkFormat.  Compressed
+// formats have zero glType and glFormat as in a ktx header.
+type glFormat struct {
+	glInternalFormat     uint32
+	glFormat             uint32
+	glType               uint32
+	glBaseInternalFormat uint32
+}
+
+// GL enums needed to describe the formats in vkFormats.
+const (
+	glUnsignedByte              = 0x1401
+	glUnsignedShort4444         = 0x8033
+	glUnsignedShort5551         = 0x8034
+	glUnsignedShort565          = 0x8363
+	glLuminance                 = 0x1909
+	glLuminanceAlpha            = 0x190A
+	glRed                       = 0x1903
+	glRG                        = 0x8227
+	glRGB                       = 0x1907
+	glRGBA                      = 0x1908
+	glSRGB8                     = 0x8C41
+	glSRGB8Alpha8               = 0x8C43
+	glCompressedRGBS3TC         = 0x83F0
+	glCompressedRGBAS3TC1       = 0x83F1
+	glCompressedRGBAS3TC3       = 0x83F2
+	glCompressedRGBAS3TC5       = 0x83F3
+	glCompressedSRGBS3TC        = 0x8C4C
+	glCompressedSRGBAS3TC1      = 0x8C4D
+	glCompressedSRGBAS3TC3      = 0x8C4E
+	glCompressedSRGBAS3TC5      = 0x8C4F
+	glCompressedRedRGTC1        = 0x8DBB
+	glCompressedSignedRedRGTC1  = 0x8DBC
+	glCompressedRGRGTC2         = 0x8DBD
+	glCompressedSignedRGRGTC2   = 0x8DBE
+	glCompressedRGBABPTC        = 0x8E8C
+	glCompressedSRGBAlphaBPTC   = 0x8E8D
+	glCompressedRGB8ETC2        = 0x9274
+	glCompressedSRGB8ETC2       = 0x9275
+	glCompressedRGB8A1ETC2      = 0x9276
+	glCompressedSRGB8A1         = 0x9277
+	glCompressedRGBA8ETC2       = 0x9278
+	glCompressedSRGBA8ETC2      = 0x9279
+	glCompressedR11EAC          = 0x9270
+	glCompressedSR11EAC         = 0x9271
+	glCompressedRG11EAC         = 0x9272
+	glCompressedSRG11EAC        = 0x9273
+	glCompressedRGBAASTC        = 0x93B0 // 4x4, other block sizes follow
+	glCompressedSRGBAASTC       = 0x93D0 // 4x4, other block sizes follow
+	glCompressedRGBAPVRTC4      = 0x8C02
+	glCompressedRGBAPVRTC2      = 0x8C03
+	glCompressedSRGBAlphaPVRTC2 = 0x8A56
+	glCompressedSRGBAlphaPVRTC4 = 0x8A57
+)
+
+var vkFormats = map[uint32]glFormat{
+	2:  {glRGBA, glRGBA, glUnsignedShort4444, glRGBA}, // R4G4B4A4_UNORM_PACK16
+	4:  {glRGB, glRGB, glUnsignedShort565, glRGB},     // R5G6B5_UNORM_PACK16
+	6:  {glRGBA, glRGBA, glUnsignedShort5551, glRGBA}, // R5G5B5A1_UNORM_PACK16
+	9:  {glLuminance, glLuminance, glUnsignedByte, glLuminance},
+	16: {glLuminanceAlpha, glLuminanceAlpha, glUnsignedByte, glLuminanceAlpha},
+	23: {glRGB, glRGB, glUnsignedByte, glRGB},           // R8G8B8_UNORM
+	29: {glSRGB8, glRGB, glUnsignedByte, glRGB},         // R8G8B8_SRGB
+	37: {glRGBA, glRGBA, glUnsignedByte, glRGBA},        // R8G8B8A8_UNORM
+	43: {glSRGB8Alpha8, glRGBA, glUnsignedByte, glRGBA}, // R8G8B8A8_SRGB
+
+	131: {glCompressedRGBS3TC, 0, 0, glRGB},        // BC1_RGB_UNORM_BLOCK
+	132: {glCompressedSRGBS3TC, 0, 0, glRGB},       // BC1_RGB_SRGB_BLOCK
+	133: {glCompressedRGBAS3TC1, 0, 0, glRGBA},     // BC1_RGBA_UNORM_BLOCK
+	134: {glCompressedSRGBAS3TC1, 0, 0, glRGBA},    // BC1_RGBA_SRGB_BLOCK
+	135: {glCompressedRGBAS3TC3, 0, 0, glRGBA},     // BC2_UNORM_BLOCK
+	136: {glCompressedSRGBAS3TC3, 0, 0, glRGBA},    // BC2_SRGB_BLOCK
+	137: {glCompressedRGBAS3TC5, 0, 0, glRGBA},     // BC3_UNORM_BLOCK
+	138: {glCompressedSRGBAS3TC5, 0, 0, glRGBA},    // BC3_SRGB_BLOCK
+	139: {glCompressedRedRGTC1, 0, 0, glRed},       // BC4_UNORM_BLOCK
+	140: {glCompressedSignedRedRGTC1, 0, 0, glRed}, // BC4_SNORM_BLOCK
+	141: {glCompressedRGRGTC2, 0, 0, glRG},         // BC5_UNORM_BLOCK
+	142: {glCompressedSignedRGRGTC2, 0, 0, glRG},   // BC5_SNORM_BLOCK
+	145: {glCompressedRGBABPTC, 0, 0, glRGBA},      // BC7_UNORM_BLOCK
+	146: {glCompressedSRGBAlphaBPTC, 0, 0, glRGBA}, // BC7_SRGB_BLOCK
+
+	147: {glCompressedRGB8ETC2, 0, 0, glRGB},    // ETC2_R8G8B8_UNORM_BLOCK
+	148: {glCompressedSRGB8ETC2, 0, 0, glRGB},   // ETC2_R8G8B8_SRGB_BLOCK
+	149: {glCompressedRGB8A1ETC2, 0, 0, glRGBA}, // ETC2_R8G8B8A1_UNORM_BLOCK
+	150: {glCompressedSRGB8A1, 0, 0, glRGBA},    // ETC2_R8G8B8A1_SRGB_BLOCK
+	151: {glCompressedRGBA8ETC2, 0, 0, glRGBA},  // ETC2_R8G8B8A8_UNORM_BLOCK
+	152: {glCompressedSRGBA8ETC2, 0, 0, glRGBA}, // ETC2_R8G8B8A8_SRGB_BLOCK
+	153: {glCompressedR11EAC, 0, 0, glRed},      // EAC_R11_UNORM_BLOCK
+	154: {glCompressedSR11EAC, 0, 0, glRed},     // EAC_R11_SNORM_BLOCK
+	155: {glCompressedRG11EAC, 0, 0, glRG},      // EAC_R11G11_UNORM_BLOCK
+	156: {glCompressedSRG11EAC, 0, 0, glRG},     // EAC_R11G11_SNORM_BLOCK
+
+	1000054000: {glCompressedRGBAPVRTC2, 0, 0, glRGBA},      // PVRTC1_2BPP_UNORM_BLOCK_IMG
+	1000054001: {glCompressedRGBAPVRTC4, 0, 0, glRGBA},      // PVRTC1_4BPP_UNORM_BLOCK_IMG
+	1000054004: {glCompressedSRGBAlphaPVRTC2, 0, 0, glRGBA}, // PVRTC1_2BPP_SRGB_BLOCK_IMG
+	1000054005: {glCompressedSRGBAlphaPVRTC4, 0, 0, glRGBA}, // PVRTC1_4BPP_SRGB_BLOCK_IMG
+}
+
+func init() {
+	// ASTC formats come in UNORM/SRGB pairs for each of the 14 block sizes
+	// starting at ASTC_4x4_UNORM_BLOCK (157) and the GL enums are contiguous.
+	for i := uint32(0); i < 14; i++ {
+		vkFormats[157+2*i] = glFormat{glCompressedRGBAASTC + i, 0, 0, glRGBA}
+		vkFormats[158+2*i] = glFormat{glCompressedSRGBAASTC + i, 0, 0, glRGBA}
+	}
+}
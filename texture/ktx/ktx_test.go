@@ -0,0 +1,755 @@
+package ktx
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// goldenKTX describes the ktx files shipped as tutorial assets.  Files with
+// the same name in different tutorials are identical.
+var goldenKTX = map[string]struct {
+	header Header
+	levels []int
+	sha256 string
+}{
+	"uvtemplate.ktx": {
+		header: etc2Header(0x9274, 0x1907, 512, 10),
+		levels: []int{131072, 32768, 8192, 2048, 512, 128, 32, 8, 8, 8},
+		sha256: "481ff52296261143aca78b01c3d268f30b96bdfebdcdf031d033e8cb68a54971",
+	},
+	"uvmap.ktx": {
+		header: etc2Header(0x9274, 0x1907, 512, 10),
+		levels: []int{131072, 32768, 8192, 2048, 512, 128, 32, 8, 8, 8},
+		sha256: "af832f9d1f75a102a70361143e99505966628e6823b1fdb18bf4e21bc2ae6d47",
+	},
+	"Holstein.ktx": {
+		header: etc2Header(0x9278, 0x1908, 1024, 11),
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "5e376496cc5bd901a6e2469bc4ac1e20206bc3a88b07e1f77ceb570af98ea4ae",
+	},
+	"diffuse.ktx": {
+		header: etc2Header(0x9278, 0x1908, 1024, 11),
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "354eeda42f67b3488387b977c82da8565e4cf512ca49af32805d64bc347a10ff",
+	},
+	"specular.ktx": {
+		header: etc2Header(0x9278, 0x1908, 1024, 11),
+		levels: []int{1048576, 262144, 65536, 16384, 4096, 1024, 256, 64, 16, 16, 16},
+		sha256: "b91fe6708929d22fd17ae7edf60b5d974c276ff2097c810cfc0c7644ad939eca",
+	},
+}
+
+// etc2Header returns the header of a square little-endian ETC2 texture.
+func etc2Header(internalFormat, baseFormat, size, levels uint32) Header {
+	return Header{
+		Endianness:           LittleEndian,
+		GLTypeSize:           1,
+		GLInternalFormat:     internalFormat,
+		GLBaseInternalFormat: baseFormat,
+		PixelWidth:           size,
+		PixelHeight:          size,
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: levels,
+	}
+}
+
+func sha256Hex(data [][]byte) string {
+	h := sha256.New()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// assetFiles returns the tutorial assets matching pattern.
+func assetFiles(t testing.TB, pattern string) []string {
+	files, err := filepath.Glob(filepath.Join("..", "..", "tutorial*", "assets", pattern))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no assets match %s", pattern)
+	}
+	return files
+}
+
+func TestReadGolden(t *testing.T) {
+	for _, p := range assetFiles(t, "*.ktx") {
+		golden, ok := goldenKTX[filepath.Base(p)]
+		if !ok {
+			t.Errorf("%s: no golden description", p)
+			continue
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, meta, data, err := Read(bytes.NewReader(b))
+		if err != nil {
+			t.Errorf("%s: %v", p, err)
+			continue
+		}
+		if !reflect.DeepEqual(*h, golden.header) {
+			t.Errorf("%s: header %+v, expected %+v", p, *h, golden.header)
+		}
+		if len(meta) != 0 {
+			t.Errorf("%s: %d bytes of metadata", p, len(meta))
+		}
+		var levels []int
+		for _, level := range data {
+			levels = append(levels, len(level))
+		}
+		if !reflect.DeepEqual(levels, golden.levels) {
+			t.Errorf("%s: level sizes %v, expected %v", p, levels, golden.levels)
+		}
+		if sum := sha256Hex(data); sum != golden.sha256 {
+			t.Errorf("%s: level data sha256 %s, expected %s", p, sum, golden.sha256)
+		}
+	}
+}
+
+// encodePairs encodes key-value pairs by hand, independent of Metadata.Encode,
+// so that the tests pin the format rather than the encoder.
+func encodePairs(end Endianness, pairs ...string) []byte {
+	var b []byte
+	for _, kv := range pairs {
+		b = appendUint32(end, b, uint32(len(kv)))
+		b = append(b, kv...)
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+func TestParseMetadata(t *testing.T) {
+	for _, end := range []Endianness{LittleEndian, BigEndian} {
+		h := &Header{Endianness: end}
+		meta := encodePairs(end,
+			// keys and values containing the character '0', which was
+			// once mistaken for the terminating null.
+			"K0ey\x00v0\x00",
+			"KTXorientation\x00S=r,T=d\x00",
+			// a pair whose size is already aligned, and a repeated key.
+			"abc\x00",
+			"K0ey\x00binary\x00\x01",
+		)
+		m, err := ParseMetadata(h, meta)
+		if err != nil {
+			t.Fatalf("%v: %v", end, err)
+		}
+		expect := Metadata{
+			{"K0ey", []byte("v0\x00")},
+			{"KTXorientation", []byte("S=r,T=d\x00")},
+			{"abc", []byte{}},
+			{"K0ey", []byte("binary\x00\x01")},
+		}
+		if !reflect.DeepEqual(m, expect) {
+			t.Errorf("%v: parsed %q, expected %q", end, m, expect)
+		}
+		o, ok, err := m.Orientation()
+		if err != nil || !ok || o != (Orientation{S: Right, T: Down}) {
+			t.Errorf("%v: orientation %v %v %v", end, o, ok, err)
+		}
+
+		reencoded, err := m.Encode(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reencoded, meta) {
+			t.Errorf("%v: encoded %q, expected %q", end, reencoded, meta)
+		}
+
+		dm, err := DecodeMetadata(h, meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectMap := map[string][][]byte{
+			"K0ey":           {[]byte("v0\x00"), []byte("binary\x00\x01")},
+			"KTXorientation": {[]byte("S=r,T=d\x00")},
+			"abc":            {[]byte{}},
+		}
+		if !reflect.DeepEqual(dm, expectMap) {
+			t.Errorf("%v: decoded %q, expected %q", end, dm, expectMap)
+		}
+	}
+}
+
+// malformedMetadata are key-value blocks which ParseMetadata must reject.
+var malformedMetadata = map[string][]byte{
+	"truncated size":      {8, 0},
+	"pair exceeds data":   {0xff, 0xff, 0xff, 0xff, 'k', 0, 0, 0},
+	"missing null":        encodePairs(LittleEndian, "K0ey"),
+	"non-zero padding":    append(appendUint32(LittleEndian, nil, 2), 'k', 0, 1, 0),
+	"size in padding":     append(encodePairs(LittleEndian, "k\x00v"), 1),
+	"huge size after one": append(encodePairs(LittleEndian, "k\x00v"), 0xff, 0xff, 0xff, 0x7f),
+}
+
+func TestParseMetadataMalformed(t *testing.T) {
+	h := &Header{Endianness: LittleEndian}
+	for name, meta := range malformedMetadata {
+		_, err := ParseMetadata(h, meta)
+		if _, ok := err.(FormatError); !ok {
+			t.Errorf("%s: error %v, expected a FormatError", name, err)
+		}
+		_, err = DecodeMetadata(h, meta)
+		if _, ok := err.(FormatError); !ok {
+			t.Errorf("%s: DecodeMetadata error %v, expected a FormatError", name, err)
+		}
+	}
+}
+
+// testKTX returns a small valid ktx file holding an uncompressed RGBA
+// texture with two mipmap levels and metadata.
+func testKTX(t testing.TB, end Endianness) []byte {
+	h := &Header{
+		Endianness:           end,
+		GLType:               0x1401, // UNSIGNED_BYTE
+		GLTypeSize:           1,
+		GLFormat:             0x1908, // RGBA
+		GLInternalFormat:     0x1908,
+		GLBaseInternalFormat: 0x1908,
+		PixelWidth:           2,
+		PixelHeight:          2,
+		NumberOfFaces:        1,
+		NumberOfMipmapLevels: 2,
+	}
+	var m Metadata
+	m.SetOrientation(Orientation{S: Right, T: Up})
+	m.SetString("K0ey", "v0")
+	meta, err := m.Encode(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := [][]byte{bytes.Repeat([]byte{1, 2, 3, 4}, 4), {5, 6, 7, 8}}
+	var buf bytes.Buffer
+	err = Write(&buf, h, meta, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadWritten(t *testing.T) {
+	for _, end := range []Endianness{LittleEndian, BigEndian} {
+		h, meta, data, err := Read(bytes.NewReader(testKTX(t, end)))
+		if err != nil {
+			t.Fatalf("%v: %v", end, err)
+		}
+		if h.Endianness != end || h.PixelWidth != 2 || h.NumberOfMipmapLevels != 2 {
+			t.Errorf("%v: header %+v", end, *h)
+		}
+		m, err := ParseMetadata(h, meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := m.GetString("K0ey"); v != "v0" {
+			t.Errorf("%v: K0ey=%q", end, v)
+		}
+		if o, _, _ := m.Orientation(); o.T != Up {
+			t.Errorf("%v: orientation %v", end, o)
+		}
+		expect := [][]byte{bytes.Repeat([]byte{1, 2, 3, 4}, 4), {5, 6, 7, 8}}
+		if !reflect.DeepEqual(data, expect) {
+			t.Errorf("%v: data %v", end, data)
+		}
+	}
+}
+
+func TestReadCubemap(t *testing.T) {
+	h := &Header{
+		Endianness:           LittleEndian,
+		GLType:               0x1401,
+		GLTypeSize:           1,
+		GLFormat:             0x1907, // RGB
+		GLInternalFormat:     0x1907,
+		GLBaseInternalFormat: 0x1907,
+		PixelWidth:           1,
+		PixelHeight:          1,
+		NumberOfFaces:        6,
+		NumberOfMipmapLevels: 1,
+	}
+	level := []byte("+x.-x.+y.-y.+z.-z.")
+	var buf bytes.Buffer
+	err := Write(&buf, h, nil, [][]byte{level})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, data, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	faces, err := h.Images(data[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(faces) != 6 || string(faces[1]) != "-x." || string(faces[5]) != "-z." {
+		t.Errorf("faces %q", faces)
+	}
+}
+
+// setHeaderField overwrites the uint32 header field at index i, counting
+// from glType, of a little-endian ktx file.
+func setHeaderField(b []byte, i int, v uint32) []byte {
+	b = append([]byte(nil), b...)
+	binary.LittleEndian.PutUint32(b[16+4*i:], v)
+	return b
+}
+
+// Indices of header fields for setHeaderField.
+const (
+	fieldGLTypeSize          = 1
+	fieldPixelWidth          = 5
+	fieldPixelDepth          = 7
+	fieldNumberOfArray       = 8
+	fieldNumberOfFaces       = 9
+	fieldNumberOfMipmaps     = 10
+	fieldBytesOfKeyValueData = 11
+)
+
+// malformedKTX returns malformed copies of a valid ktx file, and the type of
+// error Read must return for each.
+func malformedKTX(t testing.TB) map[string]struct {
+	b   []byte
+	err error
+} {
+	valid := testKTX(t, LittleEndian)
+	level0 := headerSize + int(binary.LittleEndian.Uint32(valid[60:]))
+	return map[string]struct {
+		b   []byte
+		err error
+	}{
+		"empty":             {nil, io.EOF},
+		"truncated header":  {valid[:40], io.ErrUnexpectedEOF},
+		"truncated level":   {valid[:len(valid)-6], io.ErrUnexpectedEOF},
+		"trailing bytes":    {append(append([]byte(nil), valid...), 0), FormatError("")},
+		"bad identifier":    {append([]byte("KTX 12"), valid[6:]...), FormatError("")},
+		"bad endianness":    {setHeaderField(valid, -1, 0x01020304), FormatError("")},
+		"zero width":        {setHeaderField(valid, fieldPixelWidth, 0), FormatError("")},
+		"three faces":       {setHeaderField(valid, fieldNumberOfFaces, 3), FormatError("")},
+		"type size 3":       {setHeaderField(valid, fieldGLTypeSize, 3), FormatError("")},
+		"too many levels":   {setHeaderField(valid, fieldNumberOfMipmaps, 3), FormatError("")},
+		"unaligned kv data": {setHeaderField(valid, fieldBytesOfKeyValueData, 6), FormatError("")},
+		"huge dimension":    {setHeaderField(valid, fieldPixelWidth, 1<<20), &LimitError{}},
+		"huge array":        {setHeaderField(valid, fieldNumberOfArray, 1<<20), &LimitError{}},
+		"huge kv data":      {setHeaderField(valid, fieldBytesOfKeyValueData, 1<<30), &LimitError{}},
+		"huge image size": {
+			append(append(append([]byte(nil), valid[:level0]...), 0xff, 0xff, 0xff, 0x7f), valid[level0+4:]...),
+			&LimitError{},
+		},
+		"image size exceeds file": {
+			append(append(append([]byte(nil), valid[:level0]...), 0, 0, 1, 0), valid[level0+4:]...),
+			io.ErrUnexpectedEOF,
+		},
+	}
+}
+
+// errorMatches returns true if err is want, for io.EOF and
+// io.ErrUnexpectedEOF, or otherwise has the same type as want.
+func errorMatches(err, want error) bool {
+	if want == io.EOF || want == io.ErrUnexpectedEOF {
+		return err == want
+	}
+	return reflect.TypeOf(err) == reflect.TypeOf(want)
+}
+
+func TestReadMalformed(t *testing.T) {
+	for name, test := range malformedKTX(t) {
+		_, _, _, err := Read(bytes.NewReader(test.b))
+		if !errorMatches(err, test.err) {
+			t.Errorf("%s: error %#v, expected %T", name, err, test.err)
+		}
+	}
+}
+
+func TestDecoderLimits(t *testing.T) {
+	d := NewDecoder(bytes.NewReader(testKTX(t, LittleEndian)))
+	d.Limits.MaxLevelSize = 8
+	_, _, err := d.NextLevel()
+	lerr, ok := err.(*LimitError)
+	if !ok || lerr.Field != "level size" || lerr.Value != 16 || lerr.Limit != 8 {
+		t.Errorf("error %v, expected a level size LimitError", err)
+	}
+
+	d = NewDecoder(bytes.NewReader(testKTX(t, LittleEndian)))
+	d.Limits = Limits{}
+	for i := 0; i < 2; i++ {
+		level, _, err := d.NextLevel()
+		if err != nil || level != i {
+			t.Fatalf("level %d: %v", level, err)
+		}
+	}
+	_, _, err = d.NextLevel()
+	if err != io.EOF {
+		t.Errorf("error %v after the last level, expected EOF", err)
+	}
+}
+
+func TestImages(t *testing.T) {
+	h := &Header{NumberOfFaces: 6, NumberOfArrayElements: 2}
+	// levels smaller than their image count were once split into empty
+	// images.
+	for _, size := range []int{0, 6, 13} {
+		_, err := h.Images(make([]byte, size))
+		if err == nil {
+			t.Errorf("%d byte level was split into %d images", size, h.NumberOfImages())
+		}
+	}
+	images, err := h.Images(make([]byte, 24))
+	if err != nil || len(images) != 12 || len(images[11]) != 2 {
+		t.Errorf("24 byte level split into %d images: %v", len(images), err)
+	}
+}
+
+// testKTX2 describes a ktx2 file to be built by encode.
+type testKTX2 struct {
+	vkFormat   uint32
+	typeSize   uint32
+	width      uint32
+	height     uint32
+	faces      uint32
+	scheme     uint32
+	kvd        []byte
+	levels     [][]byte
+	uncompLens []uint64
+}
+
+// rgbaDFD returns a data format descriptor for an RGBA8 format.
+func rgbaDFD() []byte {
+	const samples = 4
+	blockSize := basicBlockSize + samples*dfdSampleSize
+	var b []byte
+	b = appendUint32(LittleEndian, b, uint32(4+blockSize))
+	b = appendUint32(LittleEndian, b, 0)                       // vendor and type
+	b = appendUint32(LittleEndian, b, 2|uint32(blockSize)<<16) // version and size
+	b = append(b, 1, 1, 2, 0)                                  // RGBSDA, BT709, sRGB
+	b = append(b, 0, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0)
+	for i := 0; i < samples; i++ {
+		b = append(b, byte(8*i), 0, 7, byte(i))
+		b = append(b, 0, 0, 0, 0)
+		b = appendUint32(LittleEndian, b, 0)
+		b = appendUint32(LittleEndian, b, 255)
+	}
+	return b
+}
+
+func (k *testKTX2) encode() []byte {
+	dfd := rgbaDFD()
+
+	// the dfd and kvd follow the level index and the levels follow them,
+	// smallest first, each 8-byte aligned.
+	offset := headerSize2 + len(k.levels)*levelIndexEntrySize
+	dfdOffset := offset
+	offset += len(dfd)
+	kvdOffset := offset
+	offset += len(k.kvd)
+	levelOffsets := make([]int, len(k.levels))
+	for i := len(k.levels) - 1; i >= 0; i-- {
+		offset = (offset + 7) &^ 7
+		levelOffsets[i] = offset
+		offset += len(k.levels[i])
+	}
+
+	b := append([]byte(nil), fileID2[:]...)
+	for _, v := range []uint32{
+		k.vkFormat, k.typeSize, k.width, k.height, 0, 0, k.faces, uint32(len(k.levels)), k.scheme,
+		uint32(dfdOffset), uint32(len(dfd)), uint32(kvdOffset), uint32(len(k.kvd)),
+	} {
+		b = appendUint32(LittleEndian, b, v)
+	}
+	b = append(b, make([]byte, 16)...) // no supercompression global data
+	for i, level := range k.levels {
+		uncomp := uint64(len(level))
+		if k.uncompLens != nil {
+			uncomp = k.uncompLens[i]
+		}
+		for _, v := range []uint64{uint64(levelOffsets[i]), uint64(len(level)), uncomp} {
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], v)
+			b = append(b, buf[:]...)
+		}
+	}
+	b = append(b, dfd...)
+	b = append(b, k.kvd...)
+	for i := len(k.levels) - 1; i >= 0; i-- {
+		for len(b) < levelOffsets[i] {
+			b = append(b, 0)
+		}
+		b = append(b, k.levels[i]...)
+	}
+	return b
+}
+
+// validKTX2 returns a small valid ktx2 file holding an RGBA8 texture with
+// two mipmap levels and orientation metadata.
+func validKTX2(t testing.TB) *testKTX2 {
+	var m Metadata
+	m.SetString(KeyOrientation, "rd")
+	kvd, err := m.Encode(&Header{Endianness: LittleEndian})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKTX2{
+		vkFormat: 37, // R8G8B8A8_UNORM
+		typeSize: 1,
+		width:    2,
+		height:   2,
+		faces:    1,
+		kvd:      kvd,
+		levels:   [][]byte{bytes.Repeat([]byte{1, 2, 3, 4}, 4), {5, 6, 7, 8}},
+	}
+}
+
+func TestReadKTX2(t *testing.T) {
+	k, data, err := ReadKTX2(bytes.NewReader(validKTX2(t).encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.VkFormat != 37 || k.PixelWidth != 2 || k.PixelHeight != 2 || len(k.Levels) != 2 {
+		t.Errorf("header %+v", *k.KTX2Header)
+	}
+	expect := [][]byte{bytes.Repeat([]byte{1, 2, 3, 4}, 4), {5, 6, 7, 8}}
+	if !reflect.DeepEqual(data, expect) {
+		t.Errorf("data %v", data)
+	}
+
+	basic := k.DFD.Basic()
+	if basic == nil || basic.ColorModel != 1 || basic.TransferFunction != 2 || len(basic.Samples) != 4 {
+		t.Errorf("data format descriptor %+v", k.DFD)
+	} else if s := basic.Samples[3]; s.BitOffset != 24 || s.BitLength != 7 || s.SampleUpper != 255 {
+		t.Errorf("alpha sample %+v", s)
+	}
+
+	h, err := k.GLHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.GLFormat != 0x1908 || h.GLType != 0x1401 || h.NumberOfMipmapLevels != 2 {
+		t.Errorf("GL header %+v", *h)
+	}
+	m, err := ParseMetadata(h, k.Meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, ok, err := m.Orientation()
+	if err != nil || !ok || o != (Orientation{S: Right, T: Down}) {
+		t.Errorf("orientation %v %v %v", o, ok, err)
+	}
+}
+
+func TestReadKTX2Zlib(t *testing.T) {
+	k2 := validKTX2(t)
+	plain := k2.levels
+	k2.scheme = SupercompressionZLIB
+	k2.levels = nil
+	for _, level := range plain {
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		zw.Write(level)
+		zw.Close()
+		k2.levels = append(k2.levels, buf.Bytes())
+		k2.uncompLens = append(k2.uncompLens, uint64(len(level)))
+	}
+	k, data, err := ReadKTX2(bytes.NewReader(k2.encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		b, err := k.Decompress(i, data[i])
+		if err != nil {
+			t.Fatalf("level %d: %v", i, err)
+		}
+		if !bytes.Equal(b, plain[i]) {
+			t.Errorf("level %d: decompressed %v, expected %v", i, b, plain[i])
+		}
+	}
+
+	_, err = k.Decompress(0, []byte("not zlib data"))
+	if _, ok := err.(FormatError); !ok {
+		t.Errorf("corrupt level error %v, expected a FormatError", err)
+	}
+}
+
+// malformedKTX2 returns malformed ktx2 files, and the type of error ReadKTX2
+// must return for each.
+func malformedKTX2(t testing.TB) map[string]struct {
+	b   []byte
+	err error
+} {
+	valid := validKTX2(t).encode()
+	set := func(off int, v uint32) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint32(b[off:], v)
+		return b
+	}
+	set64 := func(off int, v uint64) []byte {
+		b := append([]byte(nil), valid...)
+		binary.LittleEndian.PutUint64(b[off:], v)
+		return b
+	}
+	wrongSize := validKTX2(t)
+	wrongSize.vkFormat = 147 // ETC2_R8G8B8_UNORM_BLOCK, 8 bytes per block
+	badDFD := validKTX2(t).encode()
+	badDFD[headerSize2+2*levelIndexEntrySize] = 7
+	return map[string]struct {
+		b   []byte
+		err error
+	}{
+		"truncated header":      {valid[:60], io.ErrUnexpectedEOF},
+		"truncated level index": {valid[:headerSize2+10], io.ErrUnexpectedEOF},
+		"truncated level":       {valid[:len(valid)-8], io.ErrUnexpectedEOF},
+		"bad identifier":        {append([]byte("KTX 11"), valid[6:]...), FormatError("")},
+		"zero width":            {set(20, 0), FormatError("")},
+		"five faces":            {set(36, 5), FormatError("")},
+		"33 levels":             {set(40, 33), FormatError("")},
+		"overlapping sections":  {set64(headerSize2, 0), FormatError("")},
+		"wrong level size":      {wrongSize.encode(), FormatError("")},
+		"dfd size mismatch":     {badDFD, FormatError("")},
+		"huge dimension":        {set(20, 1<<20), &LimitError{}},
+		"huge layer count":      {set(32, 1<<20), &LimitError{}},
+		"huge kvd":              {set(60, 1<<30), &LimitError{}},
+		"huge sgd":              {set64(72, 1<<40), &LimitError{}},
+		"huge level":            {set64(headerSize2+8, 1<<40), &LimitError{}},
+		"huge uncompressed":     {set64(headerSize2+16, 1<<40), &LimitError{}},
+	}
+}
+
+func TestReadKTX2Malformed(t *testing.T) {
+	for name, test := range malformedKTX2(t) {
+		_, _, err := ReadKTX2(bytes.NewReader(test.b))
+		if !errorMatches(err, test.err) {
+			t.Errorf("%s: error %#v, expected %T", name, err, test.err)
+		}
+	}
+}
+
+func TestKTX2DecoderLimits(t *testing.T) {
+	d := NewKTX2Decoder(bytes.NewReader(validKTX2(t).encode()))
+	d.Limits.MaxLevelSize = 8
+	_, _, err := d.Read()
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Field != "level size" || lerr.Value != 16 {
+		t.Errorf("error %v, expected a level size LimitError", err)
+	}
+
+	// the structure of the header is still checked without limits.
+	d = NewKTX2Decoder(bytes.NewReader(malformedKTX2(t)["five faces"].b))
+	d.Limits = Limits{}
+	_, err = d.Header()
+	if _, ok := err.(FormatError); !ok {
+		t.Errorf("error %v, expected a FormatError", err)
+	}
+
+	d = NewKTX2Decoder(bytes.NewReader(malformedKTX2(t)["huge level"].b))
+	d.Limits = Limits{}
+	_, err = d.Header()
+	if err != nil {
+		t.Errorf("header error %v without limits", err)
+	}
+}
+
+// fuzzLimits keep allocations made while fuzzing small.
+var fuzzLimits = Limits{
+	MaxDimension:     1 << 10,
+	MaxArrayElements: 64,
+	MaxKeyValueData:  1 << 12,
+	MaxLevelSize:     1 << 16,
+}
+
+func FuzzRead(f *testing.F) {
+	f.Add(testKTX(f, LittleEndian))
+	f.Add(testKTX(f, BigEndian))
+	for _, test := range malformedKTX(f) {
+		f.Add(test.b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d := NewDecoder(bytes.NewReader(b))
+		d.Limits = fuzzLimits
+		h, err := d.Header()
+		if err != nil {
+			return
+		}
+		meta, err := d.Metadata()
+		if err != nil {
+			return
+		}
+		ParseMetadata(h, meta)
+		for {
+			level, data, err := d.NextLevel()
+			if err != nil {
+				return
+			}
+			if level >= d.NumLevels() {
+				t.Fatalf("level %d of %d", level, d.NumLevels())
+			}
+			images, err := h.Images(data)
+			if err == nil && len(images) != h.NumberOfImages() {
+				t.Fatalf("%d images, expected %d", len(images), h.NumberOfImages())
+			}
+		}
+	})
+}
+
+func FuzzParseMetadata(f *testing.F) {
+	f.Add(encodePairs(LittleEndian, "K0ey\x00v0\x00", "KTXorientation\x00S=r,T=d\x00"))
+	f.Add(encodePairs(LittleEndian, "abc\x00", "k\x00\x01\x02\x03"))
+	for _, meta := range malformedMetadata {
+		f.Add(meta)
+	}
+	f.Fuzz(func(t *testing.T, meta []byte) {
+		h := &Header{Endianness: LittleEndian}
+		m, err := ParseMetadata(h, meta)
+		if err != nil {
+			if _, ok := err.(FormatError); !ok {
+				t.Fatalf("error %v is not a FormatError", err)
+			}
+			return
+		}
+		m.Orientation()
+		b, err := m.Encode(h)
+		if err != nil {
+			// empty keys parse but cannot be encoded.
+			return
+		}
+		m2, err := ParseMetadata(h, b)
+		if err != nil || !reflect.DeepEqual(m, m2) {
+			t.Fatalf("round trip of %q gave %q: %v", m, m2, err)
+		}
+	})
+}
+
+func FuzzReadKTX2(f *testing.F) {
+	f.Add(validKTX2(f).encode())
+	zlibKTX2 := validKTX2(f)
+	zlibKTX2.scheme = SupercompressionZLIB
+	f.Add(zlibKTX2.encode())
+	for _, test := range malformedKTX2(f) {
+		f.Add(test.b)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		d := NewKTX2Decoder(bytes.NewReader(b))
+		d.Limits = fuzzLimits
+		k, data, err := d.Read()
+		if err != nil {
+			return
+		}
+		if len(data) != len(k.Levels) {
+			t.Fatalf("%d levels, expected %d", len(data), len(k.Levels))
+		}
+		for i := range data {
+			k.Decompress(i, data[i])
+		}
+		if h, err := k.GLHeader(); err == nil {
+			ParseMetadata(h, k.Meta)
+		}
+	})
+}
@@ -0,0 +1,226 @@
+package ktx
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Well-known metadata keys defined by the ktx specifications.
+const (
+	KeyOrientation = "KTXorientation"
+	KeyWriter      = "KTXwriter"
+	KeySwizzle     = "KTXswizzle"
+)
+
+// KeyValue is a single metadata key-value pair.
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// Metadata is the list of key-value pairs from a ktx file in the order they
+// appear in the file.  A key may appear more than once.
+type Metadata []KeyValue
+
+// ParseMetadata parses the key-value block meta, as returned by Read or
+// Decoder.Metadata, preserving the order of its pairs.
+func ParseMetadata(h *Header, meta []byte) (Metadata, error) {
+	var m Metadata
+	for len(meta) > 0 {
+		if len(meta) < 4 {
+			return nil, FormatError("truncated metadata pair size")
+		}
+		var kvsize uint32
+		kvsize, meta = decodeUint32(h.Endianness, meta)
+		if uint64(kvsize) > uint64(len(meta)) {
+			return nil, FormatError("metadata pair exceeds key-value data")
+		}
+		kvdata := meta[:kvsize]
+		meta = meta[kvsize:]
+		padsize := int(3 - (kvsize+3)%4)
+		if padsize > len(meta) {
+			padsize = len(meta)
+		}
+		for _, c := range meta[:padsize] {
+			if c != 0 {
+				return nil, FormatError("non-zero metadata padding")
+			}
+		}
+		meta = meta[padsize:]
+
+		klen := bytes.IndexByte(kvdata, 0)
+		if klen < 0 {
+			return nil, FormatError("metadata pair missing null terminated key")
+		}
+		m = append(m, KeyValue{
+			Key:   string(kvdata[:klen]),
+			Value: kvdata[klen+1:], // skip the terminating null
+		})
+	}
+	return m, nil
+}
+
+// Encode encodes m into a key-value block suitable for Write, in the byte
+// order given by h.
+func (m Metadata) Encode(h *Header) ([]byte, error) {
+	var meta []byte
+	for _, kv := range m {
+		var err error
+		meta, err = appendKeyValue(h.Endianness, meta, kv.Key, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return meta, nil
+}
+
+// Get returns the value of the first pair in m with the given key.
+func (m Metadata) Get(key string) ([]byte, bool) {
+	for _, kv := range m {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetString returns the value of the first pair in m with the given key as a
+// string without its terminating null.
+func (m Metadata) GetString(key string) (string, bool) {
+	v, ok := m.Get(key)
+	if !ok {
+		return "", false
+	}
+	return string(bytes.TrimSuffix(v, []byte{0})), true
+}
+
+// Set replaces the value of the first pair in m with the given key, or
+// appends a new pair if there is none.
+func (m *Metadata) Set(key string, value []byte) {
+	for i := range *m {
+		if (*m)[i].Key == key {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, KeyValue{key, value})
+}
+
+// SetString is like Set but stores value as a null terminated utf-8 string,
+// as the specification recommends.
+func (m *Metadata) SetString(key, value string) {
+	v := make([]byte, len(value)+1)
+	copy(v, value)
+	m.Set(key, v)
+}
+
+// Writer returns the value of the KTXwriter key, identifying the program
+// which wrote the file.
+func (m Metadata) Writer() (string, bool) {
+	return m.GetString(KeyWriter)
+}
+
+// SetWriter sets the KTXwriter key.
+func (m *Metadata) SetWriter(writer string) {
+	m.SetString(KeyWriter, writer)
+}
+
+// Swizzle returns the value of the KTXswizzle key, a four character string
+// mapping the r, g, b, and a channels to one of "rgba01".
+func (m Metadata) Swizzle() (string, bool) {
+	return m.GetString(KeySwizzle)
+}
+
+// SetSwizzle sets the KTXswizzle key.
+func (m *Metadata) SetSwizzle(swizzle string) error {
+	if len(swizzle) != 4 || strings.Trim(swizzle, "rgba01") != "" {
+		return fmt.Errorf("invalid swizzle: %q", swizzle)
+	}
+	m.SetString(KeySwizzle, swizzle)
+	return nil
+}
+
+// Orientation returns the parsed value of the KTXorientation key.  If the key
+// is not present ok is false.
+func (m Metadata) Orientation() (o Orientation, ok bool, err error) {
+	v, ok := m.GetString(KeyOrientation)
+	if !ok {
+		return Orientation{}, false, nil
+	}
+	o, err = ParseOrientation(v)
+	if err != nil {
+		return Orientation{}, true, err
+	}
+	return o, true, nil
+}
+
+// SetOrientation sets the KTXorientation key.
+func (m *Metadata) SetOrientation(o Orientation) {
+	m.SetString(KeyOrientation, o.String())
+}
+
+// Direction is the direction in which a texture coordinate increases.
+type Direction byte
+
+// Possible Direction values.
+const (
+	Right Direction = 'r'
+	Left  Direction = 'l'
+	Down  Direction = 'd'
+	Up    Direction = 'u'
+	Out   Direction = 'o'
+	In    Direction = 'i'
+)
+
+// Orientation describes the logical orientation of texture data -- the
+// directions in which the S, T, and R texture coordinates increase.  R is
+// zero for textures with fewer than three dimensions and T is zero for
+// one-dimensional textures.
+type Orientation struct {
+	S Direction
+	T Direction
+	R Direction
+}
+
+// ParseOrientation parses a KTXorientation value.  Both the ktx form,
+// "S=r,T=d", and the ktx2 form, "rd", are accepted.
+func ParseOrientation(s string) (Orientation, error) {
+	var dirs []byte
+	if strings.Contains(s, "=") {
+		for i, f := range strings.Split(s, ",") {
+			if i > 2 || len(f) != 3 || f[0] != "STR"[i] || f[1] != '=' {
+				return Orientation{}, fmt.Errorf("invalid orientation: %q", s)
+			}
+			dirs = append(dirs, f[2])
+		}
+	} else {
+		dirs = []byte(s)
+	}
+	if len(dirs) == 0 || len(dirs) > 3 {
+		return Orientation{}, fmt.Errorf("invalid orientation: %q", s)
+	}
+
+	var o Orientation
+	axes := []*Direction{&o.S, &o.T, &o.R}
+	valid := []string{"rl", "du", "oi"}
+	for i, d := range dirs {
+		if strings.IndexByte(valid[i], d) < 0 {
+			return Orientation{}, fmt.Errorf("invalid orientation: %q", s)
+		}
+		*axes[i] = Direction(d)
+	}
+	return o, nil
+}
+
+// String returns the ktx form of o, for example "S=r,T=d".
+func (o Orientation) String() string {
+	var fields []string
+	for i, d := range []Direction{o.S, o.T, o.R} {
+		if d == 0 {
+			break
+		}
+		fields = append(fields, fmt.Sprintf("%c=%c", "STR"[i], d))
+	}
+	return strings.Join(fields, ",")
+}
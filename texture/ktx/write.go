@@ -0,0 +1,174 @@
+package ktx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Write writes a ktx format byte stream to w.  The metadata meta must be an
+// encoded key-value block, as returned by EncodeMetadata, and each element of
+// data holds the image data for one mipmap level.  Levels of array or cubemap
+// textures hold each image concatenated in the order described by
+// Header.Images.  The BytesOfKeyValueData field of h is ignored and computed
+// from meta.
+//
+// If h.NumberOfMipmapLevels is zero data must contain exactly one level and the
+// loader is expected to generate the remaining mipmaps.
+func Write(w io.Writer, h *Header, meta []byte, data [][]byte) error {
+	if len(meta)%4 != 0 {
+		return fmt.Errorf("metadata is not 4-byte aligned")
+	}
+	numLevels := int(h.NumberOfMipmapLevels)
+	if numLevels == 0 {
+		numLevels = 1
+	}
+	if len(data) != numLevels {
+		return fmt.Errorf("header specifies %d mipmap levels but %d were given", numLevels, len(data))
+	}
+
+	hcopy := *h
+	hcopy.BytesOfKeyValueData = uint32(len(meta))
+
+	bw := bufio.NewWriter(w)
+	err := EncodeHeader(bw, &hcopy)
+	if err != nil {
+		return err
+	}
+	_, err = bw.Write(meta)
+	if err != nil {
+		return err
+	}
+
+	var pad [3]byte
+	for _, b := range data {
+		if h.isCubePadded() {
+			err = writeCubeLevel(bw, h, b)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		size := uint32(len(b))
+		_, err = bw.Write(appendUint32(h.Endianness, nil, size))
+		if err != nil {
+			return err
+		}
+		_, err = bw.Write(b)
+		if err != nil {
+			return err
+		}
+		mipPad := 3 - (size+3)%4
+		_, err = bw.Write(pad[:mipPad])
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeCubeLevel writes the mipmap level b for a non-array cubemap, which is
+// stored as six individually padded faces following the size of one face.
+func writeCubeLevel(w io.Writer, h *Header, b []byte) error {
+	if len(b)%6 != 0 {
+		return fmt.Errorf("cubemap level size %d is not divisible into 6 faces", len(b))
+	}
+	size := uint32(len(b) / 6)
+	_, err := w.Write(appendUint32(h.Endianness, nil, size))
+	if err != nil {
+		return err
+	}
+	var pad [3]byte
+	cubePad := 3 - (size+3)%4
+	for i := uint32(0); i < 6; i++ {
+		_, err = w.Write(b[i*size : (i+1)*size])
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(pad[:cubePad])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeHeader encodes a ktx file header to w.
+func EncodeHeader(w io.Writer, h *Header) error {
+	if h.Endianness != BigEndian && h.Endianness != LittleEndian {
+		return fmt.Errorf("invalid endianness: %d", h.Endianness)
+	}
+	b := make([]byte, 0, headerSize)
+	b = append(b, fileID[:]...)
+	b = appendUint32(h.Endianness, b, endiannessValue)
+	b = appendUint32(h.Endianness, b, h.GLType)
+	b = appendUint32(h.Endianness, b, h.GLTypeSize)
+	b = appendUint32(h.Endianness, b, h.GLFormat)
+	b = appendUint32(h.Endianness, b, h.GLInternalFormat)
+	b = appendUint32(h.Endianness, b, h.GLBaseInternalFormat)
+	b = appendUint32(h.Endianness, b, h.PixelWidth)
+	b = appendUint32(h.Endianness, b, h.PixelHeight)
+	b = appendUint32(h.Endianness, b, h.PixelDepth)
+	b = appendUint32(h.Endianness, b, h.NumberOfArrayElements)
+	b = appendUint32(h.Endianness, b, h.NumberOfFaces)
+	b = appendUint32(h.Endianness, b, h.NumberOfMipmapLevels)
+	b = appendUint32(h.Endianness, b, h.BytesOfKeyValueData)
+	_, err := w.Write(b)
+	return err
+}
+
+// EncodeMetadata encodes metadata key-value pairs into a block suitable for
+// Write.  Keys are written in sorted order so that the output is
+// deterministic.  Values are written verbatim, callers wanting utf-8 string
+// values to be null terminated must include the terminating null.
+func EncodeMetadata(h *Header, m map[string][][]byte) ([]byte, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs Metadata
+	for _, k := range keys {
+		for _, v := range m[k] {
+			pairs = append(pairs, KeyValue{k, v})
+		}
+	}
+	return pairs.Encode(h)
+}
+
+// appendKeyValue appends the encoded key-value pair to b along with any
+// padding needed to keep the following pair 4-byte aligned.
+func appendKeyValue(end Endianness, b []byte, k string, v []byte) ([]byte, error) {
+	if len(k) == 0 {
+		return nil, fmt.Errorf("empty metadata key")
+	}
+	for i := 0; i < len(k); i++ {
+		if k[i] == 0 {
+			return nil, fmt.Errorf("metadata key contains a null byte: %q", k)
+		}
+	}
+	kvsize := uint32(len(k) + 1 + len(v))
+	b = appendUint32(end, b, kvsize)
+	b = append(b, k...)
+	b = append(b, 0)
+	b = append(b, v...)
+	padsize := 3 - (kvsize+3)%4
+	for i := uint32(0); i < padsize; i++ {
+		b = append(b, 0)
+	}
+	return b, nil
+}
+
+func appendUint32(end Endianness, b []byte, v uint32) []byte {
+	var buf [4]byte
+	if end == BigEndian {
+		binary.BigEndian.PutUint32(buf[:], v)
+	} else {
+		binary.LittleEndian.PutUint32(buf[:], v)
+	}
+	return append(b, buf[:]...)
+}
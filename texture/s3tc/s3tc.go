@@ -0,0 +1,158 @@
+// Package s3tc decodes S3TC compressed image data, also known as DXT1, DXT3,
+// and DXT5 or BC1, BC2, and BC3, for devices which cannot sample it directly.
+package s3tc
+
+import (
+	"fmt"
+	"image"
+)
+
+// Format identifies one of the S3TC block formats.
+type Format int
+
+// Supported formats.
+const (
+	DXT1 Format = iota + 1 // BC1, 4-bit color with optional 1-bit alpha
+	DXT3                   // BC2, DXT1 color with explicit 4-bit alpha
+	DXT5                   // BC3, DXT1 color with interpolated 8-bit alpha
+)
+
+func (f Format) String() string {
+	switch f {
+	case DXT1:
+		return "DXT1"
+	case DXT3:
+		return "DXT3"
+	case DXT5:
+		return "DXT5"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// BlockSize returns the number of bytes in each 4x4 block of f.
+func (f Format) BlockSize() int {
+	if f == DXT1 {
+		return 8
+	}
+	return 16
+}
+
+// Size returns the number of bytes of f data encoding an image with the
+// given dimensions.
+func (f Format) Size(width, height int) int {
+	return ((width + 3) / 4) * ((height + 3) / 4) * f.BlockSize()
+}
+
+// Decode decodes an image with the given dimensions from the f data in b.
+// The image has non-premultiplied alpha and its first row is the first row of
+// blocks in b.
+func Decode(f Format, b []byte, width, height int) (*image.NRGBA, error) {
+	if f < DXT1 || f > DXT5 {
+		return nil, fmt.Errorf("s3tc: unknown format %v", f)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("s3tc: invalid dimensions %dx%d", width, height)
+	}
+	if len(b) < f.Size(width, height) {
+		return nil, fmt.Errorf("s3tc: %d bytes is too short for a %dx%d %v image", len(b), width, height, f)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	var block [16][4]byte
+	blockSize := f.BlockSize()
+	for by := 0; by < height; by += 4 {
+		for bx := 0; bx < width; bx += 4 {
+			DecodeBlock(f, &block, b[:blockSize])
+			b = b[blockSize:]
+			for y := 0; y < 4 && by+y < height; y++ {
+				for x := 0; x < 4 && bx+x < width; x++ {
+					copy(img.Pix[img.PixOffset(bx+x, by+y):], block[y*4+x][:])
+				}
+			}
+		}
+	}
+	return img, nil
+}
+
+// DecodeBlock decodes a single block of f data into dst, which receives the
+// non-premultiplied RGBA value of each texel in row-major order.
+func DecodeBlock(f Format, dst *[16][4]byte, block []byte) {
+	switch f {
+	case DXT1:
+		decodeColor(dst, block, true)
+	case DXT3:
+		decodeColor(dst, block[8:], false)
+		for i := 0; i < 16; i++ {
+			a := block[i/2] >> (4 * uint(i%2)) & 0x0f
+			dst[i][3] = a<<4 | a
+		}
+	case DXT5:
+		decodeColor(dst, block[8:], false)
+		decodeAlpha(dst, block)
+	}
+}
+
+// decodeColor decodes a DXT1 color block.  Only DXT1 blocks use the
+// three-color mode with transparent black when the endpoints are ordered
+// c0 <= c1.
+func decodeColor(dst *[16][4]byte, block []byte, dxt1 bool) {
+	c0 := uint16(block[0]) | uint16(block[1])<<8
+	c1 := uint16(block[2]) | uint16(block[3])<<8
+	var palette [4][4]byte
+	palette[0] = rgb565(c0)
+	palette[1] = rgb565(c1)
+	if c0 > c1 || !dxt1 {
+		for i := 0; i < 3; i++ {
+			p0, p1 := uint32(palette[0][i]), uint32(palette[1][i])
+			palette[2][i] = uint8((2*p0 + p1 + 1) / 3)
+			palette[3][i] = uint8((p0 + 2*p1 + 1) / 3)
+		}
+		palette[2][3] = 0xff
+		palette[3][3] = 0xff
+	} else {
+		for i := 0; i < 3; i++ {
+			palette[2][i] = uint8((uint32(palette[0][i]) + uint32(palette[1][i])) / 2)
+		}
+		palette[2][3] = 0xff
+		palette[3] = [4]byte{0, 0, 0, 0}
+	}
+
+	bits := uint32(block[4]) | uint32(block[5])<<8 | uint32(block[6])<<16 | uint32(block[7])<<24
+	for i := 0; i < 16; i++ {
+		dst[i] = palette[bits>>(2*uint(i))&3]
+	}
+}
+
+// decodeAlpha decodes the interpolated alpha block of a DXT5 block.
+func decodeAlpha(dst *[16][4]byte, block []byte) {
+	a0, a1 := uint32(block[0]), uint32(block[1])
+	var alpha [8]byte
+	alpha[0], alpha[1] = uint8(a0), uint8(a1)
+	if a0 > a1 {
+		for i := uint32(1); i < 7; i++ {
+			alpha[i+1] = uint8(((7-i)*a0 + i*a1 + 3) / 7)
+		}
+	} else {
+		for i := uint32(1); i < 5; i++ {
+			alpha[i+1] = uint8(((5-i)*a0 + i*a1 + 2) / 5)
+		}
+		alpha[6], alpha[7] = 0, 0xff
+	}
+
+	var bits uint64
+	for i := 7; i >= 2; i-- {
+		bits = bits<<8 | uint64(block[i])
+	}
+	for i := 0; i < 16; i++ {
+		dst[i][3] = alpha[bits>>(3*uint(i))&7]
+	}
+}
+
+// rgb565 expands a 16-bit 5:6:5 color to 8 bits per component.
+func rgb565(c uint16) [4]byte {
+	r := uint8(c >> 11 & 0x1f)
+	g := uint8(c >> 5 & 0x3f)
+	b := uint8(c & 0x1f)
+	return [4]byte{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 0xff}
+}
@@ -0,0 +1,175 @@
+package s3tc
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	red         = [4]byte{0xff, 0, 0, 0xff}
+	blue        = [4]byte{0, 0, 0xff, 0xff}
+	green       = [4]byte{0, 0xff, 0, 0xff}
+	transparent = [4]byte{0, 0, 0, 0}
+	redBlue     = [4]byte{170, 0, 85, 0xff} // 2/3 red and 1/3 blue
+	blueRed     = [4]byte{85, 0, 170, 0xff} // 1/3 red and 2/3 blue
+)
+
+// rows returns a block whose rows each hold the texels c.
+func rows(c ...[4]byte) [16][4]byte {
+	var block [16][4]byte
+	for i := range block {
+		block[i] = c[i%4]
+	}
+	return block
+}
+
+// withAlpha returns block with the alpha of texel i set to alpha[i%len(alpha)].
+func withAlpha(block [16][4]byte, alpha ...byte) [16][4]byte {
+	for i := range block {
+		block[i][3] = alpha[i%len(alpha)]
+	}
+	return block
+}
+
+// indices0123 selects palette entries 0, 1, 2 and 3 across each row of a
+// color block.
+var indices0123 = []byte{0xe4, 0xe4, 0xe4, 0xe4}
+
+func colorBlock(c0, c1 uint16, indices []byte) []byte {
+	return append([]byte{byte(c0), byte(c0 >> 8), byte(c1), byte(c1 >> 8)}, indices...)
+}
+
+func TestDecodeBlock(t *testing.T) {
+	const (
+		red565   = 0xf800
+		green565 = 0x07e0
+		blue565  = 0x001f
+	)
+	for _, test := range []struct {
+		name  string
+		f     Format
+		block []byte
+		want  [16][4]byte
+	}{
+		{
+			name:  "dxt1 four colors",
+			f:     DXT1,
+			block: colorBlock(red565, blue565, indices0123),
+			want:  rows(red, blue, redBlue, blueRed),
+		},
+		{
+			name:  "dxt1 three colors",
+			f:     DXT1,
+			block: colorBlock(blue565, red565, indices0123),
+			want:  rows(blue, red, [4]byte{127, 0, 127, 0xff}, transparent),
+		},
+		{
+			name:  "dxt1 equal endpoints",
+			f:     DXT1,
+			block: colorBlock(green565, green565, indices0123),
+			want:  rows(green, green, green, transparent),
+		},
+		{
+			// the color block of dxt3 and dxt5 never has a transparent
+			// entry, whatever the order of its endpoints.
+			name:  "dxt3 alpha",
+			f:     DXT3,
+			block: append([]byte{0x10, 0x32, 0x54, 0x76, 0x98, 0xba, 0xdc, 0xfe}, colorBlock(blue565, red565, indices0123)...),
+			want: withAlpha(rows(blue, red, blueRed, redBlue),
+				0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff),
+		},
+		{
+			name:  "dxt5 eight alpha values",
+			f:     DXT5,
+			block: append([]byte{255, 0, 0x88, 0xc6, 0xfa, 0x88, 0xc6, 0xfa}, colorBlock(red565, blue565, indices0123)...),
+			want:  withAlpha(rows(red, blue, redBlue, blueRed), 255, 0, 219, 182, 146, 109, 73, 36),
+		},
+		{
+			name:  "dxt5 six alpha values",
+			f:     DXT5,
+			block: append([]byte{40, 240, 0x88, 0xc6, 0xfa, 0x88, 0xc6, 0xfa}, colorBlock(red565, blue565, indices0123)...),
+			want:  withAlpha(rows(red, blue, redBlue, blueRed), 40, 240, 80, 120, 160, 200, 0, 255),
+		},
+	} {
+		var block [16][4]byte
+		DecodeBlock(test.f, &block, test.block)
+		if block != test.want {
+			t.Errorf("%s: decoded %v, expected %v", test.name, block, test.want)
+		}
+	}
+}
+
+func TestDecodeCrop(t *testing.T) {
+	b := append(colorBlock(0xf800, 0, make([]byte, 4)), colorBlock(0x07e0, 0, make([]byte, 4))...)
+	img, err := Decode(DXT1, b, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 5, 3) {
+		t.Fatalf("bounds %v", img.Bounds())
+	}
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 5; x++ {
+			want := color.NRGBA{0xff, 0, 0, 0xff}
+			if x == 4 {
+				want = color.NRGBA{0, 0xff, 0, 0xff}
+			}
+			if c := img.NRGBAAt(x, y); c != want {
+				t.Errorf("texel %d,%d is %v, expected %v", x, y, c, want)
+			}
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		f             Format
+		b             []byte
+		width, height int
+	}{
+		{"unknown format", Format(0), make([]byte, 8), 4, 4},
+		{"unknown format", DXT5 + 1, make([]byte, 16), 4, 4},
+		{"zero width", DXT1, make([]byte, 8), 0, 4},
+		{"negative height", DXT1, make([]byte, 8), 4, -4},
+		{"truncated", DXT1, make([]byte, 15), 5, 4},
+		{"truncated", DXT3, make([]byte, 8), 4, 4},
+		{"truncated", DXT5, make([]byte, 16), 4, 5},
+	} {
+		_, err := Decode(test.f, test.b, test.width, test.height)
+		if err == nil {
+			t.Errorf("%s %v %dx%d: no error", test.name, test.f, test.width, test.height)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(uint8(DXT1), uint8(4), uint8(4), colorBlock(0x001f, 0xf800, indices0123))
+	f.Add(uint8(DXT1), uint8(5), uint8(3), append(colorBlock(0xf800, 0, indices0123), colorBlock(0x07e0, 0, indices0123)...))
+	f.Add(uint8(DXT3), uint8(4), uint8(4), append([]byte{0x10, 0x32, 0x54, 0x76, 0x98, 0xba, 0xdc, 0xfe}, colorBlock(0x001f, 0xf800, indices0123)...))
+	f.Add(uint8(DXT5), uint8(1), uint8(1), append([]byte{40, 240, 0x88, 0xc6, 0xfa, 0x88, 0xc6, 0xfa}, colorBlock(0xf800, 0x001f, indices0123)...))
+	f.Add(uint8(0), uint8(4), uint8(4), make([]byte, 8))
+	f.Add(uint8(DXT1), uint8(0), uint8(4), make([]byte, 8))
+	f.Add(uint8(DXT5), uint8(4), uint8(5), make([]byte, 16))
+	f.Fuzz(func(t *testing.T, format, width, height uint8, b []byte) {
+		fm, w, h := Format(format), int(width), int(height)
+		img, err := Decode(fm, b, w, h)
+		if err != nil {
+			return
+		}
+		if img.Bounds() != image.Rect(0, 0, w, h) {
+			t.Fatalf("%v %dx%d: decoded bounds %v", fm, w, h, img.Bounds())
+		}
+		if fm != DXT1 {
+			return
+		}
+		// dxt1 alpha is one bit and its transparent texels are black.
+		for i := 0; i < len(img.Pix); i += 4 {
+			p := img.Pix[i : i+4]
+			if p[3] != 0 && p[3] != 0xff || p[3] == 0 && (p[0] != 0 || p[1] != 0 || p[2] != 0) {
+				t.Fatalf("%v %dx%d: decoded texel %v", fm, w, h, p)
+			}
+		}
+	})
+}
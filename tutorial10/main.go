@@ -27,8 +27,6 @@ import (
 	"image/color"
 	"log"
 	"math"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/bmatsuo/mobile-gl-tutorial/f32hack"
@@ -62,9 +60,8 @@ var (
 	glLightColor gl.Uniform
 	glLightPower gl.Uniform
 
-	// BUG:
-	// The DDS compressed texture format is never used because I'm not sure how
-	// to generate it or which platforms support it properly.
+	// texturePath names the texture without an extension, mobtex loads the
+	// asset variant best supported by the device.
 	texturePath string
 	objectPath  string
 
@@ -73,7 +70,7 @@ var (
 	bufD6UV     gl.Buffer
 	bufD6Norm   gl.Buffer
 	bufD6Index  gl.Buffer
-	textureD6   gl.Texture
+	textureD6   *mobtex.Texture
 	modelD6     *f32.Mat4
 	mvpD6       [16]float32
 	mD6         [16]float32
@@ -202,12 +199,6 @@ func computePV(sz size.Event, deltat float32, force bool) {
 	vY = float32(fovSpeed) // TODO: undo whatever transformation I decide is right
 }
 
-// invertUV determines if the hardcoded vertex UV matrix needs to have its y
-// values inverted.
-func invertUV() bool {
-	return strings.ToLower(filepath.Ext(texturePath)) == ".ktx"
-}
-
 func main() {
 	app.Main(func(a app.App) {
 		var glctx gl.Context
@@ -307,7 +298,7 @@ func onStart(glctx gl.Context) {
 		return
 	}
 
-	textureD6, err = mobtex.LoadPath(glctx, texturePath)
+	textureD6, err = mobtex.LoadPath(glctx, texturePath, nil)
 	if err != nil {
 		log.Printf("error loading texture: %v", err)
 		return
@@ -323,14 +314,11 @@ func onStart(glctx gl.Context) {
 	d6UVData = d6UVData[:0]
 	d6NormData = d6NormData[:0]
 	d6IndexData = d6IndexData[:0]
-	uvinvert := invertUV()
+	vboD6.OrientUV(textureD6.Origin)
 	for i := range vboD6.V {
 		d6VertexData = append(d6VertexData, f32.Bytes(binary.LittleEndian, vboD6.V[i][:]...)...)
 	}
 	for i := range vboD6.VT {
-		if uvinvert {
-			vboD6.VT[i][1] = 1 - vboD6.VT[i][1]
-		}
 		d6UVData = append(d6UVData, f32.Bytes(binary.LittleEndian, vboD6.VT[i][:]...)...)
 	}
 	for i := range vboD6.VN {
@@ -406,8 +394,10 @@ func onStop(glctx gl.Context) {
 	glctx.DeleteBuffer(bufD6UV)
 	glctx.DeleteBuffer(bufD6Norm)
 	glctx.DeleteBuffer(bufD6Index)
+	textureD6.Release()
 	fps.Release()
 	images.Release()
+	mobtex.Forget(glctx)
 }
 
 func onPaint(glctx gl.Context, sz size.Event) {
@@ -481,7 +471,7 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	// bind the die texture
 	glctx.ActiveTexture(gl.TEXTURE0)
-	glctx.BindTexture(gl.TEXTURE_2D, textureD6)
+	glctx.BindTexture(gl.TEXTURE_2D, textureD6.Texture)
 	glctx.Uniform1i(glTexture, 0)
 
 	glctx.DrawElements(gl.TRIANGLES, len(d6IndexData)/2, gl.UNSIGNED_SHORT, 0)
@@ -27,8 +27,6 @@ import (
 	"image/color"
 	"log"
 	"math"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/bmatsuo/mobile-gl-tutorial/f32hack"
@@ -63,9 +61,8 @@ var (
 	glLightColor gl.Uniform
 	glLightPower gl.Uniform
 
-	// BUG:
-	// The DDS compressed texture format is never used because I'm not sure how
-	// to generate it or which platforms support it properly.
+	// texturePath names the texture without an extension, mobtex loads the
+	// asset variant best supported by the device.
 	texturePath string
 	objectPath  string
 
@@ -73,7 +70,7 @@ var (
 	bufD6UV     gl.Buffer
 	bufD6Norm   gl.Buffer
 	bufD6Index  gl.Buffer
-	textureD6   gl.Texture
+	textureD6   *mobtex.Texture
 	modelD6     *f32.Mat4
 	mvpD6       [16]float32
 	mD6         [16]float32
@@ -203,12 +200,6 @@ func computePV(sz size.Event, deltat float32, force bool) {
 	vY = float32(fovSpeed) // TODO: undo whatever transformation I decide is right
 }
 
-// invertUV determines if the hardcoded vertex UV matrix needs to have its y
-// values inverted.
-func invertUV() bool {
-	return strings.ToLower(filepath.Ext(texturePath)) == ".ktx"
-}
-
 func main() {
 	app.Main(func(a app.App) {
 		var glctx gl.Context
@@ -310,13 +301,13 @@ func onStart(glctx gl.Context) {
 		return
 	}
 
-	textureD6, err = mobtex.LoadPath(glctx, texturePath)
+	textureD6, err = mobtex.LoadPath(glctx, texturePath, nil)
 	if err != nil {
 		log.Printf("error loading texture: %v", err)
 		return
 	}
 
-	text, err = newText2D(glctx, "Holstein.ktx")
+	text, err = newText2D(glctx, "Holstein")
 	if err != nil {
 		log.Printf("initializing text engine: %v", err)
 		return
@@ -332,14 +323,11 @@ func onStart(glctx gl.Context) {
 	d6UVData = d6UVData[:0]
 	d6NormData = d6NormData[:0]
 	d6IndexData = d6IndexData[:0]
-	uvinvert := invertUV()
+	vbo.OrientUV(textureD6.Origin)
 	for i := range vbo.V {
 		d6VertexData = append(d6VertexData, f32.Bytes(binary.LittleEndian, vbo.V[i][:]...)...)
 	}
 	for i := range vbo.VT {
-		if uvinvert {
-			vbo.VT[i][1] = 1 - vbo.VT[i][1]
-		}
 		d6UVData = append(d6UVData, f32.Bytes(binary.LittleEndian, vbo.VT[i][:]...)...)
 	}
 	for i := range vbo.VN {
@@ -414,8 +402,10 @@ func onStop(glctx gl.Context) {
 	glctx.DeleteBuffer(bufD6Vertex)
 	glctx.DeleteBuffer(bufD6UV)
 	text.cleanup()
+	textureD6.Release()
 	fps.Release()
 	images.Release()
+	mobtex.Forget(glctx)
 }
 
 func onPaint(glctx gl.Context, sz size.Event) {
@@ -488,7 +478,7 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	// bind the die texture
 	glctx.ActiveTexture(gl.TEXTURE0)
-	glctx.BindTexture(gl.TEXTURE_2D, textureD6)
+	glctx.BindTexture(gl.TEXTURE_2D, textureD6.Texture)
 	glctx.Uniform1i(glTexture, 0)
 
 	glctx.DrawElements(gl.TRIANGLES, len(d6IndexData)/2, gl.UNSIGNED_SHORT, 0)
@@ -22,7 +22,7 @@ type text2D struct {
 
 	gl        gl.Context
 	program   gl.Program
-	texture   gl.Texture
+	texture   *mobtex.Texture
 	vertexPos gl.Attrib
 	vertexUV  gl.Attrib
 	sampler   gl.Uniform
@@ -48,7 +48,7 @@ func (t2d *text2D) init(glctx gl.Context, texturePath string) error {
 	t2d.program = program
 
 	t2d.texturePath = texturePath
-	texture, err := mobtex.LoadPath(t2d.gl, t2d.texturePath)
+	texture, err := mobtex.LoadPath(t2d.gl, t2d.texturePath, nil)
 	if err != nil {
 		t2d.cleanup()
 		return err
@@ -89,11 +89,16 @@ func (t2d *text2D) write(text string, x, y, size int) {
 
 		uvX := float32(char%16) / 16.0
 		uvY := float32(char/16) / 16.0
+		uvTop, uvBottom := uvY, uvY+uvOffset
+		if t2d.texture.Origin != mobtex.OriginTopLeft {
+			// glyph rows are counted from the top of the font image
+			uvTop, uvBottom = 1-uvY, 1-uvY-uvOffset
+		}
 
-		qUpLeft = Vec2{float32(uvX), float32(uvY)}
-		qUpRight = Vec2{float32(uvX + uvOffset), float32(uvY)}
-		qDownRight = Vec2{float32(uvX + uvOffset), float32(uvY + uvOffset)}
-		qDownLeft = Vec2{float32(uvX), float32(uvY + uvOffset)}
+		qUpLeft = Vec2{float32(uvX), float32(uvTop)}
+		qUpRight = Vec2{float32(uvX + uvOffset), float32(uvTop)}
+		qDownRight = Vec2{float32(uvX + uvOffset), float32(uvBottom)}
+		qDownLeft = Vec2{float32(uvX), float32(uvBottom)}
 
 		uv = append(uv, qUpLeft)
 		uv = append(uv, qDownLeft)
@@ -120,7 +125,7 @@ func (t2d *text2D) write(text string, x, y, size int) {
 
 	// use TEXTURE0 for the fragment shader texture sampler
 	t2d.gl.ActiveTexture(gl.TEXTURE0)
-	t2d.gl.BindTexture(gl.TEXTURE_2D, t2d.texture)
+	t2d.gl.BindTexture(gl.TEXTURE_2D, t2d.texture.Texture)
 	t2d.gl.Uniform1i(t2d.sampler, 0)
 
 	// setup vertex position and uv attributes
@@ -161,9 +166,9 @@ func (t2d *text2D) cleanup() {
 		t2d.vBuffer = gl.Buffer{}
 	}
 
-	if t2d.texture.Value != 0 {
-		t2d.gl.DeleteTexture(t2d.texture)
-		t2d.texture = gl.Texture{}
+	if t2d.texture != nil {
+		t2d.texture.Release()
+		t2d.texture = nil
 	}
 
 	if t2d.program.Value != 0 {
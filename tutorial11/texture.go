@@ -4,5 +4,5 @@ package main
 
 func init() {
 	objectPath = "cube2.obj"
-	texturePath = "uvtemplate.bmp"
+	texturePath = "uvtemplate"
 }
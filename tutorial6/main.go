@@ -24,8 +24,6 @@ package main
 
 import (
 	"log"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/bmatsuo/mobile-gl-tutorial/f32hack"
@@ -52,14 +50,13 @@ var (
 	mvp       gl.Uniform
 	textureID gl.Uniform
 
-	// BUG:
-	// The DDS compressed texture format is never used because I'm not sure how
-	// to generate it or which platforms support it properly.
-	texturePath string
+	// texturePath names the texture without an extension, mobtex loads the
+	// asset variant best supported by the device.
+	texturePath = "uvtemplate"
 
 	bufD6Vertex gl.Buffer
 	bufD6UV     gl.Buffer
-	textureD6   gl.Texture
+	textureD6   *mobtex.Texture
 	modelD6     *f32.Mat4
 	mvpD6       [16]float32
 
@@ -166,9 +163,10 @@ func computePV(sz size.Event, deltat float32, force bool) {
 }
 
 // invertUV determines if the hardcoded vertex UV matrix needs to have its y
-// values inverted.
+// values inverted.  The hardcoded coordinates assume a texture with its first
+// row at the top of the image.
 func invertUV() bool {
-	return strings.ToLower(filepath.Ext(texturePath)) == ".bmp"
+	return textureD6.Origin != mobtex.OriginTopLeft
 }
 
 func main() {
@@ -263,7 +261,7 @@ func onStart(glctx gl.Context) {
 		return
 	}
 
-	textureD6, err = mobtex.LoadPath(glctx, texturePath)
+	textureD6, err = mobtex.LoadPath(glctx, texturePath, nil)
 	if err != nil {
 		log.Printf("error loading texture: %v", err)
 		return
@@ -319,8 +317,10 @@ func onStop(glctx gl.Context) {
 	glctx.DeleteProgram(program)
 	glctx.DeleteBuffer(bufD6Vertex)
 	glctx.DeleteBuffer(bufD6UV)
+	textureD6.Release()
 	fps.Release()
 	images.Release()
+	mobtex.Forget(glctx)
 }
 
 func onPaint(glctx gl.Context, sz size.Event) {
@@ -363,7 +363,7 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	// bind the die texture
 	glctx.ActiveTexture(gl.TEXTURE0)
-	glctx.BindTexture(gl.TEXTURE_2D, textureD6)
+	glctx.BindTexture(gl.TEXTURE_2D, textureD6.Texture)
 	glctx.Uniform1i(textureID, 0)
 
 	glctx.DrawArrays(gl.TRIANGLES, 0, d6VertexCount)
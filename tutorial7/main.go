@@ -25,8 +25,6 @@ package main
 import (
 	"encoding/binary"
 	"log"
-	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/bmatsuo/mobile-gl-tutorial/f32hack"
@@ -53,14 +51,13 @@ var (
 	mvp       gl.Uniform
 	textureID gl.Uniform
 
-	// BUG:
-	// The DDS compressed texture format is never used because I'm not sure how
-	// to generate it or which platforms support it properly.
-	texturePath string
+	// texturePath names the texture without an extension, mobtex loads the
+	// asset variant best supported by the device.
+	texturePath = "uvtemplate"
 
 	bufD6Vertex gl.Buffer
 	bufD6UV     gl.Buffer
-	textureD6   gl.Texture
+	textureD6   *mobtex.Texture
 	modelD6     *f32.Mat4
 	mvpD6       [16]float32
 
@@ -166,12 +163,6 @@ func computePV(sz size.Event, deltat float32, force bool) {
 	vY = float32(fovSpeed) // TODO: undo whatever transformation I decide is right
 }
 
-// invertUV determines if the hardcoded vertex UV matrix needs to have its y
-// values inverted.
-func invertUV() bool {
-	return strings.ToLower(filepath.Ext(texturePath)) == ".ktx"
-}
-
 func main() {
 	app.Main(func(a app.App) {
 		var glctx gl.Context
@@ -264,7 +255,7 @@ func onStart(glctx gl.Context) {
 		return
 	}
 
-	textureD6, err = mobtex.LoadPath(glctx, texturePath)
+	textureD6, err = mobtex.LoadPath(glctx, texturePath, nil)
 	if err != nil {
 		log.Printf("error loading texture: %v", err)
 		return
@@ -277,14 +268,11 @@ func onStart(glctx gl.Context) {
 	}
 	d6VertexData = d6VertexData[:0]
 	d6UVData = d6UVData[:0]
-	uvinvert := invertUV()
+	obj.OrientUV(textureD6.Origin)
 	for i := range obj.V {
 		d6VertexData = append(d6VertexData, f32.Bytes(binary.LittleEndian, obj.V[i][:]...)...)
 	}
 	for i := range obj.VT {
-		if uvinvert {
-			obj.VT[i][1] = 1 - obj.VT[i][1]
-		}
 		d6UVData = append(d6UVData, f32.Bytes(binary.LittleEndian, obj.VT[i][:]...)...)
 	}
 
@@ -332,8 +320,10 @@ func onStop(glctx gl.Context) {
 	glctx.DeleteProgram(program)
 	glctx.DeleteBuffer(bufD6Vertex)
 	glctx.DeleteBuffer(bufD6UV)
+	textureD6.Release()
 	fps.Release()
 	images.Release()
+	mobtex.Forget(glctx)
 }
 
 func onPaint(glctx gl.Context, sz size.Event) {
@@ -376,7 +366,7 @@ func onPaint(glctx gl.Context, sz size.Event) {
 
 	// bind the die texture
 	glctx.ActiveTexture(gl.TEXTURE0)
-	glctx.BindTexture(gl.TEXTURE_2D, textureD6)
+	glctx.BindTexture(gl.TEXTURE_2D, textureD6.Texture)
 	glctx.Uniform1i(textureID, 0)
 
 	glctx.DrawArrays(gl.TRIANGLES, 0, d6VertexCount)
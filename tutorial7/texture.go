@@ -1,7 +0,0 @@
-//+build !linux,!android
-
-package main
-
-func init() {
-	texturePath = "uvtemplate.bmp"
-}
@@ -2,5 +2,5 @@ package main
 
 func init() {
 	objectPath = "suzanne.obj"
-	texturePath = "uvmap.ktx"
+	texturePath = "uvmap"
 }